@@ -1,18 +1,33 @@
 package main
 
 import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/json"
 	"fmt"
+	"io"
 	"math"
 	"net/http"
 	"os"
+	"os/signal"
+	"sort"
 	"strconv"
+	"strings"
+	"text/tabwriter"
 	"time"
 
 	"github.com/alecthomas/kong"
 	"github.com/davecgh/go-spew/spew"
 	"github.com/rmrfslashbin/gomarta/pkg/bus"
 	"github.com/rmrfslashbin/gomarta/pkg/database"
+	"github.com/rmrfslashbin/gomarta/pkg/diff"
+	"github.com/rmrfslashbin/gomarta/pkg/export"
+	"github.com/rmrfslashbin/gomarta/pkg/gtfspec"
+	"github.com/rmrfslashbin/gomarta/pkg/planner"
+	"github.com/rmrfslashbin/gomarta/pkg/schedule"
 	"github.com/rmrfslashbin/gomarta/pkg/specsupdate"
+	"github.com/rmrfslashbin/gomarta/pkg/transfers"
+	"github.com/rmrfslashbin/gomarta/pkg/validate"
 	"github.com/rs/zerolog"
 )
 
@@ -40,6 +55,7 @@ type BusCmd struct {
 	Vehicles    bool    `name:"vehicles" group:"fetch" help:"Fetch the vehicles."`
 	Trips       bool    `name:"trips" group:"fetch" help:"Fetch the trips."`
 	Route       *string `name:"route" help:"Route to fetch. (ex: 37)"`
+	Namespace   string  `name:"namespace" help:"Namespace the agency's GTFS feed was imported with."`
 }
 
 // Run is the entry point for the BusCmd command
@@ -62,7 +78,8 @@ func (r *BusCmd) Run(ctx *Context) error {
 		bus.WithDatabase(db),
 		bus.WithLogger(ctx.log),
 		bus.WithTripsUrl(r.TripsUrl),
-		bus.WithVehiclesUrl(r.VehiclesUrl))
+		bus.WithVehiclesUrl(r.VehiclesUrl),
+		bus.WithNamespace(r.Namespace))
 	if err != nil {
 		return err
 	}
@@ -85,6 +102,9 @@ func (r *BusCmd) Run(ctx *Context) error {
 		for _, vehicle := range data.Vehicles {
 			spew.Dump(vehicle)
 		}
+		if len(data.UnmatchedVehicles) > 0 {
+			spew.Dump(data.UnmatchedVehicles)
+		}
 	}
 
 	if r.Route != nil && data.Trips != nil {
@@ -103,10 +123,87 @@ func (r *BusCmd) Run(ctx *Context) error {
 	return nil
 }
 
+// DbCmd manages the database schema
+type DbCmd struct {
+	Migrate DbMigrateCmd `cmd:"" help:"Apply pending schema migrations."`
+	Status  DbStatusCmd  `cmd:"" help:"Show the schema version and pending migrations."`
+}
+
+// DbMigrateCmd applies pending schema migrations
+type DbMigrateCmd struct{}
+
+// Run is the entry point for the DbMigrateCmd command
+func (r *DbMigrateCmd) Run(ctx *Context) error {
+	db, err := database.New(
+		database.WithLogger(ctx.log),
+		database.WithSqlite(ctx.sqlite),
+		database.WithMysql(ctx.mysql),
+		database.WithPgsql(ctx.pgsql),
+		database.WithoutSchemaCheck(),
+	)
+	if err != nil {
+		return err
+	}
+
+	applied, err := db.Migrate()
+	for _, migration := range applied {
+		fmt.Printf("applied %d: %s\n", migration.Version, migration.Description)
+	}
+	if err != nil {
+		return err
+	}
+	if len(applied) == 0 {
+		fmt.Printf("schema is up to date (version %d)\n", database.LatestVersion())
+	}
+
+	return nil
+}
+
+// DbStatusCmd shows the schema version and pending migrations
+type DbStatusCmd struct{}
+
+// Run is the entry point for the DbStatusCmd command
+func (r *DbStatusCmd) Run(ctx *Context) error {
+	db, err := database.New(
+		database.WithLogger(ctx.log),
+		database.WithSqlite(ctx.sqlite),
+		database.WithMysql(ctx.mysql),
+		database.WithPgsql(ctx.pgsql),
+		database.WithoutSchemaCheck(),
+	)
+	if err != nil {
+		return err
+	}
+
+	version, err := db.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	status, err := db.MigrationStatus()
+	if err != nil {
+		return err
+	}
+
+	fmt.Printf("Schema version: %d (latest %d)\n\n", version, database.LatestVersion())
+	rows := [][]string{{"VERSION", "DESCRIPTION", "APPLIED"}}
+	for _, migration := range status {
+		applied := "pending"
+		if migration.AppliedAt != nil {
+			applied = migration.AppliedAt.Format(time.RFC3339)
+		}
+		rows = append(rows, []string{strconv.Itoa(migration.Version), migration.Description, applied})
+	}
+	return writeRows(os.Stdout, rows, "table")
+}
+
 // UpdateSpecsCmd updates the GTFS feed specs
 type UpdateSpecsCmd struct {
-	Url   string `name:"url" default:"https://itsmarta.com/google_transit_feed/google_transit.zip" help:"URL the GTFS feed spec zip file."`
-	Check bool   `name:"check" help:"Get last update data."`
+	Url        string `name:"url" default:"https://itsmarta.com/google_transit_feed/google_transit.zip" help:"URL the GTFS feed spec zip file."`
+	Check      bool   `name:"check" help:"Get last update data."`
+	Policy     string `name:"policy" default:"abort" enum:"abort,skip,default" help:"How to handle records that fail to parse (abort, skip, default)."`
+	Report     string `name:"report" default:"none" enum:"none,text,json" help:"Write an import report (none, text, json)."`
+	ReportFile string `name:"reportfile" help:"Write the import report to this file instead of stdout."`
+	Namespace  string `name:"namespace" help:"Prefix every id of the feed with this namespace so several agencies can be stored side by side. (ex: cobblinc)"`
 }
 
 // prettyByteSize formats a byte size into a human readable format
@@ -154,12 +251,880 @@ func (r *UpdateSpecsCmd) Run(ctx *Context) error {
 		specsupdate.WithDatabase(db),
 		specsupdate.WithLogger(ctx.log),
 		specsupdate.WithUrl(r.Url),
+		specsupdate.WithPolicy(specsupdate.Policy(r.Policy)),
+		specsupdate.WithNamespace(r.Namespace),
+	)
+	if err != nil {
+		return err
+	}
+
+	updateErr := spec.Update()
+
+	if report := spec.Report(); report != nil && r.Report != "none" {
+		if err := writeReport(report, r.Report, r.ReportFile); err != nil {
+			return err
+		}
+	}
+
+	return updateErr
+}
+
+// writeReport writes an import report to a file, or to stdout if no file is given
+func writeReport(report *specsupdate.Report, format string, filename string) error {
+	out := os.Stdout
+	if filename != "" {
+		f, err := os.Create(filename)
+		if err != nil {
+			return err
+		}
+		defer f.Close()
+		out = f
+	}
+
+	if format == "json" {
+		return report.WriteJSON(out)
+	}
+	return report.WriteText(out)
+}
+
+// TimetableCmd prints the scheduled timetable of a route, or the departures from a stop
+type TimetableCmd struct {
+	Route     *string `name:"route" xor:"target" required:"" help:"Route id or short name. (ex: 37)"`
+	Stop      *string `name:"stop" xor:"target" required:"" help:"Stop id to list departures for."`
+	Date      string  `name:"date" help:"Service date (YYYY-MM-DD). Defaults to today."`
+	Direction int     `name:"direction" default:"0" help:"Direction id (0 or 1)."`
+	Format    string  `name:"format" default:"table" enum:"table,csv" help:"Output format (table, csv)."`
+}
+
+// Run is the entry point for the TimetableCmd command
+func (r *TimetableCmd) Run(ctx *Context) error {
+	date, err := parseDate(r.Date)
+	if err != nil {
+		return err
+	}
+
+	db, err := database.New(
+		database.WithLogger(ctx.log),
+		database.WithSqlite(ctx.sqlite),
+		database.WithMysql(ctx.mysql),
+		database.WithPgsql(ctx.pgsql),
+	)
+	if err != nil {
+		return err
+	}
+
+	sched, err := schedule.New(
+		schedule.WithDatabase(db),
+		schedule.WithLogger(ctx.log),
+	)
+	if err != nil {
+		return err
+	}
+
+	rows := make([][]string, 0)
+
+	if r.Stop != nil {
+		departures, err := sched.Departures(*r.Stop, date)
+		if err != nil {
+			return err
+		}
+		rows = append(rows, []string{"time", "route", "headsign", "trip_id"})
+		for _, departure := range departures {
+			rows = append(rows, []string{
+				gtfspec.GTFSTimeOf(departure.Time, date, sched.Location()).String(),
+				departure.Route.ShortName,
+				departure.Trip.Headsign,
+				departure.Trip.TripID,
+			})
+		}
+	} else {
+		timetable, err := sched.Timetable(&schedule.TimetableInput{
+			RouteId:     *r.Route,
+			Date:        date,
+			DirectionId: r.Direction,
+		})
+		if err != nil {
+			return err
+		}
+		header := []string{"stop_id", "stop_name"}
+		for _, trip := range timetable.Trips {
+			header = append(header, trip.TripID)
+		}
+		rows = append(rows, header)
+		for i, stop := range timetable.Stops {
+			row := []string{stop.StopId, stop.Name}
+			for _, t := range timetable.Times[i] {
+				row = append(row, t.String())
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	return writeRows(os.Stdout, rows, r.Format)
+}
+
+// IsochroneCmd lists the stops reachable from an origin within a time budget
+type IsochroneCmd struct {
+	From      string        `name:"from" help:"Origin stop or station id."`
+	Lat       float64       `name:"lat" help:"Origin latitude, used when --from is not given."`
+	Lon       float64       `name:"lon" help:"Origin longitude, used when --from is not given."`
+	At        string        `name:"at" help:"Departure time (HH:MM). Defaults to now."`
+	Date      string        `name:"date" help:"Departure date (YYYY-MM-DD). Defaults to today."`
+	Budget    time.Duration `name:"budget" default:"45m" help:"Travel time budget. (ex: 45m)"`
+	Transfers int           `name:"transfers" default:"3" help:"Maximum number of transfers. 0 reaches stops on direct trips only."`
+	MaxWalk   float64       `name:"maxwalk" default:"800" help:"Farthest walk in meters from a coordinate origin, and the largest buffer."`
+	Format    string        `name:"format" default:"points" enum:"points,buffers,csv" help:"Output format: GeoJSON points, GeoJSON buffers or csv."`
+}
+
+// Run is the entry point for the IsochroneCmd command
+func (r *IsochroneCmd) Run(ctx *Context) error {
+	if r.From == "" && r.Lat == 0 && r.Lon == 0 {
+		return fmt.Errorf("must specify --from or --lat and --lon")
+	}
+
+	db, err := database.New(
+		database.WithLogger(ctx.log),
+		database.WithSqlite(ctx.sqlite),
+		database.WithMysql(ctx.mysql),
+		database.WithPgsql(ctx.pgsql),
+	)
+	if err != nil {
+		return err
+	}
+
+	p, err := planner.New(
+		planner.WithDatabase(db),
+		planner.WithLogger(ctx.log),
+	)
+	if err != nil {
+		return err
+	}
+
+	depart, err := parseDeparture(r.Date, r.At, p.Location())
+	if err != nil {
+		return err
+	}
+
+	reach, err := p.Reach(&planner.ReachInput{
+		From:         r.From,
+		Lat:          r.Lat,
+		Lon:          r.Lon,
+		Depart:       depart,
+		Budget:       r.Budget,
+		MaxTransfers: r.Transfers,
+		MaxWalk:      r.MaxWalk,
+	})
+	if err != nil {
+		return err
+	}
+
+	if r.Format == "csv" {
+		rows := [][]string{{"stop_id", "stop_name", "stop_lat", "stop_lon", "seconds", "transfers"}}
+		for _, reached := range reach.Stops {
+			rows = append(rows, []string{
+				reached.Stop.StopId,
+				reached.Stop.Name,
+				strconv.FormatFloat(reached.Stop.Lat, 'f', -1, 64),
+				strconv.FormatFloat(reached.Stop.Lon, 'f', -1, 64),
+				strconv.Itoa(reached.Seconds),
+				strconv.Itoa(reached.Transfers),
+			})
+		}
+		return writeRows(os.Stdout, rows, "csv")
+	}
+
+	enc := json.NewEncoder(os.Stdout)
+	enc.SetIndent("", "  ")
+	return enc.Encode(reach.GeoJSON(r.Format == "buffers"))
+}
+
+// TransfersCmd generates walking transfers between nearby stops
+type TransfersCmd struct {
+	MaxDistance float64 `name:"maxdistance" default:"250" help:"Farthest straight line walk between two stops in meters."`
+	DryRun      bool    `name:"dryrun" help:"Print the transfers as csv instead of storing them."`
+}
+
+// Run is the entry point for the TransfersCmd command
+func (r *TransfersCmd) Run(ctx *Context) error {
+	db, err := database.New(
+		database.WithLogger(ctx.log),
+		database.WithSqlite(ctx.sqlite),
+		database.WithMysql(ctx.mysql),
+		database.WithPgsql(ctx.pgsql),
+	)
+	if err != nil {
+		return err
+	}
+
+	generator, err := transfers.New(
+		transfers.WithDatabase(db),
+		transfers.WithLogger(ctx.log),
+		transfers.WithMaxDistance(r.MaxDistance),
+	)
+	if err != nil {
+		return err
+	}
+
+	walking, err := generator.Generate()
+	if err != nil {
+		return err
+	}
+
+	if r.DryRun {
+		rows := [][]string{{"from_stop_id", "to_stop_id", "distance", "walk_time", "same_station"}}
+		for _, transfer := range walking {
+			rows = append(rows, []string{
+				transfer.FromStopId,
+				transfer.ToStopId,
+				strconv.FormatFloat(transfer.Distance, 'f', 1, 64),
+				strconv.Itoa(transfer.WalkTime),
+				strconv.FormatBool(transfer.SameStation),
+			})
+		}
+		return writeRows(os.Stdout, rows, "csv")
+	}
+
+	if err := generator.Store(walking); err != nil {
+		return err
+	}
+	ctx.log.Info().Int("transfers", len(walking)).Msg("stored walking transfers")
+
+	return nil
+}
+
+// PlanCmd plans a journey between two stops over the static schedule
+type PlanCmd struct {
+	From      string `name:"from" required:"" help:"Origin stop or station id."`
+	To        string `name:"to" required:"" help:"Destination stop or station id."`
+	At        string `name:"at" help:"Departure time (HH:MM). Defaults to now."`
+	Date      string `name:"date" help:"Departure date (YYYY-MM-DD). Defaults to today."`
+	Transfers int    `name:"transfers" default:"3" help:"Maximum number of transfers. 0 finds direct trips only."`
+	Format    string `name:"format" default:"text" enum:"text,json" help:"Output format (text, json)."`
+	Realtime  bool   `name:"realtime" help:"Apply current bus trip updates to the schedule."`
+	TripsUrl  string `name:"tripsurl" default:"https://gtfs-rt.itsmarta.com/TMGTFSRealTimeWebService/tripupdate/tripupdates.pb" help:"URL for the Marta Bus Trips GTFS endpoint."`
+	Namespace string `name:"namespace" help:"Namespace the bus feed's agency was imported with."`
+}
+
+// Run is the entry point for the PlanCmd command
+func (r *PlanCmd) Run(ctx *Context) error {
+	db, err := database.New(
+		database.WithLogger(ctx.log),
+		database.WithSqlite(ctx.sqlite),
+		database.WithMysql(ctx.mysql),
+		database.WithPgsql(ctx.pgsql),
 	)
 	if err != nil {
 		return err
 	}
 
-	return spec.Update()
+	p, err := planner.New(
+		planner.WithDatabase(db),
+		planner.WithLogger(ctx.log),
+	)
+	if err != nil {
+		return err
+	}
+
+	depart, err := parseDeparture(r.Date, r.At, p.Location())
+	if err != nil {
+		return err
+	}
+
+	input := &planner.PlanInput{
+		From:         r.From,
+		To:           r.To,
+		Depart:       depart,
+		MaxTransfers: r.Transfers,
+	}
+
+	if r.Realtime {
+		b, err := bus.New(
+			bus.WithDatabase(db),
+			bus.WithLogger(ctx.log),
+			bus.WithTripsUrl(r.TripsUrl),
+			bus.WithNamespace(r.Namespace))
+		if err != nil {
+			return err
+		}
+		data, err := b.Fetch(&bus.FetchInput{Trips: true})
+		if err != nil {
+			return err
+		}
+		input.Realtime = data.TripUpdates
+	}
+
+	plan, err := p.Plan(input)
+	if err != nil {
+		return err
+	}
+
+	if r.Format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(plan)
+	}
+
+	if plan.Scheduled != nil {
+		fmt.Println("Expected:")
+	}
+	printItineraries(plan.Itineraries)
+	if plan.Scheduled != nil {
+		fmt.Println("\nScheduled:")
+		printItineraries(plan.Scheduled)
+	}
+
+	return nil
+}
+
+// printItineraries prints itineraries one leg per line. Transit legs running
+// off schedule also show their scheduled times.
+func printItineraries(itineraries []*planner.Itinerary) {
+	clock := func(expected time.Time, scheduled time.Time) string {
+		if expected.Equal(scheduled) {
+			return expected.Format("15:04")
+		}
+		return fmt.Sprintf("%s (scheduled %s)", expected.Format("15:04"), scheduled.Format("15:04"))
+	}
+
+	for ndx, itinerary := range itineraries {
+		fmt.Printf("Itinerary %d: %s - %s (%s, %d transfers)\n",
+			ndx+1,
+			itinerary.Departure.Format("15:04"),
+			itinerary.Arrival.Format("15:04"),
+			itinerary.Duration(),
+			itinerary.Transfers)
+		for _, leg := range itinerary.Legs {
+			if leg.Mode == planner.ModeWalk {
+				fmt.Printf("  %s  walk        %s -> %s\n", leg.Departure.Format("15:04"), leg.From.Name, leg.To.Name)
+				continue
+			}
+			route := leg.Trip.RouteId
+			if leg.Route != nil {
+				route = leg.Route.ShortName
+			}
+			fmt.Printf("  %s  route %-5s %s -> %s (arrive %s)\n",
+				clock(leg.Departure, leg.ScheduledDeparture),
+				route,
+				leg.From.Name,
+				leg.To.Name,
+				clock(leg.Arrival, leg.ScheduledArrival))
+		}
+	}
+}
+
+// parseDeparture combines a date and an HH:MM time in loc, defaulting to now
+func parseDeparture(date string, at string, loc *time.Location) (time.Time, error) {
+	now := time.Now().In(loc)
+	if date == "" && at == "" {
+		return now, nil
+	}
+
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	if date != "" {
+		var err error
+		if day, err = parseDate(date); err != nil {
+			return time.Time{}, err
+		}
+	}
+
+	clock := time.Date(0, 1, 1, now.Hour(), now.Minute(), now.Second(), 0, time.UTC)
+	if at != "" {
+		var err error
+		if clock, err = time.Parse("15:04", at); err != nil {
+			if clock, err = time.Parse("15:04:05", at); err != nil {
+				return time.Time{}, err
+			}
+		}
+	}
+
+	return time.Date(day.Year(), day.Month(), day.Day(), clock.Hour(), clock.Minute(), clock.Second(), 0, loc), nil
+}
+
+// parseDate parses a YYYY-MM-DD or YYYYMMDD date, defaulting to today
+func parseDate(s string) (time.Time, error) {
+	if s == "" {
+		now := time.Now()
+		return time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC), nil
+	}
+	if date, err := time.Parse("2006-01-02", s); err == nil {
+		return date, nil
+	}
+	return time.Parse("20060102", s)
+}
+
+// writeRows writes rows as CSV or as an aligned table; the first row is the header
+func writeRows(w io.Writer, rows [][]string, format string) error {
+	if format == "csv" {
+		cw := csv.NewWriter(w)
+		if err := cw.WriteAll(rows); err != nil {
+			return err
+		}
+		cw.Flush()
+		return cw.Error()
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	for _, row := range rows {
+		fmt.Fprintln(tw, strings.Join(row, "\t"))
+	}
+	return tw.Flush()
+}
+
+// DiffCmd compares two versions of the GTFS feed
+type DiffCmd struct {
+	Old           string  `name:"old" default:"db" help:"Earlier feed: a zip file, a directory, a URL, or db for the stored feed."`
+	New           string  `name:"new" required:"" help:"Later feed: a zip file, a directory, a URL, or db for the stored feed."`
+	Format        string  `name:"format" default:"text" enum:"text,json" help:"Output format (text, json)."`
+	MoveThreshold float64 `name:"movethreshold" default:"10" help:"Report stops that moved at least this many meters."`
+}
+
+// Run is the entry point for the DiffCmd command
+func (r *DiffCmd) Run(ctx *Context) error {
+	old, err := loadFeed(ctx, r.Old)
+	if err != nil {
+		return err
+	}
+	updated, err := loadFeed(ctx, r.New)
+	if err != nil {
+		return err
+	}
+
+	differ, err := diff.New(
+		diff.WithOld(old),
+		diff.WithNew(updated),
+		diff.WithMoveThreshold(r.MoveThreshold),
+		diff.WithLogger(ctx.log),
+	)
+	if err != nil {
+		return err
+	}
+
+	changes := differ.Compare()
+	if r.Format == "json" {
+		return changes.WriteJSON(os.Stdout)
+	}
+	return changes.WriteText(os.Stdout)
+}
+
+// loadFeed reads a feed from a zip file, an unpacked directory, a URL, or
+// the database when the source is "db". Records that fail to parse are skipped.
+func loadFeed(ctx *Context, source string) (*gtfspec.Feed, error) {
+	if source == "db" {
+		db, err := database.New(
+			database.WithLogger(ctx.log),
+			database.WithSqlite(ctx.sqlite),
+			database.WithMysql(ctx.mysql),
+			database.WithPgsql(ctx.pgsql),
+		)
+		if err != nil {
+			return nil, err
+		}
+		return db.LoadFeed()
+	}
+
+	spec, err := specsupdate.New(
+		specsupdate.WithLogger(ctx.log),
+		specsupdate.WithUrl(source),
+		specsupdate.WithPolicy(specsupdate.PolicySkip),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	if strings.HasPrefix(source, "http://") || strings.HasPrefix(source, "https://") {
+		zipReader, err := spec.Fetch()
+		if err != nil {
+			return nil, err
+		}
+		return spec.Parse(zipReader)
+	}
+
+	info, err := os.Stat(source)
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return spec.ParseFS(os.DirFS(source))
+	}
+
+	readCloser, err := zip.OpenReader(source)
+	if err != nil {
+		return nil, err
+	}
+	defer readCloser.Close()
+	return spec.Parse(&readCloser.Reader)
+}
+
+// ExportCmd writes the stored schedule out in other formats
+type ExportCmd struct {
+	Gtfs ExportGtfsCmd `cmd:"" help:"Write the stored schedule as a GTFS zip."`
+}
+
+// ExportGtfsCmd writes the stored schedule as a GTFS zip
+type ExportGtfsCmd struct {
+	Out    string   `name:"out" required:"" help:"GTFS zip file to write."`
+	Routes []string `name:"route" help:"Only export these routes, by id or short name. May be repeated."`
+	From   string   `name:"from" help:"Only export service on or after this date (YYYY-MM-DD)."`
+	To     string   `name:"to" help:"Only export service on or before this date (YYYY-MM-DD)."`
+}
+
+// Run is the entry point for the ExportGtfsCmd command
+func (r *ExportGtfsCmd) Run(ctx *Context) error {
+	start, end, err := parseDateRange(r.From, r.To)
+	if err != nil {
+		return err
+	}
+
+	db, err := database.New(
+		database.WithLogger(ctx.log),
+		database.WithSqlite(ctx.sqlite),
+		database.WithMysql(ctx.mysql),
+		database.WithPgsql(ctx.pgsql),
+	)
+	if err != nil {
+		return err
+	}
+
+	exporter, err := export.New(
+		export.WithDatabase(db),
+		export.WithLogger(ctx.log),
+		export.WithRoutes(r.Routes...),
+		export.WithDateRange(start, end),
+	)
+	if err != nil {
+		return err
+	}
+
+	if err := writeExport(exporter, r.Out); err != nil {
+		return err
+	}
+	ctx.log.Info().Str("file", r.Out).Msg("exported feed")
+
+	return nil
+}
+
+// SubsetCmd writes a smaller, consistent copy of a GTFS feed
+type SubsetCmd struct {
+	In       string   `name:"in" default:"db" help:"Feed to subset: a zip file, a directory, a URL, or db for the stored feed."`
+	Out      string   `name:"out" required:"" help:"GTFS zip file to write."`
+	Routes   []string `name:"route" help:"Only keep these routes, by id or short name. May be repeated."`
+	Agencies []string `name:"agency" help:"Only keep the routes of these agencies. May be repeated."`
+	Bbox     string   `name:"bbox" help:"Only keep trips that stop inside this box (minLat,minLon,maxLat,maxLon)."`
+	From     string   `name:"from" help:"Only keep service on or after this date (YYYY-MM-DD)."`
+	To       string   `name:"to" help:"Only keep service on or before this date (YYYY-MM-DD)."`
+}
+
+// Run is the entry point for the SubsetCmd command
+func (r *SubsetCmd) Run(ctx *Context) error {
+	start, end, err := parseDateRange(r.From, r.To)
+	if err != nil {
+		return err
+	}
+
+	feed, err := loadFeed(ctx, r.In)
+	if err != nil {
+		return err
+	}
+
+	opts := []export.Option{
+		export.WithFeed(feed),
+		export.WithLogger(ctx.log),
+		export.WithRoutes(r.Routes...),
+		export.WithAgencies(r.Agencies...),
+		export.WithDateRange(start, end),
+	}
+	if r.Bbox != "" {
+		parts := strings.Split(r.Bbox, ",")
+		if len(parts) != 4 {
+			return fmt.Errorf("--bbox must be minLat,minLon,maxLat,maxLon")
+		}
+		box := make([]float64, 4)
+		for i, part := range parts {
+			if box[i], err = strconv.ParseFloat(strings.TrimSpace(part), 64); err != nil {
+				return fmt.Errorf("--bbox: %w", err)
+			}
+		}
+		opts = append(opts, export.WithBounds(box[0], box[1], box[2], box[3]))
+	}
+
+	exporter, err := export.New(opts...)
+	if err != nil {
+		return err
+	}
+
+	subset, err := exporter.Feed()
+	if err != nil {
+		return err
+	}
+	if err := writeExport(exporter, r.Out); err != nil {
+		return err
+	}
+	ctx.log.Info().
+		Str("file", r.Out).
+		Int("routes", len(subset.Routes)).
+		Int("trips", len(subset.Trips)).
+		Int("stops", len(subset.Stops)).
+		Int("stopTimes", len(subset.StopTimes)).
+		Msg("wrote feed subset")
+
+	return nil
+}
+
+// writeExport writes an export to a file, removing the file if writing fails
+func writeExport(exporter *export.Exporter, filename string) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := exporter.Write(f); err != nil {
+		f.Close()
+		os.Remove(filename)
+		return err
+	}
+	return f.Close()
+}
+
+// parseDateRange parses optional start and end dates; an empty date is the zero time
+func parseDateRange(from string, to string) (time.Time, time.Time, error) {
+	var start, end time.Time
+	var err error
+	if from != "" {
+		if start, err = parseDate(from); err != nil {
+			return start, end, err
+		}
+	}
+	if to != "" {
+		if end, err = parseDate(to); err != nil {
+			return start, end, err
+		}
+	}
+	return start, end, nil
+}
+
+// ValidateCmd checks a static GTFS feed for problems
+type ValidateCmd struct {
+	Url           string  `name:"url" xor:"source" help:"URL of a GTFS feed zip file to validate."`
+	Zip           string  `name:"zip" xor:"source" type:"existingfile" help:"GTFS feed zip file to validate."`
+	Format        string  `name:"format" default:"text" enum:"text,json" help:"Output format (text, json)."`
+	Date          string  `name:"date" help:"Date calendars are checked for expiry against (YYYY-MM-DD). Defaults to today."`
+	ShapeDistance float64 `name:"shapedistance" default:"150" help:"Farthest a stop may be from its trip's shape in meters."`
+}
+
+// Run is the entry point for the ValidateCmd command. Without --url or --zip
+// the feed stored in the database is validated.
+func (r *ValidateCmd) Run(ctx *Context) error {
+	date, err := parseDate(r.Date)
+	if err != nil {
+		return err
+	}
+
+	var feed *gtfspec.Feed
+	var imported *specsupdate.Report
+
+	if r.Url != "" || r.Zip != "" {
+		spec, err := specsupdate.New(
+			specsupdate.WithLogger(ctx.log),
+			specsupdate.WithUrl(r.Url),
+			specsupdate.WithPolicy(specsupdate.PolicySkip),
+		)
+		if err != nil {
+			return err
+		}
+
+		var zipReader *zip.Reader
+		if r.Zip != "" {
+			readCloser, err := zip.OpenReader(r.Zip)
+			if err != nil {
+				return err
+			}
+			defer readCloser.Close()
+			zipReader = &readCloser.Reader
+		} else if zipReader, err = spec.Fetch(); err != nil {
+			return err
+		}
+
+		if feed, err = spec.Parse(zipReader); err != nil {
+			return err
+		}
+		imported = spec.Report()
+	} else {
+		db, err := database.New(
+			database.WithLogger(ctx.log),
+			database.WithSqlite(ctx.sqlite),
+			database.WithMysql(ctx.mysql),
+			database.WithPgsql(ctx.pgsql),
+		)
+		if err != nil {
+			return err
+		}
+		if feed, err = db.LoadFeed(); err != nil {
+			return err
+		}
+	}
+
+	validator, err := validate.New(
+		validate.WithFeed(feed),
+		validate.WithImportReport(imported),
+		validate.WithDate(date),
+		validate.WithShapeDistance(r.ShapeDistance),
+		validate.WithLogger(ctx.log),
+	)
+	if err != nil {
+		return err
+	}
+
+	report := validator.Validate()
+	if r.Format == "json" {
+		err = report.WriteJSON(os.Stdout)
+	} else {
+		err = report.WriteText(os.Stdout)
+	}
+	if err != nil {
+		return err
+	}
+
+	if report.HasErrors() {
+		return &validate.ErrInvalidFeed{Errors: report.Counts[validate.SeverityError]}
+	}
+	return nil
+}
+
+// ValidateRealtimeCmd checks the bus GTFS-RT feeds against the static feed in the database
+type ValidateRealtimeCmd struct {
+	VehiclesUrl string        `name:"vehiclesurl" default:"https://gtfs-rt.itsmarta.com/TMGTFSRealTimeWebService/vehicle/vehiclepositions.pb" help:"URL for the Marta Bus Vehicles GTFS endpoint."`
+	TripsUrl    string        `name:"tripsurl" default:"https://gtfs-rt.itsmarta.com/TMGTFSRealTimeWebService/tripupdate/tripupdates.pb" help:"URL for the Marta Bus Trips GTFS endpoint."`
+	Vehicles    bool          `name:"vehicles" group:"fetch" help:"Validate the vehicle positions."`
+	Trips       bool          `name:"trips" group:"fetch" help:"Validate the trip updates."`
+	Format      string        `name:"format" default:"text" enum:"text,json" help:"Output format (text, json)."`
+	MaxAge      time.Duration `name:"maxage" default:"15m" help:"Report timestamps older than this as stale."`
+	Watch       time.Duration `name:"watch" help:"Validate repeatedly at this interval and keep counts per finding code until interrupted."`
+	Namespace   string        `name:"namespace" help:"Namespace the agency's GTFS feed was imported with."`
+}
+
+// Run is the entry point for the ValidateRealtimeCmd command
+func (r *ValidateRealtimeCmd) Run(ctx *Context) error {
+	if !r.Vehicles && !r.Trips {
+		return fmt.Errorf("must specify at least one of --vehicles or --trips")
+	}
+
+	db, err := database.New(
+		database.WithLogger(ctx.log),
+		database.WithSqlite(ctx.sqlite),
+		database.WithMysql(ctx.mysql),
+		database.WithPgsql(ctx.pgsql),
+	)
+	if err != nil {
+		return err
+	}
+	feed, err := db.LoadFeed()
+	if err != nil {
+		return err
+	}
+
+	validator, err := validate.New(
+		validate.WithFeed(feed),
+		validate.WithMaxAge(r.MaxAge),
+		validate.WithNamespace(r.Namespace),
+		validate.WithLogger(ctx.log),
+	)
+	if err != nil {
+		return err
+	}
+
+	urls := make([]string, 0, 2)
+	if r.Trips {
+		urls = append(urls, r.TripsUrl)
+	}
+	if r.Vehicles {
+		urls = append(urls, r.VehiclesUrl)
+	}
+
+	if r.Watch <= 0 {
+		for _, url := range urls {
+			report, err := validateRealtime(validator, url)
+			if err != nil {
+				return err
+			}
+			if r.Format == "json" {
+				err = report.WriteJSON(os.Stdout)
+			} else {
+				fmt.Printf("%s\n", url)
+				err = report.WriteText(os.Stdout)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	ticker := time.NewTicker(r.Watch)
+	defer ticker.Stop()
+
+	totals := make(map[string]map[string]int, len(urls))
+	passes := 0
+	for {
+		for _, url := range urls {
+			report, err := validateRealtime(validator, url)
+			if err != nil {
+				ctx.log.Error().Err(err).Str("url", url).Msg("unable to validate realtime feed")
+				continue
+			}
+			if _, ok := totals[url]; !ok {
+				totals[url] = make(map[string]int)
+			}
+			for code, count := range report.CountByCode() {
+				totals[url][code] += count
+			}
+			ctx.log.Info().
+				Str("url", url).
+				Int("errors", report.Counts[validate.SeverityError]).
+				Int("warnings", report.Counts[validate.SeverityWarning]).
+				Interface("codes", report.CountByCode()).
+				Msg("validated realtime feed")
+		}
+		passes++
+
+		select {
+		case <-ticker.C:
+		case <-interrupt:
+			return printRealtimeTotals(urls, totals, passes, r.Format)
+		}
+	}
+}
+
+// printRealtimeTotals writes the finding counts per feed and code collected by a watch
+func printRealtimeTotals(urls []string, totals map[string]map[string]int, passes int, format string) error {
+	if format == "json" {
+		enc := json.NewEncoder(os.Stdout)
+		enc.SetIndent("", "  ")
+		return enc.Encode(map[string]interface{}{"passes": passes, "counts": totals})
+	}
+
+	rows := [][]string{{"FEED", "CODE", "COUNT"}}
+	for _, url := range urls {
+		codes := make([]string, 0, len(totals[url]))
+		for code := range totals[url] {
+			codes = append(codes, code)
+		}
+		sort.Strings(codes)
+		for _, code := range codes {
+			rows = append(rows, []string{url, code, strconv.Itoa(totals[url][code])})
+		}
+	}
+	fmt.Printf("%d passes\n", passes)
+	return writeRows(os.Stdout, rows, "table")
+}
+
+// validateRealtime fetches a GTFS-RT feed and validates it
+func validateRealtime(validator *validate.Validator, url string) (*validate.Report, error) {
+	message, err := validate.FetchRealtime(url)
+	if err != nil {
+		return nil, err
+	}
+	return validator.ValidateRealtime(message)
 }
 
 // CLI is the main CLI struct
@@ -170,8 +1135,18 @@ type CLI struct {
 	Mysql    *string `name:"mysql" env:"MYSQL" group:"database" xor:"database" required:"" help:"MySQL connection string."`
 	Pgsql    *string `name:"pgsql" env:"PGSQL" group:"database" xor:"database" required:"" help:"PostgreSQL connection string."`
 
-	Bus    BusCmd         `cmd:"" help:"Get bus data."`
-	Update UpdateSpecsCmd `cmd:"" help:"Update the GTFS feed specs."`
+	Bus       BusCmd              `cmd:"" help:"Get bus data."`
+	Db        DbCmd               `cmd:"" help:"Manage the database schema."`
+	Diff      DiffCmd             `cmd:"" help:"Compare two versions of the GTFS feed."`
+	Export    ExportCmd           `cmd:"" help:"Export the stored schedule."`
+	Isochrone IsochroneCmd        `cmd:"" help:"List the stops reachable within a time budget."`
+	Plan      PlanCmd             `cmd:"" help:"Plan a journey between two stops."`
+	Subset    SubsetCmd           `cmd:"" help:"Write a smaller, consistent copy of a GTFS feed."`
+	Timetable TimetableCmd        `cmd:"" help:"Print a route timetable or stop departures."`
+	Transfers TransfersCmd        `cmd:"" help:"Generate walking transfers between nearby stops."`
+	Update    UpdateSpecsCmd      `cmd:"" help:"Update the GTFS feed specs."`
+	Validate  ValidateCmd         `cmd:"" help:"Check a GTFS feed for problems."`
+	Realtime  ValidateRealtimeCmd `cmd:"" name:"validate-rt" help:"Check the bus GTFS-RT feeds against the GTFS feed."`
 }
 
 func main() {
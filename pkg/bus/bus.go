@@ -4,12 +4,13 @@ import (
 	"io"
 	"net/http"
 	"os"
-	"strconv"
 	"time"
 
 	"github.com/mmcloughlin/geohash"
-	"github.com/rmrfslashbin/gomarta/pkg/database"
+	"github.com/rmrfslashbin/gomarta/pkg/gtfspec"
 	"github.com/rmrfslashbin/gomarta/pkg/gtfsrt"
+	"github.com/rmrfslashbin/gomarta/pkg/schedule"
+	"github.com/rmrfslashbin/gomarta/pkg/store"
 	"github.com/rs/zerolog"
 	"google.golang.org/protobuf/proto"
 )
@@ -19,8 +20,15 @@ type Option func(c *Bus)
 
 // Bus for the app instance
 type Bus struct {
-	log         *zerolog.Logger
-	db          *database.Database
+	log      *zerolog.Logger
+	db       store.Reader
+	schedule *schedule.Schedule
+
+	// scheduleFailed is set when the schedule could not be loaded, so it is
+	// not tried again on every fetch
+	scheduleFailed bool
+
+	namespace   string
 	VehiclesUrl string
 	TripsUrl    string
 }
@@ -57,12 +65,27 @@ func New(opts ...Option) (*Bus, error) {
 }
 
 // WithDatabase sets the database for the bus instance
-func WithDatabase(db *database.Database) Option {
+func WithDatabase(db store.Reader) Option {
 	return func(c *Bus) {
 		c.db = db
 	}
 }
 
+// WithSchedule sets the schedule used to resolve trip instances
+func WithSchedule(schedule *schedule.Schedule) Option {
+	return func(c *Bus) {
+		c.schedule = schedule
+	}
+}
+
+// WithNamespace sets the namespace the static feed was imported with, so the
+// realtime ids of that agency are matched to the stored ones
+func WithNamespace(namespace string) Option {
+	return func(c *Bus) {
+		c.namespace = namespace
+	}
+}
+
 // WithLogger sets the logger for the bus instance
 func WithLogger(log *zerolog.Logger) Option {
 	return func(c *Bus) {
@@ -93,232 +116,502 @@ type FetchInput struct {
 func (c *Bus) Fetch(input *FetchInput) (*FetchOutput, error) {
 	output := &FetchOutput{}
 
+	// The schedule only resolves trip instances; realtime data is still
+	// returned without it, with Instance left nil.
+	if c.schedule == nil && !c.scheduleFailed {
+		sched, err := schedule.New(
+			schedule.WithDatabase(c.db),
+			schedule.WithLogger(c.log),
+		)
+		if err != nil {
+			c.log.Warn().
+				Str("function", "pkg/bus.Fetch()").
+				Err(err).
+				Msg("unable to load the schedule, trip instances will not be resolved")
+			c.scheduleFailed = true
+		} else {
+			c.schedule = sched
+		}
+	}
+
+	var trips, vehicles []*gtfsrt.FeedEntity
+	var err error
 	if input.Trips {
 		c.log.Debug().
 			Str("url", c.TripsUrl).
 			Str("function", "pkg/bus.Fetch()").
 			Msg("getting trip data")
-		if trips, err := c.getData(c.TripsUrl); err != nil {
+		if trips, err = c.getData(c.TripsUrl); err != nil {
 			return nil, err
-		} else {
-			output.Trips = make(map[string]*Trip, len(trips))
-			for _, trip := range trips {
-				t := &Trip{}
-				t.Raw = trip
-
-				t.Id = trip.GetId()
-				t.Deleted = trip.GetIsDeleted()
-
-				alert := trip.GetAlert()
-				if alert != nil {
-					c.log.Error().Msg("alert provided in trip data")
-					/*
-						alert.GetActivePeriod()
-						alert.GetCause().String()
-						alert.GetDescriptionText().String()
-						alert.GetEffect().String()
-						alert.GetHeaderText().String()
-						alert.GetInformedEntity()
-						alert.GetUrl().String()
-					*/
-				}
+		}
+	}
+	if input.Vehicles {
+		c.log.Debug().
+			Str("url", c.VehiclesUrl).
+			Str("function", "pkg/bus.Fetch()").
+			Msg("getting vehicle data")
+		if vehicles, err = c.getData(c.VehiclesUrl); err != nil {
+			return nil, err
+		}
+	}
+
+	static, err := c.loadStatic(trips, vehicles)
+	if err != nil {
+		return nil, err
+	}
 
-				tripUpdate := trip.GetTripUpdate()
-				if tripUpdate != nil {
-					t.Delay = tripUpdate.GetDelay()
-					t.Timestamp = time.Unix(int64(tripUpdate.GetTimestamp()), 0)
+	if input.Trips {
+		output.Trips = make(map[string]*Trip, len(trips))
+		output.TripUpdates = make(map[string]*Trip, len(trips))
+		for _, trip := range trips {
+			t := &Trip{}
+			t.Raw = trip
+
+			t.Id = trip.GetId()
+			t.Deleted = trip.GetIsDeleted()
+
+			alert := trip.GetAlert()
+			if alert != nil {
+				c.log.Error().Msg("alert provided in trip data")
+				/*
+					alert.GetActivePeriod()
+					alert.GetCause().String()
+					alert.GetDescriptionText().String()
+					alert.GetEffect().String()
+					alert.GetHeaderText().String()
+					alert.GetInformedEntity()
+					alert.GetUrl().String()
+				*/
+			}
 
-					t.StopTimeUpdate = make([]*StopTimeUpdate, len(tripUpdate.GetStopTimeUpdate()))
+			tripUpdate := trip.GetTripUpdate()
+			if tripUpdate != nil {
+				t.Delay = tripUpdate.GetDelay()
+				t.Timestamp = time.Unix(int64(tripUpdate.GetTimestamp()), 0)
+
+				tripDescriptor := tripUpdate.GetTrip()
+				if tripDescriptor != nil {
+					t.DirectionId = tripDescriptor.GetDirectionId()
+					t.RouteId = gtfspec.Namespaced(c.namespace, tripDescriptor.GetRouteId())
+					t.TripId = gtfspec.Namespaced(c.namespace, tripDescriptor.GetTripId())
+					t.ScheduleRelationship = tripDescriptor.GetScheduleRelationship().String()
+					t.StartDate = tripDescriptor.GetStartDate()
+					t.StartTime = tripDescriptor.GetStartTime()
+
+					t.Route, t.Trip = c.match(static, t.Id, t.TripId, t.RouteId)
+					if t.Trip != nil {
+						t.Instance = c.instance(t.TripId, t.StartDate, t.StartTime, t.Timestamp)
+					}
+				}
 
-					for ndx, stopTimeUpdate := range tripUpdate.GetStopTimeUpdate() {
-						stu := &StopTimeUpdate{}
+				t.StopTimeUpdate = make([]*StopTimeUpdate, len(tripUpdate.GetStopTimeUpdate()))
 
-						stu.StopSequence = stopTimeUpdate.GetStopSequence()
-						stu.StopId, _ = strconv.Atoi(stopTimeUpdate.GetStopId())
-						stu.Stop, _ = c.db.GetStop(stu.StopId)
+				for ndx, stopTimeUpdate := range tripUpdate.GetStopTimeUpdate() {
+					stu := &StopTimeUpdate{}
 
-						arrival := stopTimeUpdate.GetArrival()
-						if arrival != nil {
-							stu.Arrival = &Arrival{
-								Delay:       arrival.GetDelay(),
-								Time:        time.Unix(arrival.GetTime(), 0),
-								Uncertainty: arrival.GetUncertainty(),
-							}
-						}
+					stu.StopSequence = stopTimeUpdate.GetStopSequence()
+					stu.StopId = gtfspec.Namespaced(c.namespace, stopTimeUpdate.GetStopId())
+					stu.ScheduleRelationship = stopTimeUpdate.GetScheduleRelationship().String()
+					stu.Stop = static.stops[stu.StopId]
 
-						departure := stopTimeUpdate.GetDeparture()
-						if departure != nil {
-							stu.Departure = &Departure{
-								Delay:       departure.GetDelay(),
-								Time:        time.Unix(departure.GetTime(), 0),
-								Uncertainty: departure.GetUncertainty(),
-							}
+					// Match the scheduled stop time by stop_sequence, falling back to stop_id.
+					var scheduled *schedule.InstanceStopTime
+					if t.Instance != nil {
+						if stopTimeUpdate.StopSequence != nil {
+							scheduled = t.Instance.StopTimeBySequence(int(stu.StopSequence))
+						} else {
+							scheduled = t.Instance.StopTimeByStop(stu.StopId)
 						}
-						t.StopTimeUpdate[ndx] = stu
+					}
+					if scheduled != nil {
+						stu.Scheduled = scheduled.StopTime
 					}
 
-					tripDescriptor := tripUpdate.GetTrip()
-					if tripDescriptor != nil {
-						t.DirectionId = tripDescriptor.GetDirectionId()
-						t.RouteId, _ = strconv.Atoi(tripDescriptor.GetRouteId())
-						t.TripId, _ = strconv.Atoi(tripDescriptor.GetTripId())
-						//tripDescriptor.GetScheduleRelationship()
-						t.StartDate = tripDescriptor.GetStartDate()
-						t.StartTime = tripDescriptor.GetStartTime()
-
-						if t.Route, err = c.db.GetRoute(t.RouteId); err != nil {
-							return nil, err
+					arrival := stopTimeUpdate.GetArrival()
+					if arrival != nil {
+						stu.Arrival = &Arrival{
+							Delay:       arrival.GetDelay(),
+							Time:        time.Unix(arrival.GetTime(), 0),
+							Uncertainty: arrival.GetUncertainty(),
 						}
-						if t.Trip, err = c.db.GetTrip(t.TripId, t.RouteId); err != nil {
-							return nil, err
+						if scheduled != nil {
+							stu.Arrival.Scheduled = scheduled.Arrival
 						}
+						stu.Arrival.Predicted, stu.Arrival.Deviation, stu.Arrival.DelayOnly = predict(arrival, stu.Arrival.Scheduled)
 					}
 
-					/* Vehicle info isn't provided
-					vehicleDescriptor := tripUpdate.GetVehicle()
-					if vehicleDescriptor != nil {
-						vehicleDescriptor.GetId()
-						vehicleDescriptor.GetLabel()
-						vehicleDescriptor.GetLicensePlate()
+					departure := stopTimeUpdate.GetDeparture()
+					if departure != nil {
+						stu.Departure = &Departure{
+							Delay:       departure.GetDelay(),
+							Time:        time.Unix(departure.GetTime(), 0),
+							Uncertainty: departure.GetUncertainty(),
+						}
+						if scheduled != nil {
+							stu.Departure.Scheduled = scheduled.Departure
+						}
+						stu.Departure.Predicted, stu.Departure.Deviation, stu.Departure.DelayOnly = predict(departure, stu.Departure.Scheduled)
 					}
-					*/
+					t.StopTimeUpdate[ndx] = stu
 				}
+				t.normalize()
 
 				/* Vehicle info isn't provided
-				VehiclePosition := trip.GetVehicle()
-				t.Vehicle = &Vehicle{}
-				if VehiclePosition != nil {
-					t.Vehicle.CongestionLevel = VehiclePosition.GetCongestionLevel().String()
-					t.Vehicle.CurrentStatus = VehiclePosition.GetCurrentStatus().String()
-
-					VehiclePosition.GetCurrentStopSequence()
-
-					t.Vehicle.OccupancyStatus = VehiclePosition.GetOccupancyStatus().String()
-
-					position := VehiclePosition.GetPosition()
-					if position != nil {
-						t.Vehicle.Bearing = position.GetBearing()
-						t.Vehicle.Latitude = position.GetLatitude()
-						t.Vehicle.Longitude = position.GetLongitude()
-						t.Vehicle.Orometer = position.GetOdometer()
-						t.Vehicle.Speed = position.GetSpeed()
-					}
+				vehicleDescriptor := tripUpdate.GetVehicle()
+				if vehicleDescriptor != nil {
+					vehicleDescriptor.GetId()
+					vehicleDescriptor.GetLabel()
+					vehicleDescriptor.GetLicensePlate()
+				}
+				*/
+			}
 
-					t.StopId, _ = strconv.Atoi(VehiclePosition.GetStopId())
-					t.Vehicle.Timestamp = time.Unix(int64(VehiclePosition.GetTimestamp()), 0)
+			/* Vehicle info isn't provided
+			VehiclePosition := trip.GetVehicle()
+			t.Vehicle = &Vehicle{}
+			if VehiclePosition != nil {
+				t.Vehicle.CongestionLevel = VehiclePosition.GetCongestionLevel().String()
+				t.Vehicle.CurrentStatus = VehiclePosition.GetCurrentStatus().String()
 
-					vTripDescriptor := VehiclePosition.GetTrip()
-					if vTripDescriptor != nil {
-						t.Vehicle.DirectionId = vTripDescriptor.GetDirectionId()
-						t.Vehicle.RouteId, _ = strconv.Atoi(vTripDescriptor.GetRouteId())
-						//vTripDescriptor.GetScheduleRelationship()
-						t.Vehicle.TripId, _ = strconv.Atoi(vTripDescriptor.GetTripId())
-					}
-					vVehicleDescriptor := VehiclePosition.GetVehicle()
-					if vVehicleDescriptor != nil {
-						t.Vehicle.VehicleId = vVehicleDescriptor.GetId()
-						t.Vehicle.VehicleLabel = vVehicleDescriptor.GetLabel()
-						t.Vehicle.LicensePlate = vVehicleDescriptor.GetLicensePlate()
-					}
+				VehiclePosition.GetCurrentStopSequence()
+
+				t.Vehicle.OccupancyStatus = VehiclePosition.GetOccupancyStatus().String()
 
+				position := VehiclePosition.GetPosition()
+				if position != nil {
+					t.Vehicle.Bearing = position.GetBearing()
+					t.Vehicle.Latitude = position.GetLatitude()
+					t.Vehicle.Longitude = position.GetLongitude()
+					t.Vehicle.Orometer = position.GetOdometer()
+					t.Vehicle.Speed = position.GetSpeed()
 				}
-				*/
+
+				t.StopId = VehiclePosition.GetStopId()
+				t.Vehicle.Timestamp = time.Unix(int64(VehiclePosition.GetTimestamp()), 0)
+
+				vTripDescriptor := VehiclePosition.GetTrip()
+				if vTripDescriptor != nil {
+					t.Vehicle.DirectionId = vTripDescriptor.GetDirectionId()
+					t.Vehicle.RouteId = vTripDescriptor.GetRouteId()
+					//vTripDescriptor.GetScheduleRelationship()
+					t.Vehicle.TripId = vTripDescriptor.GetTripId()
+				}
+				vVehicleDescriptor := VehiclePosition.GetVehicle()
+				if vVehicleDescriptor != nil {
+					t.Vehicle.VehicleId = vVehicleDescriptor.GetId()
+					t.Vehicle.VehicleLabel = vVehicleDescriptor.GetLabel()
+					t.Vehicle.LicensePlate = vVehicleDescriptor.GetLicensePlate()
+				}
+
+			}
+			*/
+			// A trip update without a trip descriptor can't be tied to a route or trip.
+			if t.Route != nil {
 				output.Trips[t.Route.ShortName] = t
 			}
+			if t.TripId != "" {
+				output.TripUpdates[t.TripId] = t
+			}
 		}
 	}
 
 	if input.Vehicles {
-		c.log.Debug().
-			Str("url", c.VehiclesUrl).
-			Str("function", "pkg/bus.Fetch()").
-			Msg("getting vehicle data")
-		if vehicles, err := c.getData(c.VehiclesUrl); err != nil {
-			return nil, err
-		} else {
-			output.Vehicles = make(map[string]map[string]*Vehicle, len(vehicles))
-			for _, vehicle := range vehicles {
-				v := &Vehicle{}
-				v.Raw = vehicle
-				v.Id = vehicle.GetId()
-				v.Deleted = vehicle.GetIsDeleted()
-
-				vehicle.GetAlert()
-
-				vehiclePosition := vehicle.GetVehicle()
-				if vehiclePosition != nil {
-					v.CongestionLevel = vehiclePosition.GetCongestionLevel().String()
-					v.StopStatus = vehiclePosition.GetCurrentStatus().String()
-					v.CurrentStopSequence = vehiclePosition.GetCurrentStopSequence()
-					v.OccupancyStatus = vehiclePosition.GetOccupancyStatus().String()
-					v.Timestamp = time.Unix(int64(vehiclePosition.GetTimestamp()), 0)
-
-					position := vehiclePosition.GetPosition()
-					if position != nil {
-						v.Bearing = position.GetBearing()
-						v.Latitude = position.GetLatitude()
-						v.Longitude = position.GetLongitude()
-						v.Odometer = position.GetOdometer()
-						v.Speed = position.GetSpeed()
-						if v.Latitude != 0 && v.Longitude != 0 {
-							v.Geohash = geohash.Encode(float64(v.Latitude), float64(v.Longitude))
-						}
+		output.Vehicles = make(map[string]map[string]*Vehicle, len(vehicles))
+		output.UnmatchedVehicles = make(map[string]*Vehicle)
+		for _, vehicle := range vehicles {
+			v := &Vehicle{}
+			v.Raw = vehicle
+			v.Id = vehicle.GetId()
+			v.Deleted = vehicle.GetIsDeleted()
+
+			vehicle.GetAlert()
+
+			vehiclePosition := vehicle.GetVehicle()
+			if vehiclePosition != nil {
+				v.CongestionLevel = vehiclePosition.GetCongestionLevel().String()
+				v.StopStatus = vehiclePosition.GetCurrentStatus().String()
+				v.CurrentStopSequence = vehiclePosition.GetCurrentStopSequence()
+				v.OccupancyStatus = vehiclePosition.GetOccupancyStatus().String()
+				v.Timestamp = time.Unix(int64(vehiclePosition.GetTimestamp()), 0)
+
+				position := vehiclePosition.GetPosition()
+				if position != nil {
+					v.Bearing = position.GetBearing()
+					v.Latitude = position.GetLatitude()
+					v.Longitude = position.GetLongitude()
+					v.Odometer = position.GetOdometer()
+					v.Speed = position.GetSpeed()
+					if v.Latitude != 0 && v.Longitude != 0 {
+						v.Geohash = geohash.Encode(float64(v.Latitude), float64(v.Longitude))
 					}
+				}
 
-					trip := vehiclePosition.GetTrip()
-					if trip != nil {
-						v.DirectionId = trip.GetDirectionId()
-						v.RouteId, _ = strconv.Atoi(trip.GetRouteId())
-						v.TripId, _ = strconv.Atoi(trip.GetTripId())
-						if v.Route, err = c.db.GetRoute(v.RouteId); err != nil {
-							return nil, err
-						}
-						if v.Trip, err = c.db.GetTrip(v.TripId, v.RouteId); err != nil {
-							return nil, err
-						}
-
-						if v.Agency, err = c.db.GetAgency(v.Route.AgencyId); err != nil {
-							return nil, err
+				trip := vehiclePosition.GetTrip()
+				if trip != nil {
+					v.DirectionId = trip.GetDirectionId()
+					v.RouteId = gtfspec.Namespaced(c.namespace, trip.GetRouteId())
+					v.TripId = gtfspec.Namespaced(c.namespace, trip.GetTripId())
+					v.Route, v.Trip = c.match(static, v.Id, v.TripId, v.RouteId)
+					if v.Route != nil {
+						if v.Agency, err = static.agency(v.Route.AgencyId); err != nil {
+							c.log.Warn().
+								Str("entity_id", v.Id).
+								Str("function", "pkg/bus.Fetch()").
+								Err(err).
+								Msg("realtime entity doesn't match the static feed")
 						}
-
-						v.ScheduleRelationship = trip.GetScheduleRelationship().String()
-						v.StartDate = trip.GetStartDate()
-						v.StartTime = trip.GetStartTime()
-
-						v.TripStartDate, _ = time.Parse("20060102", vehicle.Vehicle.GetTrip().GetStartDate())
 					}
 
-					vehicleDescriptor := vehiclePosition.GetVehicle()
-					if vehicleDescriptor != nil {
-						v.VehicleId = vehicleDescriptor.GetId()
-						v.VehicleLabel = vehicleDescriptor.GetLabel()
-						v.LicensePlate = vehicleDescriptor.GetLicensePlate()
+					v.ScheduleRelationship = trip.GetScheduleRelationship().String()
+					v.StartDate = trip.GetStartDate()
+					v.StartTime = trip.GetStartTime()
+
+					v.TripStartDate, _ = time.Parse("20060102", vehicle.Vehicle.GetTrip().GetStartDate())
+					if v.Trip != nil {
+						v.Instance = c.instance(v.TripId, v.StartDate, v.StartTime, v.Timestamp)
 					}
+				}
 
+				vehicleDescriptor := vehiclePosition.GetVehicle()
+				if vehicleDescriptor != nil {
+					v.VehicleId = vehicleDescriptor.GetId()
+					v.VehicleLabel = vehicleDescriptor.GetLabel()
+					v.LicensePlate = vehicleDescriptor.GetLicensePlate()
 				}
 
-				/* Trip info isn't provided
-				tripUpdate := vehicle.GetTripUpdate()
-				if tripUpdate != nil {
-					tripUpdate.GetDelay()
-					time.Unix(int64(tripUpdate.GetTimestamp()), 0)
+			}
 
-					tripUpdate.GetTrip()
-					tripUpdate.GetVehicle()
-					tripUpdate.GetStopTimeUpdate()
-				}
-				*/
+			/* Trip info isn't provided
+			tripUpdate := vehicle.GetTripUpdate()
+			if tripUpdate != nil {
+				tripUpdate.GetDelay()
+				time.Unix(int64(tripUpdate.GetTimestamp()), 0)
 
-				if _, ok := output.Vehicles[v.Route.ShortName]; !ok {
-					output.Vehicles[v.Route.ShortName] = make(map[string]*Vehicle)
+				tripUpdate.GetTrip()
+				tripUpdate.GetVehicle()
+				tripUpdate.GetStopTimeUpdate()
+			}
+			*/
+
+			if v.Route == nil {
+				key := v.VehicleId
+				if key == "" {
+					key = v.Id
 				}
-				output.Vehicles[v.Route.ShortName][v.Id] = v
+				output.UnmatchedVehicles[key] = v
+				continue
+			}
+			if _, ok := output.Vehicles[v.Route.ShortName]; !ok {
+				output.Vehicles[v.Route.ShortName] = make(map[string]*Vehicle)
 			}
+			output.Vehicles[v.Route.ShortName][v.Id] = v
 		}
 	}
 	return output, nil
 }
 
+// match looks up the route and trip a realtime entity names. Ids missing
+// from the static feed are logged and left nil rather than failing the fetch:
+// ADDED trips and feeds published ahead of the schedule are normal.
+func (c *Bus) match(static *staticData, entityId string, tripId string, routeId string) (*gtfspec.Route, *gtfspec.Trip) {
+	route, err := static.route(routeId)
+	if err != nil {
+		c.log.Warn().
+			Str("entity_id", entityId).
+			Str("function", "pkg/bus.match()").
+			Err(err).
+			Msg("realtime entity doesn't match the static feed")
+	}
+	trip, err := static.trip(tripId, routeId)
+	if err != nil {
+		c.log.Warn().
+			Str("entity_id", entityId).
+			Str("function", "pkg/bus.match()").
+			Err(err).
+			Msg("realtime entity doesn't match the static feed")
+	}
+	return route, trip
+}
+
+// instance resolves the trip instance a realtime entity refers to, or nil if it can't be matched
+func (c *Bus) instance(tripId string, startDate string, startTime string, observed time.Time) *schedule.TripInstance {
+	if c.schedule == nil {
+		return nil
+	}
+	if observed.Unix() <= 0 {
+		observed = time.Now()
+	}
+	instance, err := c.schedule.Instance(&schedule.InstanceInput{
+		TripId:    tripId,
+		StartDate: startDate,
+		StartTime: startTime,
+		Observed:  observed,
+	})
+	if err != nil {
+		c.log.Warn().
+			Str("trip_id", tripId).
+			Str("start_date", startDate).
+			Str("function", "pkg/bus.instance()").
+			Err(err).
+			Msg("unable to resolve trip instance")
+		return nil
+	}
+	return instance
+}
+
+// staticData holds the GTFS records referenced by one fetch of realtime data
+type staticData struct {
+	agencies map[string]*gtfspec.Agency
+	routes   map[string]*gtfspec.Route
+	stops    map[string]*gtfspec.Stop
+	trips    map[string]*gtfspec.Trip
+}
+
+// route returns a route referenced by the realtime data
+func (s *staticData) route(routeId string) (*gtfspec.Route, error) {
+	route, ok := s.routes[routeId]
+	if !ok {
+		return nil, &ErrUnknownRoute{RouteId: routeId}
+	}
+	return route, nil
+}
+
+// trip returns a trip referenced by the realtime data, which must belong to the given route
+func (s *staticData) trip(tripId string, routeId string) (*gtfspec.Trip, error) {
+	trip, ok := s.trips[tripId]
+	if !ok || trip.RouteId != routeId {
+		return nil, &ErrUnknownTrip{TripId: tripId, RouteId: routeId}
+	}
+	return trip, nil
+}
+
+// agency returns the agency operating a route
+func (s *staticData) agency(agencyId string) (*gtfspec.Agency, error) {
+	agency, ok := s.agencies[agencyId]
+	if !ok {
+		return nil, &ErrUnknownAgency{AgencyId: agencyId}
+	}
+	return agency, nil
+}
+
+// loadStatic reads the routes, agencies, trips and stops the realtime
+// entities refer to in a handful of queries, rather than one per entity.
+// The trips' stop times are handed to the schedule so resolving their
+// instances needs no further queries.
+func (c *Bus) loadStatic(entities ...[]*gtfsrt.FeedEntity) (*staticData, error) {
+	tripIds := make([]string, 0)
+	stopIds := make([]string, 0)
+	seenTrips := make(map[string]bool)
+	seenStops := make(map[string]bool)
+	addTrip := func(trip *gtfsrt.TripDescriptor) {
+		if trip == nil {
+			return
+		}
+		tripId := gtfspec.Namespaced(c.namespace, trip.GetTripId())
+		if !seenTrips[tripId] {
+			seenTrips[tripId] = true
+			tripIds = append(tripIds, tripId)
+		}
+	}
+	for _, list := range entities {
+		for _, entity := range list {
+			if tripUpdate := entity.GetTripUpdate(); tripUpdate != nil {
+				addTrip(tripUpdate.GetTrip())
+				for _, stopTimeUpdate := range tripUpdate.GetStopTimeUpdate() {
+					stopId := gtfspec.Namespaced(c.namespace, stopTimeUpdate.GetStopId())
+					if !seenStops[stopId] {
+						seenStops[stopId] = true
+						stopIds = append(stopIds, stopId)
+					}
+				}
+			}
+			if vehiclePosition := entity.GetVehicle(); vehiclePosition != nil {
+				addTrip(vehiclePosition.GetTrip())
+			}
+		}
+	}
+
+	c.log.Debug().
+		Int("trips", len(tripIds)).
+		Int("stops", len(stopIds)).
+		Str("function", "pkg/bus.loadStatic()").
+		Msg("loading static data")
+
+	static := &staticData{
+		agencies: make(map[string]*gtfspec.Agency),
+		routes:   make(map[string]*gtfspec.Route),
+		stops:    make(map[string]*gtfspec.Stop),
+		trips:    make(map[string]*gtfspec.Trip),
+	}
+	if len(tripIds) == 0 && len(stopIds) == 0 {
+		return static, nil
+	}
+
+	agencies, err := c.db.GetAgencies()
+	if err != nil {
+		return nil, err
+	}
+	for _, agency := range agencies {
+		static.agencies[agency.AgencyId] = agency
+	}
+
+	routes, err := c.db.GetRoutes()
+	if err != nil {
+		return nil, err
+	}
+	for _, route := range routes {
+		static.routes[route.RouteId] = route
+	}
+
+	stops, err := c.db.GetStops(stopIds)
+	if err != nil {
+		return nil, err
+	}
+	for _, stop := range stops {
+		static.stops[stop.StopId] = stop
+	}
+
+	trips, err := c.db.GetTrips(tripIds)
+	if err != nil {
+		return nil, err
+	}
+	for _, trip := range trips {
+		static.trips[trip.TripID] = trip
+	}
+
+	if c.schedule != nil {
+		stopTimes, err := c.db.GetStopTimesForTrips(tripIds)
+		if err != nil {
+			return nil, err
+		}
+		c.schedule.Preload(trips, stopTimes)
+	}
+
+	return static, nil
+}
+
+// predict returns the predicted time of a stop time event and its deviation
+// from the scheduled time. Events that carry only a delay are flagged and
+// their predicted time is derived from the schedule.
+func predict(event *gtfsrt.TripUpdate_StopTimeEvent, scheduled time.Time) (time.Time, time.Duration, bool) {
+	if event.Time != nil && event.GetTime() > 0 {
+		predicted := time.Unix(event.GetTime(), 0)
+		if scheduled.IsZero() {
+			return predicted, 0, false
+		}
+		return predicted, predicted.Sub(scheduled), false
+	}
+
+	if event.Delay == nil {
+		return time.Time{}, 0, false
+	}
+	deviation := time.Duration(event.GetDelay()) * time.Second
+	if scheduled.IsZero() {
+		return time.Time{}, deviation, true
+	}
+	return scheduled.Add(deviation), deviation, true
+}
+
 // getData gets the current bus data from the API.
 func (c *Bus) getData(url string) ([]*gtfsrt.FeedEntity, error) {
 	c.log.Debug().
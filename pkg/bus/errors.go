@@ -33,3 +33,70 @@ func (e *ErrSpecsNotSet) Error() string {
 	}
 	return e.Msg
 }
+
+// ErrUnknownRoute is an error type for when realtime data names a route that isn't in the database.
+type ErrUnknownRoute struct {
+	Err     error
+	RouteId string
+	Msg     string
+}
+
+// Error returns the error message.
+func (e *ErrUnknownRoute) Error() string {
+	if e.Msg == "" {
+		e.Msg = "unknown route"
+	}
+	if e.RouteId != "" {
+		e.Msg += ": " + e.RouteId
+	}
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
+
+// ErrUnknownTrip is an error type for when realtime data names a trip that isn't in the database.
+type ErrUnknownTrip struct {
+	Err     error
+	TripId  string
+	RouteId string
+	Msg     string
+}
+
+// Error returns the error message.
+func (e *ErrUnknownTrip) Error() string {
+	if e.Msg == "" {
+		e.Msg = "unknown trip"
+	}
+	if e.TripId != "" {
+		e.Msg += ": " + e.TripId
+	}
+	if e.RouteId != "" {
+		e.Msg += ": route " + e.RouteId
+	}
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
+
+// ErrUnknownAgency is an error type for when a route names an agency that isn't in the database.
+type ErrUnknownAgency struct {
+	Err      error
+	AgencyId string
+	Msg      string
+}
+
+// Error returns the error message.
+func (e *ErrUnknownAgency) Error() string {
+	if e.Msg == "" {
+		e.Msg = "unknown agency"
+	}
+	if e.AgencyId != "" {
+		e.Msg += ": " + e.AgencyId
+	}
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
@@ -0,0 +1,125 @@
+package bus
+
+import (
+	"time"
+
+	"github.com/rmrfslashbin/gomarta/pkg/gtfspec"
+	"github.com/rmrfslashbin/gomarta/pkg/gtfsrt"
+)
+
+// PredictionSource tells where a predicted stop time came from
+type PredictionSource string
+
+const (
+	// SourcePredicted is a time reported by the feed for the stop
+	SourcePredicted PredictionSource = "predicted"
+
+	// SourcePropagated is the scheduled time shifted by the delay of an earlier stop
+	SourcePropagated PredictionSource = "propagated"
+)
+
+// Prediction is the expected arrival and departure of a trip at a stop.
+// Arrival or Departure is zero if neither the feed nor the schedule has a time for it.
+type Prediction struct {
+	StopTime  *gtfspec.StopTime
+	Arrival   time.Time
+	Departure time.Time
+
+	// Delay is the deviation from the schedule carried to the next stop
+	Delay  time.Duration
+	Source PredictionSource
+}
+
+// normalize fills a prediction for every stop of the trip from its first
+// StopTimeUpdate onwards. Per the GTFS-RT spec, the delay of an update
+// applies to later stops until the next update; SKIPPED stops get no
+// prediction and NO_DATA stops end propagation until the next update.
+func (t *Trip) normalize() {
+	if t.Instance == nil {
+		return
+	}
+
+	updates := make(map[*gtfspec.StopTime]*StopTimeUpdate, len(t.StopTimeUpdate))
+	for _, stu := range t.StopTimeUpdate {
+		if stu.Scheduled != nil {
+			updates[stu.Scheduled] = stu
+		}
+	}
+
+	t.Predictions = make([]*Prediction, 0, len(t.Instance.StopTimes))
+
+	var delay time.Duration
+	propagating := false
+
+	for _, scheduled := range t.Instance.StopTimes {
+		stu, ok := updates[scheduled.StopTime]
+		if !ok {
+			if !propagating {
+				continue
+			}
+			prediction := &Prediction{
+				StopTime: scheduled.StopTime,
+				Delay:    delay,
+				Source:   SourcePropagated,
+			}
+			if !scheduled.Arrival.IsZero() {
+				prediction.Arrival = scheduled.Arrival.Add(delay)
+			}
+			if !scheduled.Departure.IsZero() {
+				prediction.Departure = scheduled.Departure.Add(delay)
+			}
+			if !prediction.Arrival.IsZero() || !prediction.Departure.IsZero() {
+				t.Predictions = append(t.Predictions, prediction)
+			}
+			continue
+		}
+
+		switch stu.ScheduleRelationship {
+		case gtfsrt.TripUpdate_StopTimeUpdate_SKIPPED.String():
+			continue
+		case gtfsrt.TripUpdate_StopTimeUpdate_NO_DATA.String():
+			propagating = false
+			continue
+		}
+
+		prediction := &Prediction{
+			StopTime: scheduled.StopTime,
+			Source:   SourcePredicted,
+		}
+
+		// An arrival without a departure carries its delay to the departure, and vice versa.
+		arrivalKnown := stu.Arrival != nil && !stu.Arrival.Predicted.IsZero()
+		departureKnown := stu.Departure != nil && !stu.Departure.Predicted.IsZero()
+
+		if arrivalKnown {
+			prediction.Arrival = stu.Arrival.Predicted
+			if !stu.Arrival.Scheduled.IsZero() {
+				delay = stu.Arrival.Deviation
+				propagating = true
+			}
+		}
+		if departureKnown {
+			prediction.Departure = stu.Departure.Predicted
+			if !stu.Departure.Scheduled.IsZero() {
+				delay = stu.Departure.Deviation
+				propagating = true
+			}
+		}
+		if !arrivalKnown && propagating && !scheduled.Arrival.IsZero() {
+			prediction.Arrival = scheduled.Arrival.Add(delay)
+		}
+		if !departureKnown && propagating && !scheduled.Departure.IsZero() {
+			prediction.Departure = scheduled.Departure.Add(delay)
+		}
+
+		// A bus can't leave before it arrives.
+		if !prediction.Arrival.IsZero() && prediction.Departure.Before(prediction.Arrival) {
+			prediction.Departure = prediction.Arrival
+		}
+
+		prediction.Delay = delay
+		if !prediction.Arrival.IsZero() || !prediction.Departure.IsZero() {
+			t.Predictions = append(t.Predictions, prediction)
+		}
+	}
+}
@@ -5,6 +5,7 @@ import (
 
 	"github.com/rmrfslashbin/gomarta/pkg/gtfspec"
 	"github.com/rmrfslashbin/gomarta/pkg/gtfsrt"
+	"github.com/rmrfslashbin/gomarta/pkg/schedule"
 )
 
 // Arrival is a struct for arrival data
@@ -12,6 +13,15 @@ type Arrival struct {
 	Delay       int32
 	Time        time.Time
 	Uncertainty int32
+
+	// Scheduled is the arrival time from the static schedule, zero if it can't be matched
+	Scheduled time.Time
+	// Predicted is the feed's time, or the scheduled time plus the delay for delay-only updates
+	Predicted time.Time
+	// Deviation is how far the prediction is from the schedule; positive is late
+	Deviation time.Duration
+	// DelayOnly is set when the feed reports a delay without an absolute time
+	DelayOnly bool
 }
 
 // Departure is a struct for departure data
@@ -19,6 +29,15 @@ type Departure struct {
 	Delay       int32
 	Time        time.Time
 	Uncertainty int32
+
+	// Scheduled is the departure time from the static schedule, zero if it can't be matched
+	Scheduled time.Time
+	// Predicted is the feed's time, or the scheduled time plus the delay for delay-only updates
+	Predicted time.Time
+	// Deviation is how far the prediction is from the schedule; positive is late
+	Deviation time.Duration
+	// DelayOnly is set when the feed reports a delay without an absolute time
+	DelayOnly bool
 }
 
 // FetchOutput is the output for the Fetch method
@@ -26,15 +45,28 @@ type FetchOutput struct {
 	// Trips is a map of route "short names" (ie: bus line; ex: "37") to a map of Trip struct
 	Trips    map[string]*Trip
 	Vehicles map[string]map[string]*Vehicle
+
+	// UnmatchedVehicles holds the vehicles that can't be tied to a route,
+	// keyed by vehicle id (the entity id when the vehicle has none)
+	UnmatchedVehicles map[string]*Vehicle
+
+	// TripUpdates holds every trip update keyed by trip_id
+	TripUpdates map[string]*Trip
 }
 
 // StopTimeUpdate is a struct for stop time update data
 type StopTimeUpdate struct {
 	StopSequence uint32
-	StopId       int
+	StopId       string
 	Arrival      *Arrival
 	Departure    *Departure
 	Stop         *gtfspec.Stop
+
+	// ScheduleRelationship is SCHEDULED, SKIPPED or NO_DATA
+	ScheduleRelationship string
+
+	// Scheduled is the matching stop time of the static schedule, or nil if it can't be matched
+	Scheduled *gtfspec.StopTime
 }
 
 // Trip is a struct for trip data
@@ -51,19 +83,34 @@ type Trip struct {
 
 	StopTimeUpdate []*StopTimeUpdate
 
+	// Predictions holds a predicted stop time for every remaining stop of the
+	// trip, filled from StopTimeUpdate and the static schedule
+	Predictions []*Prediction
+
 	DirectionId uint32
-	RouteId     int
-	TripId      int
+	RouteId     string
+	TripId      string
 	StartTime   string
 	StartDate   string
 
+	// ScheduleRelationship is SCHEDULED, ADDED, UNSCHEDULED or CANCELED
+	ScheduleRelationship string
+
 	Trip  *gtfspec.Trip
 	Route *gtfspec.Route
 
+	// Instance is the run of the trip this update refers to, or nil if it can't be resolved
+	Instance *schedule.TripInstance
+
 	// Vehicle info isn't currently used
 	//Vehicle *Vehicle
 }
 
+// Canceled reports whether the trip update cancels the trip
+func (t *Trip) Canceled() bool {
+	return t.ScheduleRelationship == gtfsrt.TripDescriptor_CANCELED.String()
+}
+
 // Vehicle is a struct for vehicle data
 type Vehicle struct {
 	// Raw is the raw GTFS-RT data
@@ -90,8 +137,8 @@ type Vehicle struct {
 	LicensePlate string
 	Odometer     float64
 
-	TripId        int
-	RouteId       int
+	TripId        string
+	RouteId       string
 	DirectionId   uint32
 	TripStartDate time.Time
 
@@ -106,4 +153,7 @@ type Vehicle struct {
 	Agency *gtfspec.Agency
 	Route  *gtfspec.Route
 	Trip   *gtfspec.Trip
+
+	// Instance is the run of the trip the vehicle is serving, or nil if it can't be resolved
+	Instance *schedule.TripInstance
 }
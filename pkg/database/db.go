@@ -5,14 +5,19 @@ import (
 	"path/filepath"
 
 	"github.com/rmrfslashbin/gomarta/pkg/gtfspec"
+	"github.com/rmrfslashbin/gomarta/pkg/store"
 	"github.com/rs/zerolog"
 	"gorm.io/driver/mysql"
 	"gorm.io/driver/postgres"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
 	"gorm.io/gorm/logger"
 )
 
+// Database implements store.Store
+var _ store.Store = (*Database)(nil)
+
 // Options for the bus instance
 type Option func(c *Database)
 
@@ -23,6 +28,9 @@ type Database struct {
 	mysql  *string
 	pgsql  *string
 	db     *gorm.DB
+
+	// skipSchemaCheck opens the database without verifying its schema version
+	skipSchemaCheck bool
 }
 
 // New creates a new mastoclinet instance
@@ -69,17 +77,12 @@ func New(opts ...Option) (*Database, error) {
 		return nil, &ErrNoDatabase{}
 	}
 
-	if err := cfg.db.AutoMigrate(
-		&gtfspec.Agency{},
-		&gtfspec.Calendar{},
-		&gtfspec.CalendarDate{},
-		&gtfspec.Route{},
-		&gtfspec.Shape{},
-		&gtfspec.Stop{},
-		&gtfspec.StopTime{},
-		&gtfspec.Trip{},
-	); err != nil {
-		return nil, err
+	// The schema is only changed by Migrate; other commands refuse to run
+	// against a database that has not been migrated to this build.
+	if !cfg.skipSchemaCheck {
+		if err := cfg.checkSchema(); err != nil {
+			return nil, err
+		}
 	}
 
 	return cfg, nil
@@ -92,6 +95,14 @@ func WithLogger(log *zerolog.Logger) Option {
 	}
 }
 
+// WithoutSchemaCheck opens the database without verifying the schema
+// version, so it can be inspected or migrated
+func WithoutSchemaCheck() Option {
+	return func(c *Database) {
+		c.skipSchemaCheck = true
+	}
+}
+
 // WithSqlite sets the sqlite connection string for the database instance
 func WithSqlite(sqlite *string) Option {
 	return func(c *Database) {
@@ -113,12 +124,178 @@ func WithPgsql(pgsql *string) Option {
 	}
 }
 
-func (d *Database) Create(value interface{}) (*gorm.DB, error) {
-	tx := d.db.CreateInBatches(value, 100)
-	if tx.Error != nil {
-		return nil, tx.Error
+// SaveFeed inserts every record of a feed in one transaction, replacing any
+// row with the same primary key
+func (d *Database) SaveFeed(feed *gtfspec.Feed) error {
+	tables := []struct {
+		structure string
+		rows      interface{}
+		count     int
+	}{
+		{"Agencies", feed.Agencies, len(feed.Agencies)},
+		{"Areas", feed.Areas, len(feed.Areas)},
+		{"Calendars", feed.Calendars, len(feed.Calendars)},
+		{"CalendarDates", feed.CalendarDates, len(feed.CalendarDates)},
+		{"FareAttributes", feed.FareAttributes, len(feed.FareAttributes)},
+		{"FareLegRules", feed.FareLegRules, len(feed.FareLegRules)},
+		{"FareMedia", feed.FareMedia, len(feed.FareMedia)},
+		{"FareProducts", feed.FareProducts, len(feed.FareProducts)},
+		{"FareRules", feed.FareRules, len(feed.FareRules)},
+		{"FareTransferRules", feed.FareTransferRules, len(feed.FareTransferRules)},
+		{"FeedInfo", feed.FeedInfo, len(feed.FeedInfo)},
+		{"Frequencies", feed.Frequencies, len(feed.Frequencies)},
+		{"Levels", feed.Levels, len(feed.Levels)},
+		{"Networks", feed.Networks, len(feed.Networks)},
+		{"Pathways", feed.Pathways, len(feed.Pathways)},
+		{"RouteNetworks", feed.RouteNetworks, len(feed.RouteNetworks)},
+		{"Routes", feed.Routes, len(feed.Routes)},
+		{"Shapes", feed.Shapes, len(feed.Shapes)},
+		{"StopAreas", feed.StopAreas, len(feed.StopAreas)},
+		{"Stops", feed.Stops, len(feed.Stops)},
+		{"StopTimes", feed.StopTimes, len(feed.StopTimes)},
+		{"Transfers", feed.Transfers, len(feed.Transfers)},
+		{"Trips", feed.Trips, len(feed.Trips)},
 	}
-	return tx, nil
+
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		for _, table := range tables {
+			if table.count == 0 {
+				continue
+			}
+			d.log.Debug().
+				Str("table", table.structure).
+				Int("rows", table.count).
+				Str("function", "pkg/database.SaveFeed()").
+				Msg("saving table")
+			if err := tx.Clauses(clause.OnConflict{UpdateAll: true}).CreateInBatches(table.rows, 100).Error; err != nil {
+				return &ErrSavingData{Err: err, Structure: table.structure}
+			}
+		}
+		return nil
+	})
+}
+
+// findAll loads every row of a table
+func findAll[T any](db *gorm.DB) ([]*T, error) {
+	rows := make([]*T, 0)
+	if err := db.Find(&rows).Error; err != nil {
+		return nil, err
+	}
+	return rows, nil
+}
+
+// GetAgencies returns every agency
+func (d *Database) GetAgencies() ([]*gtfspec.Agency, error) {
+	return findAll[gtfspec.Agency](d.db)
+}
+
+// GetCalendars returns every weekly service calendar
+func (d *Database) GetCalendars() ([]*gtfspec.Calendar, error) {
+	return findAll[gtfspec.Calendar](d.db)
+}
+
+// GetCalendarDates returns every service exception
+func (d *Database) GetCalendarDates() ([]*gtfspec.CalendarDate, error) {
+	return findAll[gtfspec.CalendarDate](d.db)
+}
+
+// GetFareAttributes returns every fares v1 fare
+func (d *Database) GetFareAttributes() ([]*gtfspec.FareAttribute, error) {
+	return findAll[gtfspec.FareAttribute](d.db)
+}
+
+// GetFareLegRules returns every fares v2 leg rule
+func (d *Database) GetFareLegRules() ([]*gtfspec.FareLegRule, error) {
+	return findAll[gtfspec.FareLegRule](d.db)
+}
+
+// GetFareProducts returns every fares v2 product
+func (d *Database) GetFareProducts() ([]*gtfspec.FareProduct, error) {
+	return findAll[gtfspec.FareProduct](d.db)
+}
+
+// GetFareRules returns every fares v1 rule
+func (d *Database) GetFareRules() ([]*gtfspec.FareRule, error) {
+	return findAll[gtfspec.FareRule](d.db)
+}
+
+// GetFareTransferRules returns every fares v2 transfer rule
+func (d *Database) GetFareTransferRules() ([]*gtfspec.FareTransferRule, error) {
+	return findAll[gtfspec.FareTransferRule](d.db)
+}
+
+// GetLevels returns every station level
+func (d *Database) GetLevels() ([]*gtfspec.Level, error) {
+	return findAll[gtfspec.Level](d.db)
+}
+
+// GetAllPathways returns every pathway
+func (d *Database) GetAllPathways() ([]*gtfspec.Pathway, error) {
+	return findAll[gtfspec.Pathway](d.db)
+}
+
+// GetRouteNetworks returns every route to network assignment
+func (d *Database) GetRouteNetworks() ([]*gtfspec.RouteNetwork, error) {
+	return findAll[gtfspec.RouteNetwork](d.db)
+}
+
+// GetRoutes returns every route
+func (d *Database) GetRoutes() ([]*gtfspec.Route, error) {
+	return findAll[gtfspec.Route](d.db)
+}
+
+// GetStopAreas returns every stop to area assignment
+func (d *Database) GetStopAreas() ([]*gtfspec.StopArea, error) {
+	return findAll[gtfspec.StopArea](d.db)
+}
+
+// GetAllStops returns every stop
+func (d *Database) GetAllStops() ([]*gtfspec.Stop, error) {
+	return findAll[gtfspec.Stop](d.db)
+}
+
+// GetWalkingTransfers returns every generated walking transfer
+func (d *Database) GetWalkingTransfers() ([]*gtfspec.WalkingTransfer, error) {
+	return findAll[gtfspec.WalkingTransfer](d.db)
+}
+
+// LoadFeed reads every GTFS table into a Feed
+func (d *Database) LoadFeed() (*gtfspec.Feed, error) {
+	feed := &gtfspec.Feed{}
+
+	tables := []interface{}{
+		&feed.Agencies,
+		&feed.Areas,
+		&feed.Calendars,
+		&feed.CalendarDates,
+		&feed.FareAttributes,
+		&feed.FareLegRules,
+		&feed.FareMedia,
+		&feed.FareProducts,
+		&feed.FareRules,
+		&feed.FareTransferRules,
+		&feed.FeedInfo,
+		&feed.Frequencies,
+		&feed.Levels,
+		&feed.Networks,
+		&feed.Pathways,
+		&feed.RouteNetworks,
+		&feed.Routes,
+		&feed.Shapes,
+		&feed.StopAreas,
+		&feed.Stops,
+		&feed.StopTimes,
+		&feed.Transfers,
+		&feed.Trips,
+	}
+
+	for _, table := range tables {
+		if err := d.db.Find(table).Error; err != nil {
+			return nil, err
+		}
+	}
+
+	return feed, nil
 }
 
 func (d *Database) GetAgency(agencyId string) (*gtfspec.Agency, error) {
@@ -129,7 +306,7 @@ func (d *Database) GetAgency(agencyId string) (*gtfspec.Agency, error) {
 	return agency, nil
 }
 
-func (d *Database) GetRoute(routeId int) (*gtfspec.Route, error) {
+func (d *Database) GetRoute(routeId string) (*gtfspec.Route, error) {
 	route := &gtfspec.Route{}
 	if err := d.db.First(route, "route_id = ?", routeId).Error; err != nil {
 		return nil, err
@@ -137,7 +314,16 @@ func (d *Database) GetRoute(routeId int) (*gtfspec.Route, error) {
 	return route, nil
 }
 
-func (d *Database) GetStop(stopId int) (*gtfspec.Stop, error) {
+// GetRouteByShortName returns the route with a route_short_name (ex: "37")
+func (d *Database) GetRouteByShortName(shortName string) (*gtfspec.Route, error) {
+	route := &gtfspec.Route{}
+	if err := d.db.First(route, "short_name = ?", shortName).Error; err != nil {
+		return nil, err
+	}
+	return route, nil
+}
+
+func (d *Database) GetStop(stopId string) (*gtfspec.Stop, error) {
 	stop := &gtfspec.Stop{}
 	if err := d.db.First(stop, "stop_id = ?", stopId).Error; err != nil {
 		return nil, err
@@ -145,10 +331,200 @@ func (d *Database) GetStop(stopId int) (*gtfspec.Stop, error) {
 	return stop, nil
 }
 
-func (d *Database) GetTrip(tripId int, RouteId int) (*gtfspec.Trip, error) {
+// GetChildStops returns the platforms, entrances and nodes that belong to a parent station
+// (or the boarding areas of a platform)
+func (d *Database) GetChildStops(parentStopId string) ([]*gtfspec.Stop, error) {
+	stops := make([]*gtfspec.Stop, 0)
+	if err := d.db.Where("parent_station = ?", parentStopId).Find(&stops).Error; err != nil {
+		return nil, err
+	}
+	return stops, nil
+}
+
+// GetLevel returns a station level
+func (d *Database) GetLevel(levelId string) (*gtfspec.Level, error) {
+	level := &gtfspec.Level{}
+	if err := d.db.First(level, "level_id = ?", levelId).Error; err != nil {
+		return nil, err
+	}
+	return level, nil
+}
+
+// GetPathways returns every pathway that starts or ends at one of the given stops
+func (d *Database) GetPathways(stopIds []string) ([]*gtfspec.Pathway, error) {
+	pathways := make([]*gtfspec.Pathway, 0)
+	if err := d.db.Where("from_stop_id IN ? OR to_stop_id IN ?", stopIds, stopIds).Find(&pathways).Error; err != nil {
+		return nil, err
+	}
+	return pathways, nil
+}
+
+// GetStops returns the stops with the given ids
+func (d *Database) GetStops(stopIds []string) ([]*gtfspec.Stop, error) {
+	stops := make([]*gtfspec.Stop, 0, len(stopIds))
+	for _, chunk := range chunks(stopIds) {
+		found := make([]*gtfspec.Stop, 0, len(chunk))
+		if err := d.db.Where("stop_id IN ?", chunk).Find(&found).Error; err != nil {
+			return nil, err
+		}
+		stops = append(stops, found...)
+	}
+	return stops, nil
+}
+
+func (d *Database) GetTrip(tripId string, routeId string) (*gtfspec.Trip, error) {
 	trip := &gtfspec.Trip{}
-	if err := d.db.First(trip, "trip_id = ? AND route_id = ?", tripId, RouteId).Error; err != nil {
+	if err := d.db.First(trip, "trip_id = ? AND route_id = ?", tripId, routeId).Error; err != nil {
 		return nil, err
 	}
 	return trip, nil
 }
+
+// GetFeedInfo returns the feed info record, if the feed provided one
+func (d *Database) GetFeedInfo() (*gtfspec.FeedInfo, error) {
+	feedInfo := &gtfspec.FeedInfo{}
+	if err := d.db.First(feedInfo).Error; err != nil {
+		return nil, err
+	}
+	return feedInfo, nil
+}
+
+// GetFrequencies returns the headway periods of a frequency-based trip, ordered by start time
+func (d *Database) GetFrequencies(tripId string) ([]*gtfspec.Frequency, error) {
+	frequencies := make([]*gtfspec.Frequency, 0)
+	if err := d.db.Where("trip_id = ?", tripId).Order("start_time").Find(&frequencies).Error; err != nil {
+		return nil, err
+	}
+	return frequencies, nil
+}
+
+// GetTransfer returns the transfer rule between two stops
+func (d *Database) GetTransfer(fromStopId string, toStopId string) (*gtfspec.Transfer, error) {
+	transfer := &gtfspec.Transfer{}
+	if err := d.db.First(transfer, "from_stop_id = ? AND to_stop_id = ?", fromStopId, toStopId).Error; err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// GetTransfersFrom returns every transfer rule that starts at a stop
+func (d *Database) GetTransfersFrom(fromStopId string) ([]*gtfspec.Transfer, error) {
+	transfers := make([]*gtfspec.Transfer, 0)
+	if err := d.db.Where("from_stop_id = ?", fromStopId).Find(&transfers).Error; err != nil {
+		return nil, err
+	}
+	return transfers, nil
+}
+
+// GetTransfers returns every transfer rule in the feed
+func (d *Database) GetTransfers() ([]*gtfspec.Transfer, error) {
+	return findAll[gtfspec.Transfer](d.db)
+}
+
+// GetTrips returns the trips with the given ids
+func (d *Database) GetTrips(tripIds []string) ([]*gtfspec.Trip, error) {
+	trips := make([]*gtfspec.Trip, 0, len(tripIds))
+	for _, chunk := range chunks(tripIds) {
+		found := make([]*gtfspec.Trip, 0, len(chunk))
+		if err := d.db.Where("trip_id IN ?", chunk).Find(&found).Error; err != nil {
+			return nil, err
+		}
+		trips = append(trips, found...)
+	}
+	return trips, nil
+}
+
+// GetTripsForServices returns the trips of a route that belong to any of the given services.
+// If routeId is empty, trips of every route are returned.
+func (d *Database) GetTripsForServices(routeId string, serviceIds []string) ([]*gtfspec.Trip, error) {
+	trips := make([]*gtfspec.Trip, 0)
+	tx := d.db.Where("service_id IN ?", serviceIds)
+	if routeId != "" {
+		tx = tx.Where("route_id = ?", routeId)
+	}
+	if err := tx.Find(&trips).Error; err != nil {
+		return nil, err
+	}
+	return trips, nil
+}
+
+// GetStopTimesAtStop returns the stop times at a stop that depart between from and to (inclusive),
+// ordered by departure time
+func (d *Database) GetStopTimesAtStop(stopId string, from gtfspec.GTFSTime, to gtfspec.GTFSTime) ([]*gtfspec.StopTime, error) {
+	stopTimes := make([]*gtfspec.StopTime, 0)
+	if err := d.db.
+		Where("stop_id = ? AND departure_time BETWEEN ? AND ?", stopId, from, to).
+		Order("departure_time").
+		Find(&stopTimes).Error; err != nil {
+		return nil, err
+	}
+	return stopTimes, nil
+}
+
+// GetStopTimes returns the stop times of a trip ordered by stop sequence
+func (d *Database) GetStopTimes(tripId string) ([]*gtfspec.StopTime, error) {
+	stopTimes := make([]*gtfspec.StopTime, 0)
+	if err := d.db.Where("trip_id = ?", tripId).Order("stop_sequence").Find(&stopTimes).Error; err != nil {
+		return nil, err
+	}
+	return stopTimes, nil
+}
+
+// GetStopTimesForTrips returns the stop times of several trips ordered by trip and stop sequence
+func (d *Database) GetStopTimesForTrips(tripIds []string) ([]*gtfspec.StopTime, error) {
+	stopTimes := make([]*gtfspec.StopTime, 0)
+	for _, chunk := range chunks(tripIds) {
+		found := make([]*gtfspec.StopTime, 0)
+		if err := d.db.Where("trip_id IN ?", chunk).Order("trip_id, stop_sequence").Find(&found).Error; err != nil {
+			return nil, err
+		}
+		stopTimes = append(stopTimes, found...)
+	}
+	return stopTimes, nil
+}
+
+// ReplaceWalkingTransfers replaces every generated walking transfer
+func (d *Database) ReplaceWalkingTransfers(transfers []*gtfspec.WalkingTransfer) error {
+	return d.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("1 = 1").Delete(&gtfspec.WalkingTransfer{}).Error; err != nil {
+			return err
+		}
+		if len(transfers) == 0 {
+			return nil
+		}
+		return tx.CreateInBatches(transfers, 100).Error
+	})
+}
+
+// GetWalkingTransfersFrom returns the generated walking transfers that start at a stop, nearest first
+func (d *Database) GetWalkingTransfersFrom(fromStopId string) ([]*gtfspec.WalkingTransfer, error) {
+	transfers := make([]*gtfspec.WalkingTransfer, 0)
+	if err := d.db.Where("from_stop_id = ?", fromStopId).Order("walk_time").Find(&transfers).Error; err != nil {
+		return nil, err
+	}
+	return transfers, nil
+}
+
+// GetWalkingTransfer returns the generated walking transfer between two stops
+func (d *Database) GetWalkingTransfer(fromStopId string, toStopId string) (*gtfspec.WalkingTransfer, error) {
+	transfer := &gtfspec.WalkingTransfer{}
+	if err := d.db.First(transfer, "from_stop_id = ? AND to_stop_id = ?", fromStopId, toStopId).Error; err != nil {
+		return nil, err
+	}
+	return transfer, nil
+}
+
+// chunks splits ids into slices small enough for an IN clause on every supported database
+func chunks(ids []string) [][]string {
+	const size = 500
+
+	out := make([][]string, 0, len(ids)/size+1)
+	for len(ids) > size {
+		out = append(out, ids[:size])
+		ids = ids[size:]
+	}
+	if len(ids) > 0 {
+		out = append(out, ids)
+	}
+	return out
+}
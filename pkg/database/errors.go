@@ -1,5 +1,7 @@
 package database
 
+import "strconv"
+
 // ErrMySqlOpen is returned when there is an error opening the mysql database
 type ErrMySqlOpen struct {
 	Err error
@@ -79,3 +81,86 @@ func (e *ErrSqliteOpen) Error() string {
 	}
 	return e.Msg
 }
+
+// ErrMigration is returned when a schema migration cannot be applied
+type ErrMigration struct {
+	Err     error
+	Version int
+	Msg     string
+}
+
+// Error returns the error message.
+func (e *ErrMigration) Error() string {
+	if e.Msg == "" {
+		e.Msg = "error applying migration"
+	}
+	if e.Version != 0 {
+		e.Msg += ": " + strconv.Itoa(e.Version)
+	}
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
+
+// ErrSchemaMismatch is returned when the database schema is not the version this build expects
+type ErrSchemaMismatch struct {
+	Err      error
+	Version  int
+	Expected int
+	Msg      string
+}
+
+// Error returns the error message.
+func (e *ErrSchemaMismatch) Error() string {
+	if e.Msg == "" {
+		if e.Version < e.Expected {
+			e.Msg = "database schema is out of date- run 'gomarta db migrate'"
+		} else {
+			e.Msg = "database schema is newer than this build- upgrade gomarta"
+		}
+	}
+	e.Msg += ": version " + strconv.Itoa(e.Version) + ", expected " + strconv.Itoa(e.Expected)
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
+
+// ErrSchemaVersion is returned when the schema version cannot be read
+type ErrSchemaVersion struct {
+	Err error
+	Msg string
+}
+
+// Error returns the error message.
+func (e *ErrSchemaVersion) Error() string {
+	if e.Msg == "" {
+		e.Msg = "error reading schema version"
+	}
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
+
+// ErrSavingData is returned when the rows of a table cannot be saved
+type ErrSavingData struct {
+	Err       error
+	Structure string
+	Msg       string
+}
+
+// Error returns the error message.
+func (e *ErrSavingData) Error() string {
+	if e.Msg == "" {
+		e.Msg = "error saving data"
+	}
+	if e.Structure != "" {
+		e.Msg += ": " + e.Structure
+	}
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
@@ -0,0 +1,328 @@
+package database
+
+import (
+	"strings"
+	"time"
+
+	"github.com/rmrfslashbin/gomarta/pkg/database/schemav1"
+	"github.com/rmrfslashbin/gomarta/pkg/database/schemav2"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+)
+
+// Migration is one versioned change to the database schema
+type Migration struct {
+	Version     int
+	Description string
+
+	// Steps run in order, each in its own transaction, and each is recorded
+	// as soon as it succeeds. MySQL commits DDL immediately, so a step cut
+	// short may have partly run and must be safe to run again.
+	Steps []func(tx *gorm.DB) error
+}
+
+// SchemaVersion records a migration that has been applied to the database
+type SchemaVersion struct {
+	Version     int `gorm:"primaryKey;autoIncrement:false"`
+	Description string
+	AppliedAt   time.Time
+}
+
+// TableName sets the name of the table holding the applied migrations
+func (SchemaVersion) TableName() string {
+	return "schema_version"
+}
+
+// MigrationStep records a completed step of a migration that hasn't
+// finished, so an interrupted migration resumes after its last completed step
+type MigrationStep struct {
+	Version   int `gorm:"primaryKey;autoIncrement:false"`
+	Step      int `gorm:"primaryKey;autoIncrement:false"`
+	AppliedAt time.Time
+}
+
+// TableName sets the name of the table holding the completed migration steps
+func (MigrationStep) TableName() string {
+	return "schema_version_step"
+}
+
+// MigrationStatus is a migration and when it was applied, if it has been
+type MigrationStatus struct {
+	Version     int
+	Description string
+	AppliedAt   *time.Time
+}
+
+// migrations lists every schema change in the order they are applied. Each
+// migration works from its own frozen copy of the tables so it produces the
+// same schema whichever build runs it. Applied migrations, and the order of
+// their steps, must never be edited; add a new one instead.
+var migrations = []Migration{
+	{
+		Version:     1,
+		Description: "create gtfs and walking transfer tables",
+		// AutoMigrate also brings databases created by builds that migrated
+		// on every start up to this schema, adding the tables and columns
+		// they lack.
+		Steps: []func(tx *gorm.DB) error{
+			func(tx *gorm.DB) error {
+				return tx.AutoMigrate(
+					&schemav1.Agency{},
+					&schemav1.Area{},
+					&schemav1.Calendar{},
+					&schemav1.CalendarDate{},
+					&schemav1.FareAttribute{},
+					&schemav1.FareLegRule{},
+					&schemav1.FareMedia{},
+					&schemav1.FareProduct{},
+					&schemav1.FareRule{},
+					&schemav1.FareTransferRule{},
+					&schemav1.FeedInfo{},
+					&schemav1.Frequency{},
+					&schemav1.Level{},
+					&schemav1.Network{},
+					&schemav1.Pathway{},
+					&schemav1.Route{},
+					&schemav1.RouteNetwork{},
+					&schemav1.Shape{},
+					&schemav1.Stop{},
+					&schemav1.StopArea{},
+					&schemav1.StopTime{},
+					&schemav1.Transfer{},
+					&schemav1.Trip{},
+					&schemav1.WalkingTransfer{},
+				)
+			},
+		},
+	},
+	{
+		Version:     2,
+		Description: "natural primary keys and query indexes, drop soft delete columns",
+		Steps: []func(tx *gorm.DB) error{
+			rebuildTable[schemav1.Agency, schemav2.Agency],
+			rebuildTable[schemav1.Area, schemav2.Area],
+			rebuildTable[schemav1.Calendar, schemav2.Calendar],
+			rebuildTable[schemav1.CalendarDate, schemav2.CalendarDate],
+			rebuildTable[schemav1.FareAttribute, schemav2.FareAttribute],
+			rebuildTable[schemav1.FareLegRule, schemav2.FareLegRule],
+			rebuildTable[schemav1.FareMedia, schemav2.FareMedia],
+			rebuildTable[schemav1.FareProduct, schemav2.FareProduct],
+			rebuildTable[schemav1.FareRule, schemav2.FareRule],
+			rebuildTable[schemav1.FareTransferRule, schemav2.FareTransferRule],
+			rebuildTable[schemav1.FeedInfo, schemav2.FeedInfo],
+			rebuildTable[schemav1.Frequency, schemav2.Frequency],
+			rebuildTable[schemav1.Level, schemav2.Level],
+			rebuildTable[schemav1.Network, schemav2.Network],
+			rebuildTable[schemav1.Pathway, schemav2.Pathway],
+			rebuildTable[schemav1.Route, schemav2.Route],
+			rebuildTable[schemav1.RouteNetwork, schemav2.RouteNetwork],
+			rebuildTable[schemav1.Shape, schemav2.Shape],
+			rebuildTable[schemav1.Stop, schemav2.Stop],
+			rebuildTable[schemav1.StopArea, schemav2.StopArea],
+			rebuildTable[schemav1.StopTime, schemav2.StopTime],
+			rebuildTable[schemav1.Transfer, schemav2.Transfer],
+			rebuildTable[schemav1.Trip, schemav2.Trip],
+			rebuildTable[schemav1.WalkingTransfer, schemav2.WalkingTransfer],
+		},
+	},
+}
+
+// rebuildTable recreates a table created from a model that embedded
+// gorm.Model. The keys of such tables cannot be altered in place, so the table
+// is copied to a backup, recreated from the model, and the rows that were not
+// soft deleted are written back. Where rows share a key the most recently
+// imported one is kept. The backup is only dropped once the rows are back, so
+// a rebuild that was cut short resumes from it. A missing table is created,
+// and a table already without a deleted_at column is left alone.
+func rebuildTable[V1, V2 any](tx *gorm.DB) error {
+	model := new(V2)
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(model); err != nil {
+		return err
+	}
+	backup := stmt.Schema.Table + "_v1"
+
+	migrator := tx.Migrator()
+	if migrator.HasTable(model) && migrator.HasColumn(model, "deleted_at") {
+		// The original is intact, so any backup left over is incomplete.
+		if migrator.HasTable(backup) {
+			if err := migrator.DropTable(backup); err != nil {
+				return err
+			}
+		}
+		if err := backupTable(tx, new(V1), backup); err != nil {
+			return err
+		}
+	} else if !migrator.HasTable(backup) {
+		if !migrator.HasTable(model) {
+			return migrator.CreateTable(model)
+		}
+		return nil
+	}
+
+	rows := make([]*V2, 0)
+	if err := tx.Table(backup).Where("deleted_at IS NULL").Order("id DESC").Find(&rows).Error; err != nil {
+		return err
+	}
+	if migrator.HasTable(model) {
+		if err := migrator.DropTable(model); err != nil {
+			return err
+		}
+	}
+	if err := migrator.CreateTable(model); err != nil {
+		return err
+	}
+	if len(rows) > 0 {
+		if err := tx.Clauses(clause.OnConflict{DoNothing: true}).CreateInBatches(rows, 100).Error; err != nil {
+			return err
+		}
+	}
+	return migrator.DropTable(backup)
+}
+
+// backupTable copies the table of a version 1 model to backup. The backup is
+// declared with the model's column types, which CREATE TABLE AS SELECT does
+// not keep on sqlite, but without its keys and indexes, whose names would
+// clash with those of the rebuilt table.
+func backupTable(tx *gorm.DB, model interface{}, backup string) error {
+	stmt := &gorm.Statement{DB: tx}
+	if err := stmt.Parse(model); err != nil {
+		return err
+	}
+
+	definitions := make([]string, 0, len(stmt.Schema.DBNames))
+	columns := make([]string, 0, len(stmt.Schema.DBNames))
+	args := []interface{}{clause.Table{Name: backup}}
+	for _, name := range stmt.Schema.DBNames {
+		field := *stmt.Schema.FieldsByDBName[name]
+		field.AutoIncrement = false
+		definitions = append(definitions, "? "+tx.Dialector.DataTypeOf(&field))
+		columns = append(columns, tx.Statement.Quote(name))
+		args = append(args, clause.Column{Name: name})
+	}
+	if err := tx.Exec("CREATE TABLE ? ("+strings.Join(definitions, ", ")+")", args...).Error; err != nil {
+		return err
+	}
+	list := strings.Join(columns, ", ")
+	return tx.Exec("INSERT INTO ? ("+list+") SELECT "+list+" FROM ?", clause.Table{Name: backup}, clause.Table{Name: stmt.Schema.Table}).Error
+}
+
+// LatestVersion is the schema version this build expects
+func LatestVersion() int {
+	return migrations[len(migrations)-1].Version
+}
+
+// SchemaVersion returns the version of the database schema, or 0 if no
+// migration has been applied
+func (d *Database) SchemaVersion() (int, error) {
+	if !d.db.Migrator().HasTable(&SchemaVersion{}) {
+		return 0, nil
+	}
+	var version int
+	if err := d.db.Model(&SchemaVersion{}).Select("COALESCE(MAX(version), 0)").Scan(&version).Error; err != nil {
+		return 0, &ErrSchemaVersion{Err: err}
+	}
+	return version, nil
+}
+
+// checkSchema makes sure the database schema matches this build
+func (d *Database) checkSchema() error {
+	version, err := d.SchemaVersion()
+	if err != nil {
+		return err
+	}
+	if version != LatestVersion() {
+		return &ErrSchemaMismatch{Version: version, Expected: LatestVersion()}
+	}
+	return nil
+}
+
+// MigrationStatus lists every migration known to this build and whether it
+// has been applied
+func (d *Database) MigrationStatus() ([]*MigrationStatus, error) {
+	applied := make(map[int]time.Time)
+	if d.db.Migrator().HasTable(&SchemaVersion{}) {
+		versions := make([]*SchemaVersion, 0)
+		if err := d.db.Find(&versions).Error; err != nil {
+			return nil, &ErrSchemaVersion{Err: err}
+		}
+		for _, version := range versions {
+			applied[version.Version] = version.AppliedAt
+		}
+	}
+
+	status := make([]*MigrationStatus, 0, len(migrations))
+	for _, migration := range migrations {
+		s := &MigrationStatus{Version: migration.Version, Description: migration.Description}
+		if appliedAt, ok := applied[migration.Version]; ok {
+			s.AppliedAt = &appliedAt
+		}
+		status = append(status, s)
+	}
+	return status, nil
+}
+
+// Migrate applies every pending migration in order and returns the
+// migrations that were applied. Each step is committed on its own, so a
+// migration that fails part way resumes after its last completed step.
+func (d *Database) Migrate() ([]Migration, error) {
+	if err := d.db.AutoMigrate(&SchemaVersion{}, &MigrationStep{}); err != nil {
+		return nil, &ErrMigration{Err: err}
+	}
+
+	version, err := d.SchemaVersion()
+	if err != nil {
+		return nil, err
+	}
+
+	if version > LatestVersion() {
+		return nil, &ErrSchemaMismatch{Version: version, Expected: LatestVersion()}
+	}
+
+	applied := make([]Migration, 0)
+	for _, migration := range migrations {
+		if migration.Version <= version {
+			continue
+		}
+
+		completed := make([]*MigrationStep, 0)
+		if err := d.db.Where("version = ?", migration.Version).Find(&completed).Error; err != nil {
+			return applied, &ErrMigration{Err: err, Version: migration.Version}
+		}
+		done := make(map[int]bool, len(completed))
+		for _, step := range completed {
+			done[step.Step] = true
+		}
+
+		for ndx, step := range migration.Steps {
+			if done[ndx] {
+				continue
+			}
+			if err := d.db.Transaction(func(tx *gorm.DB) error {
+				if err := step(tx); err != nil {
+					return err
+				}
+				return tx.Create(&MigrationStep{Version: migration.Version, Step: ndx, AppliedAt: time.Now()}).Error
+			}); err != nil {
+				return applied, &ErrMigration{Err: err, Version: migration.Version}
+			}
+		}
+
+		if err := d.db.Transaction(func(tx *gorm.DB) error {
+			if err := tx.Create(&SchemaVersion{Version: migration.Version, Description: migration.Description, AppliedAt: time.Now()}).Error; err != nil {
+				return err
+			}
+			return tx.Where("version = ?", migration.Version).Delete(&MigrationStep{}).Error
+		}); err != nil {
+			return applied, &ErrMigration{Err: err, Version: migration.Version}
+		}
+		d.log.Debug().
+			Int("version", migration.Version).
+			Str("description", migration.Description).
+			Str("function", "pkg/database.Migrate()").
+			Msg("applied migration")
+		applied = append(applied, migration)
+	}
+
+	return applied, nil
+}
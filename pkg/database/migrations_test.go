@@ -0,0 +1,185 @@
+package database
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/rmrfslashbin/gomarta/pkg/database/schemav1"
+	"github.com/rmrfslashbin/gomarta/pkg/gtfspec"
+	"github.com/rs/zerolog"
+	"gorm.io/gorm"
+)
+
+// v1Database creates an sqlite database at schema version 1 holding a few
+// rows, including a soft deleted stop and a trip imported twice
+func v1Database(t *testing.T) (*Database, string) {
+	t.Helper()
+
+	path := filepath.Join(t.TempDir(), "gomarta.db")
+	log := zerolog.Nop()
+	d, err := New(WithSqlite(&path), WithLogger(&log), WithoutSchemaCheck())
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	if err := d.db.AutoMigrate(&SchemaVersion{}); err != nil {
+		t.Fatalf("AutoMigrate() error = %v", err)
+	}
+	for _, step := range migrations[0].Steps {
+		if err := step(d.db); err != nil {
+			t.Fatalf("v1 step error = %v", err)
+		}
+	}
+	if err := d.db.Create(&SchemaVersion{Version: 1, Description: migrations[0].Description, AppliedAt: time.Now()}).Error; err != nil {
+		t.Fatalf("recording v1 error = %v", err)
+	}
+
+	rows := []interface{}{
+		&schemav1.Agency{AgencyId: "MARTA", Name: "MARTA", Timezone: "America/New_York"},
+		&schemav1.Calendar{
+			ServiceId: "WK", Monday: 1, Tuesday: 1, Wednesday: 1, Thursday: 1, Friday: 1,
+			StartDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			EndDate:   time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC),
+		},
+		&schemav1.Route{RouteId: "R1", AgencyId: "MARTA", ShortName: "1"},
+		&schemav1.Stop{StopId: "A", Name: "A", Lat: 33.7, Lon: -84.39},
+		&schemav1.Stop{StopId: "B", Name: "B", Lat: 33.8, Lon: -84.39},
+		&schemav1.Stop{StopId: "X", Name: "Deleted", Model: gorm.Model{DeletedAt: gorm.DeletedAt{Time: time.Now(), Valid: true}}},
+		&schemav1.Trip{RouteId: "R1", ServiceId: "WK", TripID: "T1", Headsign: "older import"},
+		&schemav1.Trip{RouteId: "R1", ServiceId: "WK", TripID: "T1", Headsign: "newer import"},
+		&schemav1.StopTime{TripId: "T1", StopId: "A", StopSequence: 1, ArrivalTime: 23 * 3600, DepartureTime: 23 * 3600},
+		&schemav1.StopTime{TripId: "T1", StopId: "B", StopSequence: 2, ArrivalTime: 25*3600 + 600, DepartureTime: gtfspec.NoTime},
+	}
+	for _, row := range rows {
+		if err := d.db.Create(row).Error; err != nil {
+			t.Fatalf("inserting %T error = %v", row, err)
+		}
+	}
+
+	return d, path
+}
+
+// checkMigrated verifies a database reached the latest schema with its data intact
+func checkMigrated(t *testing.T, d *Database, path string) {
+	t.Helper()
+
+	version, err := d.SchemaVersion()
+	if err != nil {
+		t.Fatalf("SchemaVersion() error = %v", err)
+	}
+	if version != 2 {
+		t.Errorf("SchemaVersion() = %d, want 2", version)
+	}
+
+	log := zerolog.Nop()
+	if _, err := New(WithSqlite(&path), WithLogger(&log)); err != nil {
+		t.Errorf("New() with schema check error = %v", err)
+	}
+
+	calendars, err := d.GetCalendars()
+	if err != nil {
+		t.Fatalf("GetCalendars() error = %v", err)
+	}
+	if len(calendars) != 1 || !calendars[0].StartDate.Equal(time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)) || calendars[0].Friday != 1 {
+		t.Errorf("GetCalendars() = %+v, want WK from 2024-01-01 running on Fridays", calendars)
+	}
+
+	stops, err := d.GetAllStops()
+	if err != nil {
+		t.Fatalf("GetAllStops() error = %v", err)
+	}
+	ids := make(map[string]bool)
+	for _, stop := range stops {
+		ids[stop.StopId] = true
+	}
+	if len(stops) != 2 || !ids["A"] || !ids["B"] {
+		t.Errorf("GetAllStops() = %d stops %v, want A and B without the soft deleted X", len(stops), ids)
+	}
+
+	trip, err := d.GetTrip("T1", "R1")
+	if err != nil {
+		t.Fatalf("GetTrip() error = %v", err)
+	}
+	if trip.Headsign != "newer import" {
+		t.Errorf("GetTrip() headsign = %q, want the most recent import", trip.Headsign)
+	}
+
+	stopTimes, err := d.GetStopTimesForTrips([]string{"T1"})
+	if err != nil {
+		t.Fatalf("GetStopTimesForTrips() error = %v", err)
+	}
+	if len(stopTimes) != 2 {
+		t.Fatalf("GetStopTimesForTrips() returned %d stop times, want 2", len(stopTimes))
+	}
+	if got := stopTimes[1].ArrivalTime.String(); got != "25:10:00" {
+		t.Errorf("arrival past midnight = %q, want 25:10:00", got)
+	}
+
+	for _, table := range []string{"stops_v1", "trips_v1", "calendars_v1"} {
+		if d.db.Migrator().HasTable(table) {
+			t.Errorf("backup table %s left behind", table)
+		}
+	}
+	var steps int64
+	if err := d.db.Model(&MigrationStep{}).Count(&steps).Error; err != nil {
+		t.Fatalf("counting migration steps error = %v", err)
+	}
+	if steps != 0 {
+		t.Errorf("%d migration steps left recorded, want 0", steps)
+	}
+}
+
+func TestMigrateV1ToV2(t *testing.T) {
+	d, path := v1Database(t)
+
+	applied, err := d.Migrate()
+	if err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+	if len(applied) != 1 || applied[0].Version != 2 {
+		t.Errorf("Migrate() applied %d migrations, want only version 2", len(applied))
+	}
+
+	checkMigrated(t, d, path)
+
+	applied, err = d.Migrate()
+	if err != nil {
+		t.Fatalf("second Migrate() error = %v", err)
+	}
+	if len(applied) != 0 {
+		t.Errorf("second Migrate() applied %d migrations, want 0", len(applied))
+	}
+}
+
+func TestMigrateResumesInterruptedRebuild(t *testing.T) {
+	d, path := v1Database(t)
+
+	// A rebuild cut short after dropping the original table, as MySQL leaves
+	// it since DDL is not transactional: only the backup holds the rows.
+	if err := backupTable(d.db, &schemav1.Stop{}, "stops_v1"); err != nil {
+		t.Fatalf("creating backup error = %v", err)
+	}
+	if err := d.db.Migrator().DropTable("stops"); err != nil {
+		t.Fatalf("dropping stops error = %v", err)
+	}
+
+	// Steps recorded before the interruption are not run again.
+	if err := d.db.AutoMigrate(&MigrationStep{}); err != nil {
+		t.Fatalf("AutoMigrate() error = %v", err)
+	}
+	if err := d.db.Create(&MigrationStep{Version: 2, Step: 0, AppliedAt: time.Now()}).Error; err != nil {
+		t.Fatalf("recording step error = %v", err)
+	}
+
+	if _, err := d.Migrate(); err != nil {
+		t.Fatalf("Migrate() error = %v", err)
+	}
+
+	checkMigrated(t, d, path)
+
+	// The agencies step was recorded as done, so its table was not rebuilt.
+	if !d.db.Migrator().HasColumn("agencies", "deleted_at") {
+		t.Errorf("agencies was rebuilt although its step was recorded as applied")
+	}
+}
@@ -0,0 +1,262 @@
+// Package schemav1 is a frozen copy of the tables created by migration 1,
+// taken from the gtfspec models when versioned migrations were introduced.
+// Every table embeds gorm.Model. It must never change; later schema changes
+// get a package of their own.
+package schemav1
+
+import (
+	"time"
+
+	"github.com/rmrfslashbin/gomarta/pkg/gtfspec"
+	"gorm.io/gorm"
+)
+
+type Agency struct {
+	gorm.Model
+	AgencyId string `gorm:"primaryKey"`
+	Name     string
+	Url      string
+	Timezone string
+	Lang     string
+	Phone    string
+	FareUrl  string
+}
+
+type Area struct {
+	gorm.Model
+	AreaId string `gorm:"primaryKey"`
+	Name   string
+}
+
+type Calendar struct {
+	gorm.Model
+	ServiceId string `gorm:"primaryKey"`
+	Monday    int
+	Tuesday   int
+	Wednesday int
+	Thursday  int
+	Friday    int
+	Saturday  int
+	Sunday    int
+	StartDate time.Time
+	EndDate   time.Time
+}
+
+type CalendarDate struct {
+	gorm.Model
+	ServiceId     string    `gorm:"primaryKey"`
+	Date          time.Time `gorm:"primaryKey"`
+	ExceptionType int
+}
+
+type FareAttribute struct {
+	gorm.Model
+	FareId           string `gorm:"primaryKey"`
+	Price            float64
+	CurrencyType     string
+	PaymentMethod    int
+	Transfers        int
+	AgencyId         string
+	TransferDuration int
+}
+
+type FareLegRule struct {
+	gorm.Model
+	LegGroupId           string
+	NetworkId            string
+	FromAreaId           string
+	ToAreaId             string
+	FromTimeframeGroupId string
+	ToTimeframeGroupId   string
+	FareProductId        string
+	RulePriority         int
+}
+
+type FareMedia struct {
+	gorm.Model
+	FareMediaId string `gorm:"primaryKey"`
+	Name        string
+	Type        int
+}
+
+type FareProduct struct {
+	gorm.Model
+	FareProductId string `gorm:"primaryKey"`
+	Name          string
+	FareMediaId   string `gorm:"primaryKey"`
+	Amount        float64
+	Currency      string
+}
+
+type FareRule struct {
+	gorm.Model
+	FareId        string
+	RouteId       string
+	OriginId      string
+	DestinationId string
+	ContainsId    string
+}
+
+type FareTransferRule struct {
+	gorm.Model
+	FromLegGroupId    string
+	ToLegGroupId      string
+	TransferCount     int
+	DurationLimit     int
+	DurationLimitType int
+	FareTransferType  int
+	FareProductId     string
+}
+
+type FeedInfo struct {
+	gorm.Model
+	PublisherName string
+	PublisherUrl  string
+	Lang          string
+	DefaultLang   string
+	StartDate     time.Time
+	EndDate       time.Time
+	Version       string
+	ContactEmail  string
+	ContactUrl    string
+}
+
+type Frequency struct {
+	gorm.Model
+	TripId      string           `gorm:"primaryKey"`
+	StartTime   gtfspec.GTFSTime `gorm:"primaryKey"`
+	EndTime     gtfspec.GTFSTime
+	HeadwaySecs int
+	ExactTimes  int
+}
+
+type Level struct {
+	gorm.Model
+	LevelId string `gorm:"primaryKey"`
+	Index   float64
+	Name    string
+}
+
+type Network struct {
+	gorm.Model
+	NetworkId string `gorm:"primaryKey"`
+	Name      string
+}
+
+type Pathway struct {
+	gorm.Model
+	PathwayId            string `gorm:"primaryKey"`
+	FromStopId           string
+	ToStopId             string
+	Mode                 int
+	IsBidirectional      bool
+	Length               float64
+	TraversalTime        int
+	StairCount           int
+	MaxSlope             float64
+	MinWidth             float64
+	SignpostedAs         string
+	ReversedSignpostedAs string
+}
+
+type Route struct {
+	gorm.Model
+	RouteId   string `gorm:"primaryKey"`
+	AgencyId  string
+	ShortName string
+	LongName  string
+	Desc      string
+	RouteType int
+	Url       string
+	Color     []uint8
+	TextColor []uint8
+	NetworkId string
+}
+
+type RouteNetwork struct {
+	gorm.Model
+	NetworkId string
+	RouteId   string `gorm:"primaryKey"`
+}
+
+type Shape struct {
+	gorm.Model
+	ShapeId  string `gorm:"primaryKey"`
+	Lat      float64
+	Lon      float64
+	Sequence int `gorm:"primaryKey"`
+	Distance float64
+}
+
+type Stop struct {
+	gorm.Model
+	StopId             string `gorm:"primaryKey"`
+	Code               string
+	Name               string
+	Desc               string
+	Lat                float64
+	Lon                float64
+	ZoneId             string
+	Url                string
+	LocationType       int
+	ParentStation      string
+	Timezone           string
+	WheelchairBoarding bool
+	LevelId            string
+	PlatformCode       string
+}
+
+type StopArea struct {
+	gorm.Model
+	AreaId string `gorm:"primaryKey"`
+	StopId string `gorm:"primaryKey"`
+}
+
+type StopTime struct {
+	gorm.Model
+	TripId            string `gorm:"primaryKey"`
+	ArrivalTime       gtfspec.GTFSTime
+	DepartureTime     gtfspec.GTFSTime
+	StopId            string `gorm:"primaryKey"`
+	StopSequence      int
+	StopHeadsign      string
+	PickupType        int
+	DropOffType       int
+	ShapeDistTraveled float64
+	Timepoint         int
+}
+
+type Transfer struct {
+	gorm.Model
+	FromStopId      string `gorm:"primaryKey"`
+	ToStopId        string `gorm:"primaryKey"`
+	FromRouteId     string
+	ToRouteId       string
+	FromTripId      string
+	ToTripId        string
+	TransferType    int
+	MinTransferTime int
+}
+
+type Trip struct {
+	gorm.Model
+	RouteId      string `gorm:"primaryKey"`
+	ServiceId    string
+	TripID       string `gorm:"primaryKey"`
+	Headsign     string
+	ShortName    string
+	DirectionId  int
+	BlockId      string
+	ShapeId      string
+	Wheelchair   bool
+	BikesAllowed bool
+}
+
+type WalkingTransfer struct {
+	gorm.Model
+	FromStopId  string `gorm:"primaryKey"`
+	ToStopId    string `gorm:"primaryKey"`
+	Distance    float64
+	WalkTime    int
+	SameStation bool
+}
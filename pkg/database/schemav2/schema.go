@@ -0,0 +1,237 @@
+// Package schemav2 is a frozen copy of the tables created by migration 2,
+// which keys every table by its GTFS natural key and drops gorm.Model.
+// It must never change; later schema changes get a package of their own.
+package schemav2
+
+import (
+	"time"
+
+	"github.com/rmrfslashbin/gomarta/pkg/gtfspec"
+)
+
+type Agency struct {
+	AgencyId string `gorm:"primaryKey"`
+	Name     string
+	Url      string
+	Timezone string
+	Lang     string
+	Phone    string
+	FareUrl  string
+}
+
+type Area struct {
+	AreaId string `gorm:"primaryKey"`
+	Name   string
+}
+
+type Calendar struct {
+	ServiceId string `gorm:"primaryKey"`
+	Monday    int
+	Tuesday   int
+	Wednesday int
+	Thursday  int
+	Friday    int
+	Saturday  int
+	Sunday    int
+	StartDate time.Time
+	EndDate   time.Time
+}
+
+type CalendarDate struct {
+	ServiceId     string    `gorm:"primaryKey"`
+	Date          time.Time `gorm:"primaryKey"`
+	ExceptionType int
+}
+
+type FareAttribute struct {
+	FareId           string `gorm:"primaryKey"`
+	Price            float64
+	CurrencyType     string
+	PaymentMethod    int
+	Transfers        int
+	AgencyId         string
+	TransferDuration int
+}
+
+type FareLegRule struct {
+	LegGroupId           string
+	NetworkId            string `gorm:"primaryKey"`
+	FromAreaId           string `gorm:"primaryKey"`
+	ToAreaId             string `gorm:"primaryKey"`
+	FromTimeframeGroupId string `gorm:"primaryKey"`
+	ToTimeframeGroupId   string `gorm:"primaryKey"`
+	FareProductId        string `gorm:"primaryKey"`
+	RulePriority         int
+}
+
+type FareMedia struct {
+	FareMediaId string `gorm:"primaryKey"`
+	Name        string
+	Type        int
+}
+
+type FareProduct struct {
+	FareProductId string `gorm:"primaryKey"`
+	Name          string
+	FareMediaId   string `gorm:"primaryKey"`
+	Amount        float64
+	Currency      string
+}
+
+type FareRule struct {
+	FareId        string `gorm:"primaryKey"`
+	RouteId       string `gorm:"primaryKey"`
+	OriginId      string `gorm:"primaryKey"`
+	DestinationId string `gorm:"primaryKey"`
+	ContainsId    string `gorm:"primaryKey"`
+}
+
+type FareTransferRule struct {
+	FromLegGroupId    string `gorm:"primaryKey"`
+	ToLegGroupId      string `gorm:"primaryKey"`
+	TransferCount     int    `gorm:"primaryKey;autoIncrement:false"`
+	DurationLimit     int    `gorm:"primaryKey;autoIncrement:false"`
+	DurationLimitType int
+	FareTransferType  int
+	FareProductId     string `gorm:"primaryKey"`
+}
+
+type FeedInfo struct {
+	Id            uint `gorm:"primaryKey"`
+	PublisherName string
+	PublisherUrl  string
+	Lang          string
+	DefaultLang   string
+	StartDate     time.Time
+	EndDate       time.Time
+	Version       string
+	ContactEmail  string
+	ContactUrl    string
+}
+
+type Frequency struct {
+	TripId      string           `gorm:"primaryKey"`
+	StartTime   gtfspec.GTFSTime `gorm:"primaryKey"`
+	EndTime     gtfspec.GTFSTime
+	HeadwaySecs int
+	ExactTimes  int
+}
+
+type Level struct {
+	LevelId string `gorm:"primaryKey"`
+	Index   float64
+	Name    string
+}
+
+type Network struct {
+	NetworkId string `gorm:"primaryKey"`
+	Name      string
+}
+
+type Pathway struct {
+	PathwayId            string `gorm:"primaryKey"`
+	FromStopId           string
+	ToStopId             string
+	Mode                 int
+	IsBidirectional      bool
+	Length               float64
+	TraversalTime        int
+	StairCount           int
+	MaxSlope             float64
+	MinWidth             float64
+	SignpostedAs         string
+	ReversedSignpostedAs string
+}
+
+type Route struct {
+	RouteId   string `gorm:"primaryKey"`
+	AgencyId  string
+	ShortName string
+	LongName  string
+	Desc      string
+	RouteType int
+	Url       string
+	Color     []uint8
+	TextColor []uint8
+	NetworkId string
+}
+
+type RouteNetwork struct {
+	NetworkId string
+	RouteId   string `gorm:"primaryKey"`
+}
+
+type Shape struct {
+	ShapeId  string `gorm:"primaryKey"`
+	Lat      float64
+	Lon      float64
+	Sequence int `gorm:"primaryKey;autoIncrement:false"`
+	Distance float64
+}
+
+type Stop struct {
+	StopId             string `gorm:"primaryKey"`
+	Code               string
+	Name               string
+	Desc               string
+	Lat                float64
+	Lon                float64
+	ZoneId             string
+	Url                string
+	LocationType       int
+	ParentStation      string `gorm:"index"`
+	Timezone           string
+	WheelchairBoarding bool
+	LevelId            string
+	PlatformCode       string
+}
+
+type StopArea struct {
+	AreaId string `gorm:"primaryKey"`
+	StopId string `gorm:"primaryKey"`
+}
+
+type StopTime struct {
+	TripId            string `gorm:"primaryKey"`
+	ArrivalTime       gtfspec.GTFSTime
+	DepartureTime     gtfspec.GTFSTime `gorm:"index:idx_stop_times_stop_id,priority:2"`
+	StopId            string           `gorm:"index:idx_stop_times_stop_id,priority:1"`
+	StopSequence      int              `gorm:"primaryKey;autoIncrement:false"`
+	StopHeadsign      string
+	PickupType        int
+	DropOffType       int
+	ShapeDistTraveled float64
+	Timepoint         int
+}
+
+type Transfer struct {
+	FromStopId      string `gorm:"primaryKey"`
+	ToStopId        string `gorm:"primaryKey"`
+	FromRouteId     string `gorm:"primaryKey"`
+	ToRouteId       string `gorm:"primaryKey"`
+	FromTripId      string `gorm:"primaryKey"`
+	ToTripId        string `gorm:"primaryKey"`
+	TransferType    int
+	MinTransferTime int
+}
+
+type Trip struct {
+	RouteId      string `gorm:"index;index:idx_trips_trip_route,priority:2"`
+	ServiceId    string `gorm:"index"`
+	TripID       string `gorm:"primaryKey;index:idx_trips_trip_route,priority:1"`
+	Headsign     string
+	ShortName    string
+	DirectionId  int
+	BlockId      string
+	ShapeId      string
+	Wheelchair   bool
+	BikesAllowed bool
+}
+
+type WalkingTransfer struct {
+	FromStopId  string `gorm:"primaryKey"`
+	ToStopId    string `gorm:"primaryKey"`
+	Distance    float64
+	WalkTime    int
+	SameStation bool
+}
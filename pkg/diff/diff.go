@@ -0,0 +1,368 @@
+package diff
+
+import (
+	"os"
+	"sort"
+
+	"github.com/rmrfslashbin/gomarta/pkg/gtfspec"
+	"github.com/rs/zerolog"
+)
+
+// defaultMoveThreshold is how far in meters a stop must move to be reported
+const defaultMoveThreshold = 10
+
+// Options for the diff instance
+type Option func(c *Differ)
+
+// Differ compares two versions of a static feed
+type Differ struct {
+	log           *zerolog.Logger
+	old           *gtfspec.Feed
+	new           *gtfspec.Feed
+	moveThreshold float64
+}
+
+// New creates a new diff instance
+func New(opts ...Option) (*Differ, error) {
+	cfg := &Differ{}
+
+	// apply the list of options to Differ
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	// set up logger if not provided
+	if cfg.log == nil {
+		log := zerolog.New(os.Stderr).With().Timestamp().Logger()
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+		cfg.log = &log
+	}
+
+	if cfg.old == nil || cfg.new == nil {
+		return nil, &ErrNoFeed{}
+	}
+
+	if cfg.moveThreshold <= 0 {
+		cfg.moveThreshold = defaultMoveThreshold
+	}
+
+	return cfg, nil
+}
+
+// WithOld sets the earlier version of the feed
+func WithOld(feed *gtfspec.Feed) Option {
+	return func(c *Differ) {
+		c.old = feed
+	}
+}
+
+// WithNew sets the later version of the feed
+func WithNew(feed *gtfspec.Feed) Option {
+	return func(c *Differ) {
+		c.new = feed
+	}
+}
+
+// WithMoveThreshold sets how far in meters a stop must move to be reported
+func WithMoveThreshold(meters float64) Option {
+	return func(c *Differ) {
+		c.moveThreshold = meters
+	}
+}
+
+// WithLogger sets the logger for the diff instance
+func WithLogger(log *zerolog.Logger) Option {
+	return func(c *Differ) {
+		c.log = log
+	}
+}
+
+// Compare returns the changes from the old version of the feed to the new one
+func (c *Differ) Compare() *Diff {
+	d := &Diff{
+		Old:           version(c.old),
+		New:           version(c.new),
+		RoutesAdded:   make([]*Route, 0),
+		RoutesRemoved: make([]*Route, 0),
+		RoutesRenamed: make([]*RouteRename, 0),
+		StopsAdded:    make([]*Stop, 0),
+		StopsRemoved:  make([]*Stop, 0),
+		StopsRenamed:  make([]*StopRename, 0),
+		StopsMoved:    make([]*StopMove, 0),
+		TripCounts:    make([]*TripCount, 0),
+		Spans:         make([]*Span, 0),
+	}
+
+	pairs := c.compareRoutes(d)
+	c.compareStops(d)
+	c.compareService(d, pairs)
+
+	c.log.Debug().
+		Int("routesAdded", len(d.RoutesAdded)).
+		Int("routesRemoved", len(d.RoutesRemoved)).
+		Int("stopsAdded", len(d.StopsAdded)).
+		Int("stopsRemoved", len(d.StopsRemoved)).
+		Int("stopsMoved", len(d.StopsMoved)).
+		Int("tripCounts", len(d.TripCounts)).
+		Str("function", "pkg/diff.Compare()").
+		Msg("compared feeds")
+
+	return d
+}
+
+// version returns the feed_info of a feed
+func version(feed *gtfspec.Feed) *Version {
+	if len(feed.FeedInfo) == 0 {
+		return &Version{}
+	}
+	info := feed.FeedInfo[0]
+	v := &Version{Version: info.Version}
+	if !info.StartDate.IsZero() {
+		v.StartDate = info.StartDate.Format(gtfspec.DateFormat)
+	}
+	if !info.EndDate.IsZero() {
+		v.EndDate = info.EndDate.Format(gtfspec.DateFormat)
+	}
+	return v
+}
+
+// routePair is the same route in the old and new feed
+type routePair struct {
+	old *gtfspec.Route
+	new *gtfspec.Route
+}
+
+// compareRoutes finds added, removed and renamed routes. Routes are matched
+// by route_id, then by short name, since route ids are not always stable
+// between releases. It returns the matched routes.
+func (c *Differ) compareRoutes(d *Diff) []*routePair {
+	newById := make(map[string]*gtfspec.Route, len(c.new.Routes))
+	for _, route := range c.new.Routes {
+		newById[route.RouteId] = route
+	}
+
+	pairs := make([]*routePair, 0, len(c.old.Routes))
+	matched := make(map[string]bool)
+	unmatched := make([]*gtfspec.Route, 0)
+	for _, route := range c.old.Routes {
+		if match, ok := newById[route.RouteId]; ok {
+			pairs = append(pairs, &routePair{old: route, new: match})
+			matched[match.RouteId] = true
+		} else {
+			unmatched = append(unmatched, route)
+		}
+	}
+
+	newByName := make(map[string]*gtfspec.Route)
+	for _, route := range c.new.Routes {
+		if !matched[route.RouteId] && route.ShortName != "" {
+			newByName[route.ShortName] = route
+		}
+	}
+	for _, route := range unmatched {
+		if match, ok := newByName[route.ShortName]; ok && route.ShortName != "" && !matched[match.RouteId] {
+			pairs = append(pairs, &routePair{old: route, new: match})
+			matched[match.RouteId] = true
+			continue
+		}
+		d.RoutesRemoved = append(d.RoutesRemoved, routeOf(route))
+	}
+	for _, route := range c.new.Routes {
+		if !matched[route.RouteId] {
+			d.RoutesAdded = append(d.RoutesAdded, routeOf(route))
+		}
+	}
+
+	for _, pair := range pairs {
+		if pair.old.ShortName != pair.new.ShortName || pair.old.LongName != pair.new.LongName {
+			d.RoutesRenamed = append(d.RoutesRenamed, &RouteRename{Old: routeOf(pair.old), New: routeOf(pair.new)})
+		}
+	}
+
+	sortRoutes(d.RoutesAdded)
+	sortRoutes(d.RoutesRemoved)
+	sort.SliceStable(d.RoutesRenamed, func(i, j int) bool {
+		return routeLess(d.RoutesRenamed[i].New, d.RoutesRenamed[j].New)
+	})
+	sort.SliceStable(pairs, func(i, j int) bool {
+		return routeLess(routeOf(pairs[i].new), routeOf(pairs[j].new))
+	})
+
+	return pairs
+}
+
+// compareStops finds added, removed, renamed and moved stops, matched by stop_id
+func (c *Differ) compareStops(d *Diff) {
+	newById := make(map[string]*gtfspec.Stop, len(c.new.Stops))
+	for _, stop := range c.new.Stops {
+		newById[stop.StopId] = stop
+	}
+	oldById := make(map[string]bool, len(c.old.Stops))
+
+	for _, old := range c.old.Stops {
+		oldById[old.StopId] = true
+		stop, ok := newById[old.StopId]
+		if !ok {
+			d.StopsRemoved = append(d.StopsRemoved, stopOf(old))
+			continue
+		}
+		if old.Name != stop.Name {
+			d.StopsRenamed = append(d.StopsRenamed, &StopRename{Old: stopOf(old), New: stopOf(stop)})
+		}
+		if distance := old.Distance(stop); distance >= c.moveThreshold {
+			d.StopsMoved = append(d.StopsMoved, &StopMove{Old: stopOf(old), New: stopOf(stop), Distance: distance})
+		}
+	}
+	for _, stop := range c.new.Stops {
+		if !oldById[stop.StopId] {
+			d.StopsAdded = append(d.StopsAdded, stopOf(stop))
+		}
+	}
+
+	sortStops(d.StopsAdded)
+	sortStops(d.StopsRemoved)
+	sort.SliceStable(d.StopsRenamed, func(i, j int) bool {
+		return d.StopsRenamed[i].New.StopId < d.StopsRenamed[j].New.StopId
+	})
+	sort.SliceStable(d.StopsMoved, func(i, j int) bool {
+		return d.StopsMoved[i].Distance > d.StopsMoved[j].Distance
+	})
+}
+
+// compareService finds changes in the trip count and span of service of each
+// matched route on each day type
+func (c *Differ) compareService(d *Diff, pairs []*routePair) {
+	oldService := service(c.old)
+	newService := service(c.new)
+
+	for _, pair := range pairs {
+		for _, day := range dayTypes {
+			old := oldService[serviceKey{pair.old.RouteId, day}]
+			updated := newService[serviceKey{pair.new.RouteId, day}]
+			if old == nil {
+				old = &routeService{first: gtfspec.NoTime, last: gtfspec.NoTime}
+			}
+			if updated == nil {
+				updated = &routeService{first: gtfspec.NoTime, last: gtfspec.NoTime}
+			}
+
+			if old.trips != updated.trips {
+				d.TripCounts = append(d.TripCounts, &TripCount{
+					Route:   routeOf(pair.new),
+					DayType: day,
+					Old:     old.trips,
+					New:     updated.trips,
+				})
+			}
+			if old.first != updated.first || old.last != updated.last {
+				d.Spans = append(d.Spans, &Span{
+					Route:    routeOf(pair.new),
+					DayType:  day,
+					OldFirst: old.first,
+					OldLast:  old.last,
+					NewFirst: updated.first,
+					NewLast:  updated.last,
+				})
+			}
+		}
+	}
+}
+
+// serviceKey identifies the service of a route on a day type
+type serviceKey struct {
+	routeId string
+	dayType DayType
+}
+
+// routeService is the number of trips of a route on a day type and the span
+// from the first departure to the last arrival
+type routeService struct {
+	trips int
+	first gtfspec.GTFSTime
+	last  gtfspec.GTFSTime
+}
+
+// service counts the trips and span of every route on each day of the week,
+// using the weekday flags of calendar.txt. Services only defined by
+// calendar_dates.txt are treated as exceptions and not counted.
+func service(feed *gtfspec.Feed) map[serviceKey]*routeService {
+	runs := make(map[string]map[DayType]bool, len(feed.Calendars))
+	for _, calendar := range feed.Calendars {
+		flags := make(map[DayType]bool, len(dayTypes))
+		for _, day := range dayTypes {
+			flags[day] = calendar.RunsOn(dayWeekdays[day])
+		}
+		runs[calendar.ServiceId] = flags
+	}
+
+	first := make(map[string]gtfspec.GTFSTime, len(feed.Trips))
+	last := make(map[string]gtfspec.GTFSTime, len(feed.Trips))
+	for _, stopTime := range feed.StopTimes {
+		if departure := stopTime.DepartureTime; departure.IsSet() {
+			if current, ok := first[stopTime.TripId]; !ok || departure < current {
+				first[stopTime.TripId] = departure
+			}
+		}
+		if arrival := stopTime.ArrivalTime; arrival.IsSet() {
+			if current, ok := last[stopTime.TripId]; !ok || arrival > current {
+				last[stopTime.TripId] = arrival
+			}
+		}
+	}
+
+	services := make(map[serviceKey]*routeService)
+	for _, trip := range feed.Trips {
+		for _, day := range dayTypes {
+			if !runs[trip.ServiceId][day] {
+				continue
+			}
+			key := serviceKey{trip.RouteId, day}
+			s, ok := services[key]
+			if !ok {
+				s = &routeService{first: gtfspec.NoTime, last: gtfspec.NoTime}
+				services[key] = s
+			}
+			s.trips++
+			if departure, ok := first[trip.TripID]; ok && (!s.first.IsSet() || departure < s.first) {
+				s.first = departure
+			}
+			if arrival, ok := last[trip.TripID]; ok && arrival > s.last {
+				s.last = arrival
+			}
+		}
+	}
+
+	return services
+}
+
+// routeOf summarizes a route
+func routeOf(route *gtfspec.Route) *Route {
+	return &Route{RouteId: route.RouteId, ShortName: route.ShortName, LongName: route.LongName}
+}
+
+// stopOf summarizes a stop
+func stopOf(stop *gtfspec.Stop) *Stop {
+	return &Stop{StopId: stop.StopId, Code: stop.Code, Name: stop.Name, Lat: stop.Lat, Lon: stop.Lon}
+}
+
+// routeLess orders routes by short name, then route_id
+func routeLess(a *Route, b *Route) bool {
+	if a.ShortName != b.ShortName {
+		return a.ShortName < b.ShortName
+	}
+	return a.RouteId < b.RouteId
+}
+
+// sortRoutes orders routes by short name, then route_id
+func sortRoutes(routes []*Route) {
+	sort.SliceStable(routes, func(i, j int) bool {
+		return routeLess(routes[i], routes[j])
+	})
+}
+
+// sortStops orders stops by stop_id
+func sortStops(stops []*Stop) {
+	sort.SliceStable(stops, func(i, j int) bool {
+		return stops[i].StopId < stops[j].StopId
+	})
+}
@@ -0,0 +1,18 @@
+package diff
+
+// ErrNoFeed is returned when either version of the feed is missing.
+type ErrNoFeed struct {
+	Err error
+	Msg string
+}
+
+// Error returns the error message.
+func (e *ErrNoFeed) Error() string {
+	if e.Msg == "" {
+		e.Msg = "two feeds are required- use WithOld() and WithNew()"
+	}
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
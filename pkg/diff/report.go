@@ -0,0 +1,114 @@
+package diff
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+
+	"github.com/rmrfslashbin/gomarta/pkg/gtfspec"
+)
+
+// String returns the short name of a route, or its long name if it has none
+func (r *Route) String() string {
+	if r.ShortName != "" {
+		return r.ShortName
+	}
+	return r.LongName
+}
+
+// WriteJSON writes the diff as indented JSON
+func (d *Diff) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(d)
+}
+
+// WriteText writes the diff as sections of aligned columns, leaving out
+// sections without changes
+func (d *Diff) WriteText(w io.Writer) error {
+	fmt.Fprintf(w, "Old: %s\nNew: %s\n", d.Old, d.New)
+	if d.IsEmpty() {
+		_, err := fmt.Fprintln(w, "\nNo changes.")
+		return err
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	section := func(title string, count int, header string) bool {
+		if count == 0 {
+			return false
+		}
+		tw.Flush()
+		fmt.Fprintf(w, "\n%s (%d)\n", title, count)
+		fmt.Fprintln(tw, header)
+		return true
+	}
+
+	if section("Routes added", len(d.RoutesAdded), "ROUTE\tROUTE_ID\tNAME") {
+		for _, r := range d.RoutesAdded {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", r, r.RouteId, r.LongName)
+		}
+	}
+	if section("Routes removed", len(d.RoutesRemoved), "ROUTE\tROUTE_ID\tNAME") {
+		for _, r := range d.RoutesRemoved {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", r, r.RouteId, r.LongName)
+		}
+	}
+	if section("Routes renamed", len(d.RoutesRenamed), "ROUTE_ID\tOLD\tNEW") {
+		for _, r := range d.RoutesRenamed {
+			fmt.Fprintf(tw, "%s\t%s %s\t%s %s\n", r.New.RouteId, r.Old.ShortName, r.Old.LongName, r.New.ShortName, r.New.LongName)
+		}
+	}
+	if section("Stops added", len(d.StopsAdded), "STOP_ID\tCODE\tNAME\tLAT\tLON") {
+		for _, s := range d.StopsAdded {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%.6f\t%.6f\n", s.StopId, s.Code, s.Name, s.Lat, s.Lon)
+		}
+	}
+	if section("Stops removed", len(d.StopsRemoved), "STOP_ID\tCODE\tNAME\tLAT\tLON") {
+		for _, s := range d.StopsRemoved {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%.6f\t%.6f\n", s.StopId, s.Code, s.Name, s.Lat, s.Lon)
+		}
+	}
+	if section("Stops renamed", len(d.StopsRenamed), "STOP_ID\tOLD\tNEW") {
+		for _, s := range d.StopsRenamed {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", s.New.StopId, s.Old.Name, s.New.Name)
+		}
+	}
+	if section("Stops moved", len(d.StopsMoved), "STOP_ID\tNAME\tDISTANCE") {
+		for _, s := range d.StopsMoved {
+			fmt.Fprintf(tw, "%s\t%s\t%.0fm\n", s.New.StopId, s.New.Name, s.Distance)
+		}
+	}
+	if section("Trip counts changed", len(d.TripCounts), "ROUTE\tDAY\tOLD\tNEW\tCHANGE") {
+		for _, t := range d.TripCounts {
+			fmt.Fprintf(tw, "%s\t%s\t%d\t%d\t%+d\n", t.Route, t.DayType, t.Old, t.New, t.New-t.Old)
+		}
+	}
+	if section("Service span changed", len(d.Spans), "ROUTE\tDAY\tOLD FIRST\tOLD LAST\tNEW FIRST\tNEW LAST") {
+		for _, s := range d.Spans {
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\n", s.Route, s.DayType,
+				timeOrNone(s.OldFirst), timeOrNone(s.OldLast), timeOrNone(s.NewFirst), timeOrNone(s.NewLast))
+		}
+	}
+
+	return tw.Flush()
+}
+
+// String describes a feed version
+func (v *Version) String() string {
+	if v.Version == "" && v.StartDate == "" && v.EndDate == "" {
+		return "(no feed_info)"
+	}
+	if v.StartDate == "" && v.EndDate == "" {
+		return v.Version
+	}
+	return fmt.Sprintf("%s [%s - %s]", v.Version, v.StartDate, v.EndDate)
+}
+
+// timeOrNone formats a time, showing "-" when there is no service
+func timeOrNone(t gtfspec.GTFSTime) string {
+	if !t.IsSet() {
+		return "-"
+	}
+	return t.String()
+}
@@ -0,0 +1,123 @@
+package diff
+
+import (
+	"time"
+
+	"github.com/rmrfslashbin/gomarta/pkg/gtfspec"
+)
+
+// DayType is a day of the week service is compared on
+type DayType string
+
+const (
+	DayMonday    DayType = "monday"
+	DayTuesday   DayType = "tuesday"
+	DayWednesday DayType = "wednesday"
+	DayThursday  DayType = "thursday"
+	DayFriday    DayType = "friday"
+	DaySaturday  DayType = "saturday"
+	DaySunday    DayType = "sunday"
+)
+
+// dayTypes are the day types service is compared on. Each weekday is counted
+// on its own, so a route with separate Monday-Thursday and Friday services is
+// neither double counted nor reduced to one of them.
+var dayTypes = []DayType{DayMonday, DayTuesday, DayWednesday, DayThursday, DayFriday, DaySaturday, DaySunday}
+
+// dayWeekdays maps each day type to its day of the week
+var dayWeekdays = map[DayType]time.Weekday{
+	DayMonday:    time.Monday,
+	DayTuesday:   time.Tuesday,
+	DayWednesday: time.Wednesday,
+	DayThursday:  time.Thursday,
+	DayFriday:    time.Friday,
+	DaySaturday:  time.Saturday,
+	DaySunday:    time.Sunday,
+}
+
+// Version identifies one side of a comparison, from feed_info.txt
+type Version struct {
+	Version   string `json:"version,omitempty"`
+	StartDate string `json:"start_date,omitempty"`
+	EndDate   string `json:"end_date,omitempty"`
+}
+
+// Route identifies a route in a change
+type Route struct {
+	RouteId   string `json:"route_id"`
+	ShortName string `json:"short_name"`
+	LongName  string `json:"long_name"`
+}
+
+// RouteRename is a route whose names changed
+type RouteRename struct {
+	Old *Route `json:"old"`
+	New *Route `json:"new"`
+}
+
+// Stop identifies a stop in a change
+type Stop struct {
+	StopId string  `json:"stop_id"`
+	Code   string  `json:"code,omitempty"`
+	Name   string  `json:"name"`
+	Lat    float64 `json:"lat"`
+	Lon    float64 `json:"lon"`
+}
+
+// StopRename is a stop whose name changed
+type StopRename struct {
+	Old *Stop `json:"old"`
+	New *Stop `json:"new"`
+}
+
+// StopMove is a stop whose location changed
+type StopMove struct {
+	Old *Stop `json:"old"`
+	New *Stop `json:"new"`
+
+	// Distance is how far the stop moved in meters
+	Distance float64 `json:"distance"`
+}
+
+// TripCount is the change in the number of trips of a route on a day type
+type TripCount struct {
+	Route   *Route  `json:"route"`
+	DayType DayType `json:"day_type"`
+	Old     int     `json:"old"`
+	New     int     `json:"new"`
+}
+
+// Span is the change in the first departure or last arrival of a route on a day type
+type Span struct {
+	Route    *Route           `json:"route"`
+	DayType  DayType          `json:"day_type"`
+	OldFirst gtfspec.GTFSTime `json:"old_first"`
+	OldLast  gtfspec.GTFSTime `json:"old_last"`
+	NewFirst gtfspec.GTFSTime `json:"new_first"`
+	NewLast  gtfspec.GTFSTime `json:"new_last"`
+}
+
+// Diff is every change between two versions of a feed
+type Diff struct {
+	Old *Version `json:"old"`
+	New *Version `json:"new"`
+
+	RoutesAdded   []*Route       `json:"routes_added"`
+	RoutesRemoved []*Route       `json:"routes_removed"`
+	RoutesRenamed []*RouteRename `json:"routes_renamed"`
+
+	StopsAdded   []*Stop       `json:"stops_added"`
+	StopsRemoved []*Stop       `json:"stops_removed"`
+	StopsRenamed []*StopRename `json:"stops_renamed"`
+	StopsMoved   []*StopMove   `json:"stops_moved"`
+
+	TripCounts []*TripCount `json:"trip_counts"`
+	Spans      []*Span      `json:"spans"`
+}
+
+// IsEmpty reports whether the two versions have no differences
+func (d *Diff) IsEmpty() bool {
+	return len(d.RoutesAdded)+len(d.RoutesRemoved)+len(d.RoutesRenamed)+
+		len(d.StopsAdded)+len(d.StopsRemoved)+len(d.StopsRenamed)+len(d.StopsMoved)+
+		len(d.TripCounts)+len(d.Spans) == 0
+}
@@ -0,0 +1,98 @@
+package export
+
+// ErrLoadingData is returned when the feed cannot be read from the database.
+type ErrLoadingData struct {
+	Err error
+	Msg string
+}
+
+// Error returns the error message.
+func (e *ErrLoadingData) Error() string {
+	if e.Msg == "" {
+		e.Msg = "error loading feed data"
+	}
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
+
+// ErrNoData is returned when neither a database nor a feed is provided.
+type ErrNoData struct {
+	Err error
+	Msg string
+}
+
+// Error returns the error message.
+func (e *ErrNoData) Error() string {
+	if e.Msg == "" {
+		e.Msg = "no feed data provided- use WithDatabase() or WithFeed()"
+	}
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
+
+// ErrUnknownRoute is returned when a route filter matches no route.
+type ErrUnknownRoute struct {
+	Err     error
+	RouteId string
+	Msg     string
+}
+
+// Error returns the error message.
+func (e *ErrUnknownRoute) Error() string {
+	if e.Msg == "" {
+		e.Msg = "unknown route"
+	}
+	if e.RouteId != "" {
+		e.Msg += ": " + e.RouteId
+	}
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
+
+// ErrUnknownAgency is returned when an agency filter matches no agency.
+type ErrUnknownAgency struct {
+	Err      error
+	AgencyId string
+	Msg      string
+}
+
+// Error returns the error message.
+func (e *ErrUnknownAgency) Error() string {
+	if e.Msg == "" {
+		e.Msg = "unknown agency"
+	}
+	if e.AgencyId != "" {
+		e.Msg += ": " + e.AgencyId
+	}
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
+
+// ErrWritingFile is returned when a file of the zip cannot be written.
+type ErrWritingFile struct {
+	Err  error
+	File string
+	Msg  string
+}
+
+// Error returns the error message.
+func (e *ErrWritingFile) Error() string {
+	if e.Msg == "" {
+		e.Msg = "error writing feed"
+	}
+	if e.File != "" {
+		e.Msg += ": " + e.File
+	}
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
@@ -0,0 +1,161 @@
+package export
+
+import (
+	"io"
+	"os"
+	"time"
+
+	"github.com/rmrfslashbin/gomarta/pkg/gtfspec"
+	"github.com/rmrfslashbin/gomarta/pkg/store"
+	"github.com/rs/zerolog"
+)
+
+// Options for the exporter instance
+type Option func(c *Exporter)
+
+// Exporter writes a stored or parsed feed back out as a GTFS zip
+type Exporter struct {
+	log      *zerolog.Logger
+	db       store.Reader
+	feed     *gtfspec.Feed
+	routes   []string
+	agencies []string
+	bounds   *bounds
+	start    time.Time
+	end      time.Time
+
+	// filtered caches the result of Feed
+	filtered *gtfspec.Feed
+}
+
+// bounds is a latitude/longitude box
+type bounds struct {
+	minLat float64
+	minLon float64
+	maxLat float64
+	maxLon float64
+}
+
+// contains reports whether a coordinate is inside the box
+func (b *bounds) contains(lat float64, lon float64) bool {
+	return lat >= b.minLat && lat <= b.maxLat && lon >= b.minLon && lon <= b.maxLon
+}
+
+// New creates a new exporter instance
+func New(opts ...Option) (*Exporter, error) {
+	cfg := &Exporter{}
+
+	// apply the list of options to Exporter
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	// set up logger if not provided
+	if cfg.log == nil {
+		log := zerolog.New(os.Stderr).With().Timestamp().Logger()
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+		cfg.log = &log
+	}
+
+	if cfg.feed == nil {
+		if cfg.db == nil {
+			return nil, &ErrNoData{}
+		}
+		feed, err := cfg.db.LoadFeed()
+		if err != nil {
+			return nil, &ErrLoadingData{Err: err}
+		}
+		cfg.feed = feed
+	}
+
+	return cfg, nil
+}
+
+// WithDatabase exports the feed stored in the database
+func WithDatabase(db store.Reader) Option {
+	return func(c *Exporter) {
+		c.db = db
+	}
+}
+
+// WithFeed exports an already parsed feed
+func WithFeed(feed *gtfspec.Feed) Option {
+	return func(c *Exporter) {
+		c.feed = feed
+	}
+}
+
+// WithLogger sets the logger for the exporter instance
+func WithLogger(log *zerolog.Logger) Option {
+	return func(c *Exporter) {
+		c.log = log
+	}
+}
+
+// WithRoutes limits the export to routes given by route_id or short name
+func WithRoutes(routes ...string) Option {
+	return func(c *Exporter) {
+		c.routes = append(c.routes, routes...)
+	}
+}
+
+// WithAgencies limits the export to the routes of the given agencies
+func WithAgencies(agencies ...string) Option {
+	return func(c *Exporter) {
+		c.agencies = append(c.agencies, agencies...)
+	}
+}
+
+// WithBounds limits the export to trips that stop inside a box. Trips are
+// kept whole, so stops outside the box they also serve are included.
+func WithBounds(minLat float64, minLon float64, maxLat float64, maxLon float64) Option {
+	return func(c *Exporter) {
+		c.bounds = &bounds{minLat: minLat, minLon: minLon, maxLat: maxLat, maxLon: maxLon}
+	}
+}
+
+// WithDateRange limits the export to service between two dates, inclusive.
+// A zero start or end leaves that side open.
+func WithDateRange(start time.Time, end time.Time) Option {
+	return func(c *Exporter) {
+		c.start = start
+		c.end = end
+	}
+}
+
+// Feed returns the feed with the filters applied
+func (c *Exporter) Feed() (*gtfspec.Feed, error) {
+	if len(c.routes) == 0 && len(c.agencies) == 0 && c.bounds == nil && c.start.IsZero() && c.end.IsZero() {
+		return c.feed, nil
+	}
+	if c.filtered == nil {
+		filtered, err := c.filter()
+		if err != nil {
+			return nil, err
+		}
+		c.filtered = filtered
+	}
+	return c.filtered, nil
+}
+
+// Write writes the filtered feed as a GTFS zip
+func (c *Exporter) Write(w io.Writer) error {
+	feed, err := c.Feed()
+	if err != nil {
+		return err
+	}
+
+	if err := WriteFeed(w, feed); err != nil {
+		return err
+	}
+
+	c.log.Debug().
+		Int("routes", len(feed.Routes)).
+		Int("trips", len(feed.Trips)).
+		Int("stops", len(feed.Stops)).
+		Int("stopTimes", len(feed.StopTimes)).
+		Str("function", "pkg/export.Write()").
+		Msg("wrote feed")
+
+	return nil
+}
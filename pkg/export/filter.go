@@ -0,0 +1,364 @@
+package export
+
+import (
+	"time"
+
+	"github.com/rmrfslashbin/gomarta/pkg/gtfspec"
+)
+
+// filter returns a consistent copy of the feed limited to the selected routes,
+// agencies, area and dates: only trips on those routes running in the range
+// are kept, along with the stops, shapes, services and other records they
+// refer to.
+func (c *Exporter) filter() (*gtfspec.Feed, error) {
+	in := c.feed
+	out := &gtfspec.Feed{
+		FeedInfo: in.FeedInfo,
+	}
+
+	// Services are trimmed to the date range first; a service with no
+	// calendar or added date left in the range runs no trips.
+	calendars := make(map[string]*gtfspec.Calendar)
+	for _, calendar := range in.Calendars {
+		if !c.end.IsZero() && calendar.StartDate.After(c.end) {
+			continue
+		}
+		if !c.start.IsZero() && calendar.EndDate.Before(c.start) {
+			continue
+		}
+		trimmed := *calendar
+		if !c.start.IsZero() && trimmed.StartDate.Before(c.start) {
+			trimmed.StartDate = c.start
+		}
+		if !c.end.IsZero() && trimmed.EndDate.After(c.end) {
+			trimmed.EndDate = c.end
+		}
+		calendars[calendar.ServiceId] = &trimmed
+	}
+	calendarDates := make(map[string][]*gtfspec.CalendarDate)
+	for _, date := range in.CalendarDates {
+		if c.inRange(date.Date) {
+			calendarDates[date.ServiceId] = append(calendarDates[date.ServiceId], date)
+		}
+	}
+	services := make(map[string]bool)
+	for serviceId := range calendars {
+		services[serviceId] = true
+	}
+	for serviceId, dates := range calendarDates {
+		for _, date := range dates {
+			if date.ExceptionType == gtfspec.ExceptionAdded {
+				services[serviceId] = true
+			}
+		}
+	}
+
+	selected := make(map[string]bool)
+	for _, name := range c.routes {
+		found := false
+		for _, route := range in.Routes {
+			if route.RouteId == name || route.ShortName == name {
+				selected[route.RouteId] = true
+				found = true
+			}
+		}
+		if !found {
+			return nil, &ErrUnknownRoute{RouteId: name}
+		}
+	}
+
+	// agencyRoutes are the routes run by the selected agencies
+	agencyRoutes := make(map[string]bool)
+	for _, agencyId := range c.agencies {
+		found := false
+		for _, agency := range in.Agencies {
+			if agency.AgencyId == agencyId {
+				found = true
+			}
+		}
+		if !found {
+			return nil, &ErrUnknownAgency{AgencyId: agencyId}
+		}
+		for _, route := range in.Routes {
+			if route.AgencyId == agencyId || (route.AgencyId == "" && len(in.Agencies) == 1) {
+				agencyRoutes[route.RouteId] = true
+			}
+		}
+	}
+
+	var inBounds map[string]bool
+	if c.bounds != nil {
+		inside := make(map[string]bool)
+		for _, stop := range in.Stops {
+			if c.bounds.contains(stop.Lat, stop.Lon) {
+				inside[stop.StopId] = true
+			}
+		}
+		inBounds = make(map[string]bool)
+		for _, stopTime := range in.StopTimes {
+			if inside[stopTime.StopId] {
+				inBounds[stopTime.TripId] = true
+			}
+		}
+	}
+
+	trips := make(map[string]bool)
+	routes := make(map[string]bool)
+	shapes := make(map[string]bool)
+	usedServices := make(map[string]bool)
+	for _, trip := range in.Trips {
+		if len(selected) > 0 && !selected[trip.RouteId] {
+			continue
+		}
+		if len(c.agencies) > 0 && !agencyRoutes[trip.RouteId] {
+			continue
+		}
+		if inBounds != nil && !inBounds[trip.TripID] {
+			continue
+		}
+		if !services[trip.ServiceId] {
+			continue
+		}
+		out.Trips = append(out.Trips, trip)
+		trips[trip.TripID] = true
+		routes[trip.RouteId] = true
+		usedServices[trip.ServiceId] = true
+		if trip.ShapeId != "" {
+			shapes[trip.ShapeId] = true
+		}
+	}
+
+	stops := make(map[string]bool)
+	for _, stopTime := range in.StopTimes {
+		if trips[stopTime.TripId] {
+			out.StopTimes = append(out.StopTimes, stopTime)
+			stops[stopTime.StopId] = true
+		}
+	}
+	for _, frequency := range in.Frequencies {
+		if trips[frequency.TripId] {
+			out.Frequencies = append(out.Frequencies, frequency)
+		}
+	}
+
+	for _, route := range in.Routes {
+		if routes[route.RouteId] {
+			out.Routes = append(out.Routes, route)
+		}
+	}
+	agencies := make(map[string]bool)
+	for _, route := range out.Routes {
+		agencies[route.AgencyId] = true
+	}
+	for _, agency := range in.Agencies {
+		// Routes may omit agency_id when the feed has a single agency.
+		if agencies[agency.AgencyId] || agencies[""] {
+			out.Agencies = append(out.Agencies, agency)
+		}
+	}
+
+	for _, calendar := range in.Calendars {
+		if usedServices[calendar.ServiceId] && calendars[calendar.ServiceId] != nil {
+			out.Calendars = append(out.Calendars, calendars[calendar.ServiceId])
+		}
+	}
+	for _, date := range in.CalendarDates {
+		if usedServices[date.ServiceId] && c.inRange(date.Date) {
+			out.CalendarDates = append(out.CalendarDates, date)
+		}
+	}
+
+	for _, point := range in.Shapes {
+		if shapes[point.ShapeId] {
+			out.Shapes = append(out.Shapes, point)
+		}
+	}
+
+	// Keep the stations the served stops belong to, then the entrances,
+	// nodes and boarding areas inside kept stations and platforms.
+	byId := make(map[string]*gtfspec.Stop, len(in.Stops))
+	for _, stop := range in.Stops {
+		byId[stop.StopId] = stop
+	}
+	for stopId := range stops {
+		for parent := byId[stopId]; parent != nil && parent.ParentStation != "" && !stops[parent.ParentStation]; parent = byId[parent.ParentStation] {
+			stops[parent.ParentStation] = true
+		}
+	}
+	for _, stop := range in.Stops {
+		if stop.LocationType >= gtfspec.LocationEntrance && stops[stop.ParentStation] {
+			stops[stop.StopId] = true
+		}
+	}
+	levels := make(map[string]bool)
+	for _, stop := range in.Stops {
+		if stops[stop.StopId] {
+			out.Stops = append(out.Stops, stop)
+			if stop.LevelId != "" {
+				levels[stop.LevelId] = true
+			}
+		}
+	}
+	for _, level := range in.Levels {
+		if levels[level.LevelId] {
+			out.Levels = append(out.Levels, level)
+		}
+	}
+	for _, pathway := range in.Pathways {
+		if stops[pathway.FromStopId] && stops[pathway.ToStopId] {
+			out.Pathways = append(out.Pathways, pathway)
+		}
+	}
+	for _, stopArea := range in.StopAreas {
+		if stops[stopArea.StopId] {
+			out.StopAreas = append(out.StopAreas, stopArea)
+		}
+	}
+
+	for _, transfer := range in.Transfers {
+		if (transfer.FromStopId != "" && !stops[transfer.FromStopId]) || (transfer.ToStopId != "" && !stops[transfer.ToStopId]) {
+			continue
+		}
+		if (transfer.FromRouteId != "" && !routes[transfer.FromRouteId]) || (transfer.ToRouteId != "" && !routes[transfer.ToRouteId]) {
+			continue
+		}
+		if (transfer.FromTripId != "" && !trips[transfer.FromTripId]) || (transfer.ToTripId != "" && !trips[transfer.ToTripId]) {
+			continue
+		}
+		out.Transfers = append(out.Transfers, transfer)
+	}
+
+	for _, routeNetwork := range in.RouteNetworks {
+		if routes[routeNetwork.RouteId] {
+			out.RouteNetworks = append(out.RouteNetworks, routeNetwork)
+		}
+	}
+
+	// Fares v1: a fare is kept when its agency is, unless it is restricted
+	// by fare rules and none of them name a kept route or zone.
+	keptAgencies := make(map[string]bool)
+	for _, agency := range out.Agencies {
+		keptAgencies[agency.AgencyId] = true
+	}
+	zones := make(map[string]bool)
+	for _, stop := range out.Stops {
+		if stop.ZoneId != "" {
+			zones[stop.ZoneId] = true
+		}
+	}
+	keepRule := func(rule *gtfspec.FareRule) bool {
+		if rule.RouteId != "" && !routes[rule.RouteId] {
+			return false
+		}
+		for _, zoneId := range []string{rule.OriginId, rule.DestinationId, rule.ContainsId} {
+			if zoneId != "" && !zones[zoneId] {
+				return false
+			}
+		}
+		return true
+	}
+	restricted := make(map[string]bool)
+	ruled := make(map[string]bool)
+	for _, rule := range in.FareRules {
+		restricted[rule.FareId] = true
+		if keepRule(rule) {
+			ruled[rule.FareId] = true
+		}
+	}
+	fares := make(map[string]bool)
+	for _, fare := range in.FareAttributes {
+		if fare.AgencyId != "" && !keptAgencies[fare.AgencyId] {
+			continue
+		}
+		if restricted[fare.FareId] && !ruled[fare.FareId] {
+			continue
+		}
+		out.FareAttributes = append(out.FareAttributes, fare)
+		fares[fare.FareId] = true
+	}
+	for _, rule := range in.FareRules {
+		if fares[rule.FareId] && keepRule(rule) {
+			out.FareRules = append(out.FareRules, rule)
+		}
+	}
+
+	// Fares v2: networks and areas are kept when a kept route or stop belongs
+	// to them, and leg rules only when every network and area they name is
+	// kept. Fare products and media are kept when a kept rule uses them.
+	networks := make(map[string]bool)
+	for _, route := range out.Routes {
+		if route.NetworkId != "" {
+			networks[route.NetworkId] = true
+		}
+	}
+	for _, routeNetwork := range out.RouteNetworks {
+		networks[routeNetwork.NetworkId] = true
+	}
+	for _, network := range in.Networks {
+		if networks[network.NetworkId] {
+			out.Networks = append(out.Networks, network)
+		}
+	}
+	areas := make(map[string]bool)
+	for _, stopArea := range out.StopAreas {
+		areas[stopArea.AreaId] = true
+	}
+	for _, area := range in.Areas {
+		if areas[area.AreaId] {
+			out.Areas = append(out.Areas, area)
+		}
+	}
+
+	legGroups := make(map[string]bool)
+	products := make(map[string]bool)
+	for _, rule := range in.FareLegRules {
+		if rule.NetworkId != "" && !networks[rule.NetworkId] {
+			continue
+		}
+		if (rule.FromAreaId != "" && !areas[rule.FromAreaId]) || (rule.ToAreaId != "" && !areas[rule.ToAreaId]) {
+			continue
+		}
+		out.FareLegRules = append(out.FareLegRules, rule)
+		if rule.LegGroupId != "" {
+			legGroups[rule.LegGroupId] = true
+		}
+		products[rule.FareProductId] = true
+	}
+	for _, rule := range in.FareTransferRules {
+		if (rule.FromLegGroupId != "" && !legGroups[rule.FromLegGroupId]) || (rule.ToLegGroupId != "" && !legGroups[rule.ToLegGroupId]) {
+			continue
+		}
+		out.FareTransferRules = append(out.FareTransferRules, rule)
+		if rule.FareProductId != "" {
+			products[rule.FareProductId] = true
+		}
+	}
+	// Products no rule refers to can't be pruned, so a feed without fare
+	// rules keeps them all, and likewise media without products.
+	unruled := len(in.FareLegRules) == 0 && len(in.FareTransferRules) == 0
+	media := make(map[string]bool)
+	for _, product := range in.FareProducts {
+		if products[product.FareProductId] || unruled {
+			out.FareProducts = append(out.FareProducts, product)
+			media[product.FareMediaId] = true
+		}
+	}
+	for _, fareMedia := range in.FareMedia {
+		if media[fareMedia.FareMediaId] || len(in.FareProducts) == 0 {
+			out.FareMedia = append(out.FareMedia, fareMedia)
+		}
+	}
+
+	return out, nil
+}
+
+// inRange reports whether a service date is within the date range
+func (c *Exporter) inRange(date time.Time) bool {
+	if !c.start.IsZero() && date.Before(c.start) {
+		return false
+	}
+	if !c.end.IsZero() && date.After(c.end) {
+		return false
+	}
+	return true
+}
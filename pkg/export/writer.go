@@ -0,0 +1,203 @@
+package export
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"encoding/hex"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/rmrfslashbin/gomarta/pkg/gtfspec"
+)
+
+// emptyZero lists optional numeric columns where zero means the column was
+// absent from the source feed, so zero is written as an empty value
+var emptyZero = map[string]bool{
+	"shape_dist_traveled": true,
+	"min_transfer_time":   true,
+	"transfer_duration":   true,
+	"length":              true,
+	"traversal_time":      true,
+	"stair_count":         true,
+	"max_slope":           true,
+	"min_width":           true,
+	"duration_limit":      true,
+	"duration_limit_type": true,
+}
+
+var (
+	timeType     = reflect.TypeOf(time.Time{})
+	gtfsTimeType = reflect.TypeOf(gtfspec.GTFSTime(0))
+)
+
+// table is one file of a feed and its rows, a slice of pointers to a gtfspec type
+type table struct {
+	name string
+	rows interface{}
+}
+
+// tables lists the files of a feed in the order they are written
+func tables(feed *gtfspec.Feed) []table {
+	return []table{
+		{"agency.txt", feed.Agencies},
+		{"stops.txt", feed.Stops},
+		{"routes.txt", feed.Routes},
+		{"trips.txt", feed.Trips},
+		{"stop_times.txt", feed.StopTimes},
+		{"calendar.txt", feed.Calendars},
+		{"calendar_dates.txt", feed.CalendarDates},
+		{"fare_attributes.txt", feed.FareAttributes},
+		{"fare_rules.txt", feed.FareRules},
+		{"fare_media.txt", feed.FareMedia},
+		{"fare_products.txt", feed.FareProducts},
+		{"fare_leg_rules.txt", feed.FareLegRules},
+		{"fare_transfer_rules.txt", feed.FareTransferRules},
+		{"areas.txt", feed.Areas},
+		{"stop_areas.txt", feed.StopAreas},
+		{"networks.txt", feed.Networks},
+		{"route_networks.txt", feed.RouteNetworks},
+		{"shapes.txt", feed.Shapes},
+		{"frequencies.txt", feed.Frequencies},
+		{"transfers.txt", feed.Transfers},
+		{"pathways.txt", feed.Pathways},
+		{"levels.txt", feed.Levels},
+		{"feed_info.txt", feed.FeedInfo},
+	}
+}
+
+// WriteFeed writes a feed as a GTFS zip. Empty files are left out, as are
+// optional columns that are empty in every row.
+func WriteFeed(w io.Writer, feed *gtfspec.Feed) error {
+	archive := zip.NewWriter(w)
+
+	for _, t := range tables(feed) {
+		records := encode(t.rows)
+		if len(records) < 2 {
+			continue
+		}
+
+		f, err := archive.Create(t.name)
+		if err != nil {
+			return &ErrWritingFile{Err: err, File: t.name}
+		}
+		cw := csv.NewWriter(f)
+		if err := cw.WriteAll(records); err != nil {
+			return &ErrWritingFile{Err: err, File: t.name}
+		}
+	}
+
+	if err := archive.Close(); err != nil {
+		return &ErrWritingFile{Err: err}
+	}
+	return nil
+}
+
+// encode returns the header and records of a slice of gtfspec rows. Columns
+// are named by the json tags of the row type, which match the GTFS field names.
+func encode(rows interface{}) [][]string {
+	slice := reflect.ValueOf(rows)
+	if slice.Kind() != reflect.Slice || slice.Len() == 0 {
+		return nil
+	}
+
+	structType := slice.Type().Elem()
+	if structType.Kind() == reflect.Ptr {
+		structType = structType.Elem()
+	}
+
+	fields := make([]int, 0, structType.NumField())
+	header := make([]string, 0, structType.NumField())
+	for i := 0; i < structType.NumField(); i++ {
+		field := structType.Field(i)
+		if field.Anonymous {
+			continue
+		}
+		name := strings.Split(field.Tag.Get("json"), ",")[0]
+		if name == "" || name == "-" {
+			continue
+		}
+		fields = append(fields, i)
+		header = append(header, name)
+	}
+
+	records := make([][]string, 0, slice.Len())
+	used := make([]bool, len(fields))
+	for ndx := 0; ndx < slice.Len(); ndx++ {
+		value := reflect.Indirect(slice.Index(ndx))
+		record := make([]string, len(fields))
+		for col, field := range fields {
+			record[col] = format(header[col], value.Field(field))
+			if record[col] != "" {
+				used[col] = true
+			}
+		}
+		records = append(records, record)
+	}
+
+	// Drop the columns no row uses.
+	keep := make([]int, 0, len(fields))
+	for col := range fields {
+		if used[col] {
+			keep = append(keep, col)
+		}
+	}
+	output := make([][]string, 0, len(records)+1)
+	output = append(output, pick(header, keep))
+	for _, record := range records {
+		output = append(output, pick(record, keep))
+	}
+	return output
+}
+
+// pick returns the values at the given indexes
+func pick(values []string, indexes []int) []string {
+	picked := make([]string, len(indexes))
+	for i, ndx := range indexes {
+		picked[i] = values[ndx]
+	}
+	return picked
+}
+
+// format returns the GTFS representation of a field value
+func format(column string, value reflect.Value) string {
+	switch value.Type() {
+	case timeType:
+		t := value.Interface().(time.Time)
+		if t.IsZero() {
+			return ""
+		}
+		return t.Format(gtfspec.DateFormat)
+	case gtfsTimeType:
+		return value.Interface().(gtfspec.GTFSTime).String()
+	}
+
+	switch value.Kind() {
+	case reflect.String:
+		return value.String()
+	case reflect.Bool:
+		if value.Bool() {
+			return "1"
+		}
+		return "0"
+	case reflect.Int, reflect.Int32, reflect.Int64:
+		i := value.Int()
+		if (i == 0 && emptyZero[column]) || ((column == "transfers" || column == "transfer_count") && i == gtfspec.UnlimitedTransfers) {
+			return ""
+		}
+		return strconv.FormatInt(i, 10)
+	case reflect.Float32, reflect.Float64:
+		f := value.Float()
+		if f == 0 && emptyZero[column] {
+			return ""
+		}
+		return strconv.FormatFloat(f, 'f', -1, 64)
+	case reflect.Slice:
+		if value.Type().Elem().Kind() == reflect.Uint8 {
+			return strings.ToUpper(hex.EncodeToString(value.Bytes()))
+		}
+	}
+	return ""
+}
@@ -0,0 +1,81 @@
+package fares
+
+import "strconv"
+
+// ErrLoadingData is returned when fare tables cannot be read from the database.
+type ErrLoadingData struct {
+	Err       error
+	Structure string
+	Msg       string
+}
+
+// Error returns the error message.
+func (e *ErrLoadingData) Error() string {
+	if e.Msg == "" {
+		e.Msg = "error loading fare data"
+	}
+	if e.Structure != "" {
+		e.Msg += ": " + e.Structure
+	}
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
+
+// ErrNoData is returned when neither a database nor a feed is provided.
+type ErrNoData struct {
+	Err error
+	Msg string
+}
+
+// Error returns the error message.
+func (e *ErrNoData) Error() string {
+	if e.Msg == "" {
+		e.Msg = "no fare data provided- use WithDatabase() or WithFeed()"
+	}
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
+
+// ErrNoFare is returned when no fare applies to a leg.
+type ErrNoFare struct {
+	Err     error
+	Leg     int
+	RouteId string
+	Msg     string
+}
+
+// Error returns the error message.
+func (e *ErrNoFare) Error() string {
+	if e.Msg == "" {
+		e.Msg = "no fare applies to leg"
+	}
+	e.Msg += " " + strconv.Itoa(e.Leg)
+	if e.RouteId != "" {
+		e.Msg += ": route " + e.RouteId
+	}
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
+
+// ErrNoLegs is returned when Calculate is called without any legs.
+type ErrNoLegs struct {
+	Err error
+	Msg string
+}
+
+// Error returns the error message.
+func (e *ErrNoLegs) Error() string {
+	if e.Msg == "" {
+		e.Msg = "no legs provided"
+	}
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
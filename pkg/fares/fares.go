@@ -0,0 +1,440 @@
+package fares
+
+import (
+	"os"
+	"sort"
+	"time"
+
+	"github.com/rmrfslashbin/gomarta/pkg/gtfspec"
+	"github.com/rmrfslashbin/gomarta/pkg/store"
+	"github.com/rs/zerolog"
+)
+
+// Options for the calculator instance
+type Option func(c *Calculator)
+
+// Calculator prices sequences of legs using fares v1 (fare_attributes/fare_rules)
+// or, when the feed provides fare_leg_rules, fares v2.
+type Calculator struct {
+	log  *zerolog.Logger
+	db   store.Reader
+	feed *gtfspec.Feed
+
+	attributes    map[string]*gtfspec.FareAttribute
+	rulesByFare   map[string][]*gtfspec.FareRule
+	products      map[string]*gtfspec.FareProduct
+	legRules      []*gtfspec.FareLegRule
+	transferRules []*gtfspec.FareTransferRule
+	routeNetworks map[string]string
+	stopAreas     map[string][]string
+	stopZones     map[string]string
+}
+
+// New creates a new fare calculator instance
+func New(opts ...Option) (*Calculator, error) {
+	cfg := &Calculator{}
+
+	// apply the list of options to Calculator
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	// set up logger if not provided
+	if cfg.log == nil {
+		log := zerolog.New(os.Stderr).With().Timestamp().Logger()
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+		cfg.log = &log
+	}
+
+	if cfg.feed == nil {
+		if cfg.db == nil {
+			return nil, &ErrNoData{}
+		}
+		feed, err := loadFeed(cfg.db)
+		if err != nil {
+			return nil, err
+		}
+		cfg.feed = feed
+	}
+
+	cfg.index()
+
+	return cfg, nil
+}
+
+// WithDatabase loads the fare tables from the database
+func WithDatabase(db store.Reader) Option {
+	return func(c *Calculator) {
+		c.db = db
+	}
+}
+
+// WithFeed uses the fare tables of an already parsed feed
+func WithFeed(feed *gtfspec.Feed) Option {
+	return func(c *Calculator) {
+		c.feed = feed
+	}
+}
+
+// WithLogger sets the logger for the calculator instance
+func WithLogger(log *zerolog.Logger) Option {
+	return func(c *Calculator) {
+		c.log = log
+	}
+}
+
+// loadFeed reads the tables the calculator needs from the database
+func loadFeed(db store.Reader) (*gtfspec.Feed, error) {
+	feed := &gtfspec.Feed{}
+
+	var err error
+	if feed.FareAttributes, err = db.GetFareAttributes(); err != nil {
+		return nil, &ErrLoadingData{Err: err, Structure: "FareAttributes"}
+	}
+	if feed.FareRules, err = db.GetFareRules(); err != nil {
+		return nil, &ErrLoadingData{Err: err, Structure: "FareRules"}
+	}
+	if feed.FareProducts, err = db.GetFareProducts(); err != nil {
+		return nil, &ErrLoadingData{Err: err, Structure: "FareProducts"}
+	}
+	if feed.FareLegRules, err = db.GetFareLegRules(); err != nil {
+		return nil, &ErrLoadingData{Err: err, Structure: "FareLegRules"}
+	}
+	if feed.FareTransferRules, err = db.GetFareTransferRules(); err != nil {
+		return nil, &ErrLoadingData{Err: err, Structure: "FareTransferRules"}
+	}
+	if feed.RouteNetworks, err = db.GetRouteNetworks(); err != nil {
+		return nil, &ErrLoadingData{Err: err, Structure: "RouteNetworks"}
+	}
+	if feed.Routes, err = db.GetRoutes(); err != nil {
+		return nil, &ErrLoadingData{Err: err, Structure: "Routes"}
+	}
+	if feed.StopAreas, err = db.GetStopAreas(); err != nil {
+		return nil, &ErrLoadingData{Err: err, Structure: "StopAreas"}
+	}
+	if feed.Stops, err = db.GetAllStops(); err != nil {
+		return nil, &ErrLoadingData{Err: err, Structure: "Stops"}
+	}
+
+	return feed, nil
+}
+
+// index builds the lookup tables used while pricing
+func (c *Calculator) index() {
+	c.attributes = make(map[string]*gtfspec.FareAttribute, len(c.feed.FareAttributes))
+	for _, attribute := range c.feed.FareAttributes {
+		c.attributes[attribute.FareId] = attribute
+	}
+
+	c.rulesByFare = make(map[string][]*gtfspec.FareRule)
+	for _, rule := range c.feed.FareRules {
+		c.rulesByFare[rule.FareId] = append(c.rulesByFare[rule.FareId], rule)
+	}
+
+	// A product may be sold on several media at different prices; price legs at the cheapest.
+	c.products = make(map[string]*gtfspec.FareProduct, len(c.feed.FareProducts))
+	for _, product := range c.feed.FareProducts {
+		if p, ok := c.products[product.FareProductId]; !ok || product.Amount < p.Amount {
+			c.products[product.FareProductId] = product
+		}
+	}
+
+	c.legRules = c.feed.FareLegRules
+	c.transferRules = c.feed.FareTransferRules
+
+	c.routeNetworks = make(map[string]string)
+	for _, route := range c.feed.Routes {
+		if route.NetworkId != "" {
+			c.routeNetworks[route.RouteId] = route.NetworkId
+		}
+	}
+	for _, routeNetwork := range c.feed.RouteNetworks {
+		c.routeNetworks[routeNetwork.RouteId] = routeNetwork.NetworkId
+	}
+
+	c.stopAreas = make(map[string][]string)
+	for _, stopArea := range c.feed.StopAreas {
+		c.stopAreas[stopArea.StopId] = append(c.stopAreas[stopArea.StopId], stopArea.AreaId)
+	}
+
+	c.stopZones = make(map[string]string, len(c.feed.Stops))
+	for _, stop := range c.feed.Stops {
+		c.stopZones[stop.StopId] = stop.ZoneId
+	}
+}
+
+// LegFromStops builds a leg for a ride between two stops, filling in their zones and areas
+func (c *Calculator) LegFromStops(routeId string, fromStopId string, toStopId string, start time.Time) *Leg {
+	leg := &Leg{
+		RouteId:         routeId,
+		OriginZone:      c.stopZones[fromStopId],
+		DestinationZone: c.stopZones[toStopId],
+		Start:           start,
+	}
+	if areas := c.stopAreas[fromStopId]; len(areas) > 0 {
+		leg.FromAreaId = areas[0]
+	}
+	if areas := c.stopAreas[toStopId]; len(areas) > 0 {
+		leg.ToAreaId = areas[0]
+	}
+	return leg
+}
+
+// Calculate prices a sequence of legs, applying transfer allowances between them
+func (c *Calculator) Calculate(legs []*Leg) (*Fare, error) {
+	if len(legs) == 0 {
+		return nil, &ErrNoLegs{}
+	}
+	if len(c.legRules) > 0 {
+		return c.calculateV2(legs)
+	}
+	return c.calculateV1(legs)
+}
+
+// calculateV1 prices legs with fare_attributes.txt and fare_rules.txt.
+// A leg rides free on the previous fare when that fare also applies to it and
+// its transfer count and transfer_duration have not run out.
+func (c *Calculator) calculateV1(legs []*Leg) (*Fare, error) {
+	fare := &Fare{Legs: make([]*LegFare, 0, len(legs))}
+
+	var current *gtfspec.FareAttribute
+	var remaining int
+	var expires time.Time
+
+	for ndx, leg := range legs {
+		candidates := c.matchFares(leg)
+		if len(candidates) == 0 {
+			return nil, &ErrNoFare{Leg: ndx, RouteId: leg.RouteId}
+		}
+
+		if current != nil && remaining != 0 && withinWindow(leg.Start, expires) && containsFare(candidates, current.FareId) {
+			fare.Legs = append(fare.Legs, &LegFare{Leg: leg, FareId: current.FareId, Transfer: true})
+			if remaining > 0 {
+				remaining--
+			}
+			continue
+		}
+
+		current = candidates[0]
+		remaining = current.Transfers
+		expires = time.Time{}
+		if current.TransferDuration > 0 && !leg.Start.IsZero() {
+			expires = leg.Start.Add(time.Duration(current.TransferDuration) * time.Second)
+		}
+
+		fare.Total += current.Price
+		fare.Currency = current.CurrencyType
+		fare.Legs = append(fare.Legs, &LegFare{Leg: leg, FareId: current.FareId, Amount: current.Price})
+	}
+
+	fare.TransfersRemaining = remaining
+	fare.TransferExpires = expires
+
+	return fare, nil
+}
+
+// matchFares returns the fares that apply to a leg, cheapest first.
+// A fare without any fare_rules applies to every leg.
+func (c *Calculator) matchFares(leg *Leg) []*gtfspec.FareAttribute {
+	matches := make([]*gtfspec.FareAttribute, 0)
+
+	for fareId, attribute := range c.attributes {
+		rules, ok := c.rulesByFare[fareId]
+		if !ok {
+			matches = append(matches, attribute)
+			continue
+		}
+		for _, rule := range rules {
+			if ruleMatches(rule, leg) {
+				matches = append(matches, attribute)
+				break
+			}
+		}
+	}
+
+	sort.Slice(matches, func(i, j int) bool {
+		if matches[i].Price != matches[j].Price {
+			return matches[i].Price < matches[j].Price
+		}
+		return matches[i].FareId < matches[j].FareId
+	})
+
+	return matches
+}
+
+// ruleMatches reports whether every field set on a fare rule matches the leg
+func ruleMatches(rule *gtfspec.FareRule, leg *Leg) bool {
+	if rule.RouteId != "" && rule.RouteId != leg.RouteId {
+		return false
+	}
+	if rule.OriginId != "" && rule.OriginId != leg.OriginZone {
+		return false
+	}
+	if rule.DestinationId != "" && rule.DestinationId != leg.DestinationZone {
+		return false
+	}
+	if rule.ContainsId != "" {
+		for _, zone := range leg.ContainsZones {
+			if zone == rule.ContainsId {
+				return true
+			}
+		}
+		return false
+	}
+	return true
+}
+
+// calculateV2 prices legs with fare_leg_rules.txt and fare_transfer_rules.txt.
+// Transfer durations are measured from the start of the first leg of the
+// transfer chain to the start of the current leg.
+func (c *Calculator) calculateV2(legs []*Leg) (*Fare, error) {
+	fare := &Fare{Legs: make([]*LegFare, 0, len(legs))}
+
+	var previous *LegFare
+	var chainStart time.Time
+	transfersUsed := 0
+
+	for ndx, leg := range legs {
+		rule := c.matchLegRule(leg)
+		if rule == nil {
+			return nil, &ErrNoFare{Leg: ndx, RouteId: leg.RouteId}
+		}
+		product, ok := c.products[rule.FareProductId]
+		if !ok {
+			return nil, &ErrNoFare{Leg: ndx, RouteId: leg.RouteId, Msg: "unknown fare product " + rule.FareProductId + " for leg"}
+		}
+
+		legFare := &LegFare{Leg: leg, FareId: product.FareProductId, LegGroupId: rule.LegGroupId, Amount: product.Amount}
+		fare.Currency = product.Currency
+
+		var transfer *gtfspec.FareTransferRule
+		if previous != nil {
+			transfer = c.matchTransferRule(previous.LegGroupId, rule.LegGroupId, transfersUsed, chainStart, leg.Start)
+		}
+
+		if transfer != nil {
+			transferAmount := 0.0
+			if p, ok := c.products[transfer.FareProductId]; ok {
+				transferAmount = p.Amount
+			}
+
+			switch transfer.FareTransferType {
+			case gtfspec.FareTransferAPlusAB:
+				legFare.Amount = transferAmount
+			case gtfspec.FareTransferAPlusABPlusB:
+				legFare.Amount += transferAmount
+			case gtfspec.FareTransferAB:
+				fare.Total -= previous.Amount
+				previous.Amount = 0
+				legFare.Amount = transferAmount
+			}
+			legFare.Transfer = true
+			transfersUsed++
+		} else {
+			chainStart = leg.Start
+			transfersUsed = 0
+		}
+
+		fare.Total += legFare.Amount
+		fare.Legs = append(fare.Legs, legFare)
+		previous = legFare
+	}
+
+	// Report the allowance left for another leg in the same group as the last one.
+	fare.TransfersRemaining = 0
+	for _, rule := range c.transferRules {
+		if rule.FromLegGroupId != previous.LegGroupId || rule.ToLegGroupId != previous.LegGroupId {
+			continue
+		}
+		if rule.TransferCount == gtfspec.UnlimitedTransfers {
+			fare.TransfersRemaining = gtfspec.UnlimitedTransfers
+		} else if rule.TransferCount > transfersUsed {
+			fare.TransfersRemaining = rule.TransferCount - transfersUsed
+		}
+		if rule.DurationLimit > 0 && !chainStart.IsZero() {
+			fare.TransferExpires = chainStart.Add(time.Duration(rule.DurationLimit) * time.Second)
+		}
+		break
+	}
+
+	return fare, nil
+}
+
+// matchLegRule returns the fare leg rule for a leg: the highest rule_priority
+// wins, then the rule that sets the most fields.
+func (c *Calculator) matchLegRule(leg *Leg) *gtfspec.FareLegRule {
+	network := c.routeNetworks[leg.RouteId]
+
+	var best *gtfspec.FareLegRule
+	bestSpecificity := -1
+
+	for _, rule := range c.legRules {
+		specificity := 0
+		if rule.NetworkId != "" {
+			if rule.NetworkId != network {
+				continue
+			}
+			specificity++
+		}
+		if rule.FromAreaId != "" {
+			if rule.FromAreaId != leg.FromAreaId {
+				continue
+			}
+			specificity++
+		}
+		if rule.ToAreaId != "" {
+			if rule.ToAreaId != leg.ToAreaId {
+				continue
+			}
+			specificity++
+		}
+
+		if best == nil ||
+			rule.RulePriority > best.RulePriority ||
+			(rule.RulePriority == best.RulePriority && specificity > bestSpecificity) {
+			best = rule
+			bestSpecificity = specificity
+		}
+	}
+
+	return best
+}
+
+// matchTransferRule returns the transfer rule between two leg groups, if the
+// transfer count and duration limit allow another transfer
+func (c *Calculator) matchTransferRule(fromGroup string, toGroup string, transfersUsed int, chainStart time.Time, start time.Time) *gtfspec.FareTransferRule {
+	for _, rule := range c.transferRules {
+		if rule.FromLegGroupId != "" && rule.FromLegGroupId != fromGroup {
+			continue
+		}
+		if rule.ToLegGroupId != "" && rule.ToLegGroupId != toGroup {
+			continue
+		}
+		if rule.TransferCount != gtfspec.UnlimitedTransfers && transfersUsed >= rule.TransferCount {
+			continue
+		}
+		if rule.DurationLimit > 0 && !chainStart.IsZero() && !start.IsZero() {
+			if start.Sub(chainStart) > time.Duration(rule.DurationLimit)*time.Second {
+				continue
+			}
+		}
+		return rule
+	}
+	return nil
+}
+
+// withinWindow reports whether a leg starting at start is inside a transfer
+// window ending at expires. Unknown times are treated as inside the window.
+func withinWindow(start time.Time, expires time.Time) bool {
+	return expires.IsZero() || start.IsZero() || !start.After(expires)
+}
+
+// containsFare reports whether a fare is among the candidates
+func containsFare(candidates []*gtfspec.FareAttribute, fareId string) bool {
+	for _, candidate := range candidates {
+		if candidate.FareId == fareId {
+			return true
+		}
+	}
+	return false
+}
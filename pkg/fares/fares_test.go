@@ -0,0 +1,70 @@
+package fares
+
+import (
+	"testing"
+	"time"
+
+	"github.com/rmrfslashbin/gomarta/pkg/gtfspec"
+	"github.com/rs/zerolog"
+)
+
+func TestCalculateV2TransferTypes(t *testing.T) {
+	tests := []struct {
+		name         string
+		transferType int
+		want         float64
+	}{
+		// A = 2.50, AB = 0.50, B = 2.50
+		{name: "A + AB", transferType: gtfspec.FareTransferAPlusAB, want: 3.00},
+		{name: "A + AB + B", transferType: gtfspec.FareTransferAPlusABPlusB, want: 5.50},
+		{name: "AB", transferType: gtfspec.FareTransferAB, want: 0.50},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			feed := &gtfspec.Feed{
+				Routes: []*gtfspec.Route{
+					{RouteId: "R1", NetworkId: "BUS"},
+					{RouteId: "R2", NetworkId: "BUS"},
+				},
+				FareProducts: []*gtfspec.FareProduct{
+					{FareProductId: "RIDE", Amount: 2.50, Currency: "USD"},
+					{FareProductId: "TRANSFER", Amount: 0.50, Currency: "USD"},
+				},
+				FareLegRules: []*gtfspec.FareLegRule{
+					{LegGroupId: "LOCAL", NetworkId: "BUS", FareProductId: "RIDE"},
+				},
+				FareTransferRules: []*gtfspec.FareTransferRule{
+					{
+						FromLegGroupId:   "LOCAL",
+						ToLegGroupId:     "LOCAL",
+						TransferCount:    gtfspec.UnlimitedTransfers,
+						DurationLimit:    3600,
+						FareTransferType: tt.transferType,
+						FareProductId:    "TRANSFER",
+					},
+				},
+			}
+			log := zerolog.Nop()
+			calculator, err := New(WithFeed(feed), WithLogger(&log))
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			start := time.Date(2024, 1, 1, 8, 0, 0, 0, time.UTC)
+			fare, err := calculator.Calculate([]*Leg{
+				{RouteId: "R1", Start: start},
+				{RouteId: "R2", Start: start.Add(20 * time.Minute)},
+			})
+			if err != nil {
+				t.Fatalf("Calculate() error = %v", err)
+			}
+			if fare.Total != tt.want {
+				t.Errorf("Calculate() total = %.2f, want %.2f", fare.Total, tt.want)
+			}
+			if !fare.Legs[1].Transfer {
+				t.Errorf("Calculate() second leg not priced as a transfer")
+			}
+		})
+	}
+}
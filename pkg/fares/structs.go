@@ -0,0 +1,54 @@
+package fares
+
+import "time"
+
+// Leg is a single ride on one route, as seen by the fare rules
+type Leg struct {
+	RouteId string
+
+	// OriginZone and DestinationZone are stop zone_ids, used by fare_rules.txt
+	OriginZone      string
+	DestinationZone string
+
+	// ContainsZones are the zones passed through, used by fare_rules.txt contains_id
+	ContainsZones []string
+
+	// FromAreaId and ToAreaId are areas.txt ids, used by fare_leg_rules.txt
+	FromAreaId string
+	ToAreaId   string
+
+	// Start is when the leg departs. It is optional and only used to check transfer windows.
+	Start time.Time
+}
+
+// LegFare is the price charged for a single leg
+type LegFare struct {
+	Leg *Leg
+
+	// FareId is the fare_id (fares v1) or fare_product_id (fares v2) that was applied
+	FareId string
+
+	// LegGroupId is the fares v2 leg group the leg matched, if any
+	LegGroupId string
+
+	// Amount is what this leg adds to the total
+	Amount float64
+
+	// Transfer is true when the leg was priced as a transfer from the previous leg
+	Transfer bool
+}
+
+// Fare is the result of pricing a sequence of legs
+type Fare struct {
+	Total    float64
+	Currency string
+	Legs     []*LegFare
+
+	// TransfersRemaining is how many more transfers the last fare paid allows.
+	// gtfspec.UnlimitedTransfers means there is no limit.
+	TransfersRemaining int
+
+	// TransferExpires is when the last fare's transfer window closes.
+	// It is zero if the fare has no time limit or the legs had no start times.
+	TransferExpires time.Time
+}
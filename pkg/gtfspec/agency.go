@@ -1,15 +1,10 @@
 package gtfspec
 
-import (
-	"fmt"
-
-	"gorm.io/gorm"
-)
+import "time"
 
 // agency_id,agency_name,agency_url,agency_timezone,agency_lang,agency_phone,agency_fare_url
 // MARTA,Metropolitan Atlanta Rapid Transit Authority,https://www.itsmarta.com,America/New_York,en,404-848-5000,https://www.itsmarta.com/fare-programs.aspx
 type Agency struct {
-	gorm.Model
 	AgencyId string `json:"agency_id" gorm:"primaryKey"`
 	Name     string `json:"agency_name"`
 	Url      string `json:"agency_url"`
@@ -19,17 +14,22 @@ type Agency struct {
 	FareUrl  string `json:"agency_fare_url"`
 }
 
+// Add populates the agency from a CSV record of agency.txt.
 func (a *Agency) Add(headers map[string]int, record []string) error {
-	if len(record) != 7 {
-		return fmt.Errorf("invalid agency record length: %d", len(record))
-	}
-	a.AgencyId = record[headers["agency_id"]]
-	a.Name = record[headers["agency_name"]]
-	a.Url = record[headers["agency_url"]]
-	a.Timezone = record[headers["agency_timezone"]]
-	a.Lang = record[headers["agency_lang"]]
-	a.Phone = record[headers["agency_phone"]]
-	a.FareUrl = record[headers["agency_fare_url"]]
+	row := newRow(headers, record)
+
+	a.AgencyId = row.optionalString("agency_id")
+	a.Name = row.requiredString("agency_name")
+	a.Url = row.requiredString("agency_url")
+	a.Timezone = row.requiredString("agency_timezone")
+	a.Lang = row.optionalString("agency_lang")
+	a.Phone = row.optionalString("agency_phone")
+	a.FareUrl = row.optionalString("agency_fare_url")
+
+	return row.err()
+}
 
-	return nil
+// Location returns the agency timezone, which GTFS times are expressed in.
+func (a *Agency) Location() (*time.Location, error) {
+	return time.LoadLocation(a.Timezone)
 }
@@ -0,0 +1,18 @@
+package gtfspec
+
+// area_id,area_name
+// AIRPORT,Airport Station
+type Area struct {
+	AreaId string `json:"area_id" gorm:"primaryKey"`
+	Name   string `json:"area_name"`
+}
+
+// Add populates the area from a CSV record of areas.txt.
+func (a *Area) Add(headers map[string]int, record []string) error {
+	row := newRow(headers, record)
+
+	a.AreaId = row.requiredString("area_id")
+	a.Name = row.optionalString("area_name")
+
+	return row.err()
+}
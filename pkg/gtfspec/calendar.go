@@ -1,18 +1,11 @@
 package gtfspec
 
-import (
-	"fmt"
-	"strconv"
-	"time"
-
-	"gorm.io/gorm"
-)
+import "time"
 
 // service_id,monday,tuesday,wednesday,thursday,friday,saturday,sunday,start_date,end_date
 // 20,0,0,0,0,0,0,0,20220423,20220812
 type Calendar struct {
-	gorm.Model
-	ServiceId int       `json:"service_id" gorm:"primaryKey"`
+	ServiceId string    `json:"service_id" gorm:"primaryKey"`
 	Monday    int       `json:"monday"`
 	Tuesday   int       `json:"tuesday"`
 	Wednesday int       `json:"wednesday"`
@@ -24,45 +17,41 @@ type Calendar struct {
 	EndDate   time.Time `json:"end_date"`
 }
 
+// Add populates the calendar from a CSV record of calendar.txt.
 func (c *Calendar) Add(headers map[string]int, record []string) error {
-	if len(record) != 10 {
-		return fmt.Errorf("invalid calendar record length: %d", len(record))
-	}
-
-	var err error
-
-	c.ServiceId, err = strconv.Atoi(record[headers["service_id"]])
-	if err != nil {
-		return fmt.Errorf("service_id: %v", err)
-	}
-
-	if c.Monday, err = strconv.Atoi(record[headers["monday"]]); err != nil {
-		return fmt.Errorf("monday: %v", err)
-	}
-	if c.Tuesday, err = strconv.Atoi(record[headers["tuesday"]]); err != nil {
-		return fmt.Errorf("tuesday: %v", err)
-	}
-	if c.Wednesday, err = strconv.Atoi(record[headers["wednesday"]]); err != nil {
-		return fmt.Errorf("wednesday: %v", err)
-	}
-	if c.Thursday, err = strconv.Atoi(record[headers["thursday"]]); err != nil {
-		return fmt.Errorf("thursday: %v", err)
-	}
-	if c.Friday, err = strconv.Atoi(record[headers["friday"]]); err != nil {
-		return fmt.Errorf("friday: %v", err)
-	}
-	if c.Saturday, err = strconv.Atoi(record[headers["saturday"]]); err != nil {
-		return fmt.Errorf("saturday: %v", err)
-	}
-	if c.Sunday, err = strconv.Atoi(record[headers["sunday"]]); err != nil {
-		return fmt.Errorf("sunday: %v", err)
-	}
-	if c.StartDate, err = time.Parse("20060102", record[headers["start_date"]]); err != nil {
-		return fmt.Errorf("start_date: %v", err)
-	}
-	if c.EndDate, err = time.Parse("20060102", record[headers["end_date"]]); err != nil {
-		return fmt.Errorf("end_date: %v", err)
-	}
+	row := newRow(headers, record)
+
+	c.ServiceId = row.requiredString("service_id")
+	c.Monday = row.requiredInt("monday")
+	c.Tuesday = row.requiredInt("tuesday")
+	c.Wednesday = row.requiredInt("wednesday")
+	c.Thursday = row.requiredInt("thursday")
+	c.Friday = row.requiredInt("friday")
+	c.Saturday = row.requiredInt("saturday")
+	c.Sunday = row.requiredInt("sunday")
+	c.StartDate = row.requiredDate("start_date")
+	c.EndDate = row.requiredDate("end_date")
+
+	return row.err()
+}
 
-	return nil
+// RunsOn reports whether the calendar's weekday flag is set for a day of the week.
+func (c *Calendar) RunsOn(weekday time.Weekday) bool {
+	switch weekday {
+	case time.Monday:
+		return c.Monday == 1
+	case time.Tuesday:
+		return c.Tuesday == 1
+	case time.Wednesday:
+		return c.Wednesday == 1
+	case time.Thursday:
+		return c.Thursday == 1
+	case time.Friday:
+		return c.Friday == 1
+	case time.Saturday:
+		return c.Saturday == 1
+	case time.Sunday:
+		return c.Sunday == 1
+	}
+	return false
 }
@@ -1,37 +1,28 @@
 package gtfspec
 
-import (
-	"fmt"
-	"strconv"
-	"time"
+import "time"
 
-	"gorm.io/gorm"
+// Calendar date exception types
+const (
+	ExceptionAdded   = 1
+	ExceptionRemoved = 2
 )
 
 // service_id,date,exception_type
 // 34,20220530,1
 type CalendarDate struct {
-	gorm.Model
-	ServiceId     int       `json:"service_id" gorm:"primaryKey"`
+	ServiceId     string    `json:"service_id" gorm:"primaryKey"`
 	Date          time.Time `json:"date" gorm:"primaryKey"`
 	ExceptionType int       `json:"exception_type"`
 }
 
+// Add populates the calendar date from a CSV record of calendar_dates.txt.
 func (c *CalendarDate) Add(headers map[string]int, record []string) error {
-	if len(record) != 3 {
-		return fmt.Errorf("invalid calendar_date record length: %d", len(record))
-	}
+	row := newRow(headers, record)
 
-	var err error
-	if c.ServiceId, err = strconv.Atoi(record[headers["service_id"]]); err != nil {
-		return fmt.Errorf("service_id: %v", err)
-	}
-	if c.Date, err = time.Parse("20060102", record[headers["date"]]); err != nil {
-		return fmt.Errorf("date: %v", err)
-	}
-	if c.ExceptionType, err = strconv.Atoi(record[headers["exception_type"]]); err != nil {
-		return fmt.Errorf("exception_type: %v", err)
-	}
+	c.ServiceId = row.requiredString("service_id")
+	c.Date = row.requiredDate("date")
+	c.ExceptionType = row.requiredInt("exception_type")
 
-	return nil
+	return row.err()
 }
@@ -0,0 +1,75 @@
+package gtfspec
+
+import "strings"
+
+// ErrField is returned when a single column of a record cannot be parsed.
+type ErrField struct {
+	Err      error
+	Column   string
+	Value    string
+	Required bool
+	Msg      string
+}
+
+// Error returns the error message.
+func (e *ErrField) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "error parsing field"
+	}
+	if e.Column != "" {
+		msg += ": " + e.Column
+	}
+	if e.Value != "" {
+		msg += ": \"" + e.Value + "\""
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error.
+func (e *ErrField) Unwrap() error {
+	return e.Err
+}
+
+// ErrMissingValue is returned when a required column is absent or empty.
+type ErrMissingValue struct {
+	Err error
+	Msg string
+}
+
+// Error returns the error message.
+func (e *ErrMissingValue) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "missing required value"
+	}
+	if e.Err != nil {
+		msg += ": " + e.Err.Error()
+	}
+	return msg
+}
+
+// ErrRecord is returned when one or more columns of a record cannot be parsed.
+type ErrRecord struct {
+	Fields []*ErrField
+	Msg    string
+}
+
+// Error returns the error message.
+func (e *ErrRecord) Error() string {
+	msg := e.Msg
+	if msg == "" {
+		msg = "error parsing record"
+	}
+	msgs := make([]string, len(e.Fields))
+	for i, field := range e.Fields {
+		msgs[i] = field.Error()
+	}
+	if len(msgs) > 0 {
+		msg += ": " + strings.Join(msgs, "; ")
+	}
+	return msg
+}
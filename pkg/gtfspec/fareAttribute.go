@@ -0,0 +1,32 @@
+package gtfspec
+
+// UnlimitedTransfers is the Transfers value of a fare that allows any number of transfers.
+const UnlimitedTransfers = -1
+
+// fare_id,price,currency_type,payment_method,transfers,agency_id,transfer_duration
+// REGULAR,2.50,USD,0,,MARTA,10800
+type FareAttribute struct {
+	FareId           string  `json:"fare_id" gorm:"primaryKey"`
+	Price            float64 `json:"price"`
+	CurrencyType     string  `json:"currency_type"`
+	PaymentMethod    int     `json:"payment_method"`
+	Transfers        int     `json:"transfers"`
+	AgencyId         string  `json:"agency_id"`
+	TransferDuration int     `json:"transfer_duration"`
+}
+
+// Add populates the fare attribute from a CSV record of fare_attributes.txt.
+func (f *FareAttribute) Add(headers map[string]int, record []string) error {
+	row := newRow(headers, record)
+
+	f.FareId = row.requiredString("fare_id")
+	f.Price = row.requiredFloat("price")
+	f.CurrencyType = row.requiredString("currency_type")
+	f.PaymentMethod = row.requiredInt("payment_method")
+	// An empty transfers field means unlimited transfers are permitted.
+	f.Transfers = row.optionalInt("transfers", UnlimitedTransfers)
+	f.AgencyId = row.optionalString("agency_id")
+	f.TransferDuration = row.optionalInt("transfer_duration", 0)
+
+	return row.err()
+}
@@ -0,0 +1,30 @@
+package gtfspec
+
+// leg_group_id,network_id,from_area_id,to_area_id,from_timeframe_group_id,to_timeframe_group_id,fare_product_id,rule_priority
+// MARTA_LOCAL,MARTA_BUS,,,,,ONE_WAY,0
+type FareLegRule struct {
+	LegGroupId           string `json:"leg_group_id"`
+	NetworkId            string `json:"network_id" gorm:"primaryKey"`
+	FromAreaId           string `json:"from_area_id" gorm:"primaryKey"`
+	ToAreaId             string `json:"to_area_id" gorm:"primaryKey"`
+	FromTimeframeGroupId string `json:"from_timeframe_group_id" gorm:"primaryKey"`
+	ToTimeframeGroupId   string `json:"to_timeframe_group_id" gorm:"primaryKey"`
+	FareProductId        string `json:"fare_product_id" gorm:"primaryKey"`
+	RulePriority         int    `json:"rule_priority"`
+}
+
+// Add populates the fare leg rule from a CSV record of fare_leg_rules.txt.
+func (f *FareLegRule) Add(headers map[string]int, record []string) error {
+	row := newRow(headers, record)
+
+	f.LegGroupId = row.optionalString("leg_group_id")
+	f.NetworkId = row.optionalString("network_id")
+	f.FromAreaId = row.optionalString("from_area_id")
+	f.ToAreaId = row.optionalString("to_area_id")
+	f.FromTimeframeGroupId = row.optionalString("from_timeframe_group_id")
+	f.ToTimeframeGroupId = row.optionalString("to_timeframe_group_id")
+	f.FareProductId = row.requiredString("fare_product_id")
+	f.RulePriority = row.optionalInt("rule_priority", 0)
+
+	return row.err()
+}
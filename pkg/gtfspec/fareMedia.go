@@ -0,0 +1,20 @@
+package gtfspec
+
+// fare_media_id,fare_media_name,fare_media_type
+// BREEZE_CARD,Breeze Card,2
+type FareMedia struct {
+	FareMediaId string `json:"fare_media_id" gorm:"primaryKey"`
+	Name        string `json:"fare_media_name"`
+	Type        int    `json:"fare_media_type"`
+}
+
+// Add populates the fare media from a CSV record of fare_media.txt.
+func (f *FareMedia) Add(headers map[string]int, record []string) error {
+	row := newRow(headers, record)
+
+	f.FareMediaId = row.requiredString("fare_media_id")
+	f.Name = row.optionalString("fare_media_name")
+	f.Type = row.requiredInt("fare_media_type")
+
+	return row.err()
+}
@@ -0,0 +1,24 @@
+package gtfspec
+
+// fare_product_id,fare_product_name,fare_media_id,amount,currency
+// ONE_WAY,One-Way Trip,BREEZE_CARD,2.50,USD
+type FareProduct struct {
+	FareProductId string  `json:"fare_product_id" gorm:"primaryKey"`
+	Name          string  `json:"fare_product_name"`
+	FareMediaId   string  `json:"fare_media_id" gorm:"primaryKey"`
+	Amount        float64 `json:"amount"`
+	Currency      string  `json:"currency"`
+}
+
+// Add populates the fare product from a CSV record of fare_products.txt.
+func (f *FareProduct) Add(headers map[string]int, record []string) error {
+	row := newRow(headers, record)
+
+	f.FareProductId = row.requiredString("fare_product_id")
+	f.Name = row.optionalString("fare_product_name")
+	f.FareMediaId = row.optionalString("fare_media_id")
+	f.Amount = row.requiredFloat("amount")
+	f.Currency = row.requiredString("currency")
+
+	return row.err()
+}
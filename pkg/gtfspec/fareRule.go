@@ -0,0 +1,24 @@
+package gtfspec
+
+// fare_id,route_id,origin_id,destination_id,contains_id
+// REGULAR,,,,
+type FareRule struct {
+	FareId        string `json:"fare_id" gorm:"primaryKey"`
+	RouteId       string `json:"route_id" gorm:"primaryKey"`
+	OriginId      string `json:"origin_id" gorm:"primaryKey"`
+	DestinationId string `json:"destination_id" gorm:"primaryKey"`
+	ContainsId    string `json:"contains_id" gorm:"primaryKey"`
+}
+
+// Add populates the fare rule from a CSV record of fare_rules.txt.
+func (f *FareRule) Add(headers map[string]int, record []string) error {
+	row := newRow(headers, record)
+
+	f.FareId = row.requiredString("fare_id")
+	f.RouteId = row.optionalString("route_id")
+	f.OriginId = row.optionalString("origin_id")
+	f.DestinationId = row.optionalString("destination_id")
+	f.ContainsId = row.optionalString("contains_id")
+
+	return row.err()
+}
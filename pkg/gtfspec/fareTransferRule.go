@@ -0,0 +1,40 @@
+package gtfspec
+
+// Fare transfer types
+const (
+	// FareTransferAPlusAB charges the first leg and the transfer.
+	FareTransferAPlusAB = 0
+
+	// FareTransferAPlusABPlusB charges the first leg, the transfer and the second leg.
+	FareTransferAPlusABPlusB = 1
+
+	// FareTransferAB charges only the transfer.
+	FareTransferAB = 2
+)
+
+// from_leg_group_id,to_leg_group_id,transfer_count,duration_limit,duration_limit_type,fare_transfer_type,fare_product_id
+// MARTA_LOCAL,MARTA_LOCAL,-1,10800,1,1,
+type FareTransferRule struct {
+	FromLegGroupId    string `json:"from_leg_group_id" gorm:"primaryKey"`
+	ToLegGroupId      string `json:"to_leg_group_id" gorm:"primaryKey"`
+	TransferCount     int    `json:"transfer_count" gorm:"primaryKey;autoIncrement:false"`
+	DurationLimit     int    `json:"duration_limit" gorm:"primaryKey;autoIncrement:false"`
+	DurationLimitType int    `json:"duration_limit_type"`
+	FareTransferType  int    `json:"fare_transfer_type"`
+	FareProductId     string `json:"fare_product_id" gorm:"primaryKey"`
+}
+
+// Add populates the fare transfer rule from a CSV record of fare_transfer_rules.txt.
+func (f *FareTransferRule) Add(headers map[string]int, record []string) error {
+	row := newRow(headers, record)
+
+	f.FromLegGroupId = row.optionalString("from_leg_group_id")
+	f.ToLegGroupId = row.optionalString("to_leg_group_id")
+	f.TransferCount = row.optionalInt("transfer_count", UnlimitedTransfers)
+	f.DurationLimit = row.optionalInt("duration_limit", 0)
+	f.DurationLimitType = row.optionalInt("duration_limit_type", 0)
+	f.FareTransferType = row.requiredInt("fare_transfer_type")
+	f.FareProductId = row.optionalString("fare_product_id")
+
+	return row.err()
+}
@@ -0,0 +1,28 @@
+package gtfspec
+
+// Feed holds the parsed contents of a GTFS static feed.
+type Feed struct {
+	Agencies          []*Agency
+	Areas             []*Area
+	Calendars         []*Calendar
+	CalendarDates     []*CalendarDate
+	FareAttributes    []*FareAttribute
+	FareLegRules      []*FareLegRule
+	FareMedia         []*FareMedia
+	FareProducts      []*FareProduct
+	FareRules         []*FareRule
+	FareTransferRules []*FareTransferRule
+	FeedInfo          []*FeedInfo
+	Frequencies       []*Frequency
+	Levels            []*Level
+	Networks          []*Network
+	Pathways          []*Pathway
+	RouteNetworks     []*RouteNetwork
+	Routes            []*Route
+	Shapes            []*Shape
+	StopAreas         []*StopArea
+	Stops             []*Stop
+	StopTimes         []*StopTime
+	Transfers         []*Transfer
+	Trips             []*Trip
+}
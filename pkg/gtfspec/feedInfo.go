@@ -0,0 +1,37 @@
+package gtfspec
+
+import "time"
+
+// feed_publisher_name,feed_publisher_url,feed_lang,default_lang,feed_start_date,feed_end_date,feed_version,feed_contact_email,feed_contact_url
+// MARTA,https://www.itsmarta.com,en,,20240420,20240816,20240420,,
+type FeedInfo struct {
+	// Id is a surrogate key; feed_info.txt has no natural one
+	Id uint `json:"-" gorm:"primaryKey"`
+
+	PublisherName string    `json:"feed_publisher_name"`
+	PublisherUrl  string    `json:"feed_publisher_url"`
+	Lang          string    `json:"feed_lang"`
+	DefaultLang   string    `json:"default_lang"`
+	StartDate     time.Time `json:"feed_start_date"`
+	EndDate       time.Time `json:"feed_end_date"`
+	Version       string    `json:"feed_version"`
+	ContactEmail  string    `json:"feed_contact_email"`
+	ContactUrl    string    `json:"feed_contact_url"`
+}
+
+// Add populates the feed info from a CSV record of feed_info.txt.
+func (f *FeedInfo) Add(headers map[string]int, record []string) error {
+	row := newRow(headers, record)
+
+	f.PublisherName = row.requiredString("feed_publisher_name")
+	f.PublisherUrl = row.requiredString("feed_publisher_url")
+	f.Lang = row.requiredString("feed_lang")
+	f.DefaultLang = row.optionalString("default_lang")
+	f.StartDate = row.optionalDate("feed_start_date")
+	f.EndDate = row.optionalDate("feed_end_date")
+	f.Version = row.optionalString("feed_version")
+	f.ContactEmail = row.optionalString("feed_contact_email")
+	f.ContactUrl = row.optionalString("feed_contact_url")
+
+	return row.err()
+}
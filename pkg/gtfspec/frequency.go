@@ -0,0 +1,24 @@
+package gtfspec
+
+// trip_id,start_time,end_time,headway_secs,exact_times
+// 7142673,06:00:00,09:00:00,600,0
+type Frequency struct {
+	TripId      string   `json:"trip_id" gorm:"primaryKey"`
+	StartTime   GTFSTime `json:"start_time" gorm:"primaryKey"`
+	EndTime     GTFSTime `json:"end_time"`
+	HeadwaySecs int      `json:"headway_secs"`
+	ExactTimes  int      `json:"exact_times"`
+}
+
+// Add populates the frequency from a CSV record of frequencies.txt.
+func (f *Frequency) Add(headers map[string]int, record []string) error {
+	row := newRow(headers, record)
+
+	f.TripId = row.requiredString("trip_id")
+	f.StartTime = row.requiredTime("start_time")
+	f.EndTime = row.requiredTime("end_time")
+	f.HeadwaySecs = row.requiredInt("headway_secs")
+	f.ExactTimes = row.optionalInt("exact_times", 0)
+
+	return row.err()
+}
@@ -0,0 +1,160 @@
+package gtfspec
+
+import (
+	"database/sql/driver"
+	"encoding/json"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// GTFSTime is a GTFS time of day: the number of seconds since "noon minus 12h"
+// of the service date. Trips that run past midnight use values of 24:00:00 or
+// more, so GTFSTime sorts in service order. It is stored as an integer column
+// so it can be range-queried in SQL; unset times are stored as -1.
+type GTFSTime int
+
+// NoTime is the value of an unset GTFSTime, such as the arrival time of a
+// stop that isn't a timepoint.
+const NoTime GTFSTime = -1
+
+// ParseGTFSTime parses an H:MM:SS or HH:MM:SS time. Surrounding whitespace,
+// such as the leading space MARTA emits before single digit hours, is ignored.
+func ParseGTFSTime(s string) (GTFSTime, error) {
+	parts := strings.Split(strings.TrimSpace(s), ":")
+	if len(parts) != 3 {
+		return NoTime, fmt.Errorf("invalid time %q: expected HH:MM:SS", s)
+	}
+
+	hours, err := strconv.Atoi(parts[0])
+	if err != nil || hours < 0 {
+		return NoTime, fmt.Errorf("invalid hours in time %q", s)
+	}
+	minutes, err := strconv.Atoi(parts[1])
+	if err != nil || minutes < 0 || minutes > 59 || len(parts[1]) != 2 {
+		return NoTime, fmt.Errorf("invalid minutes in time %q", s)
+	}
+	seconds, err := strconv.Atoi(parts[2])
+	if err != nil || seconds < 0 || seconds > 59 || len(parts[2]) != 2 {
+		return NoTime, fmt.Errorf("invalid seconds in time %q", s)
+	}
+
+	return GTFSTime(hours*3600 + minutes*60 + seconds), nil
+}
+
+// GTFSTimeOf returns the GTFSTime of an absolute time relative to a service date in loc.
+// The result is negative if t is before the start of the service day.
+func GTFSTimeOf(t time.Time, serviceDate time.Time, loc *time.Location) GTFSTime {
+	return GTFSTime(t.Sub(ServiceDayStart(serviceDate, loc)) / time.Second)
+}
+
+// ServiceDayStart returns "noon minus 12h" of a service date in loc, the
+// reference point GTFS times are measured from. On days with a daylight
+// saving change this differs from midnight by an hour.
+func ServiceDayStart(serviceDate time.Time, loc *time.Location) time.Time {
+	year, month, day := serviceDate.Date()
+	return time.Date(year, month, day, 12, 0, 0, 0, loc).Add(-12 * time.Hour)
+}
+
+// IsSet reports whether the time has a value.
+func (t GTFSTime) IsSet() bool {
+	return t >= 0
+}
+
+// Seconds returns the number of seconds since the start of the service day.
+func (t GTFSTime) Seconds() int {
+	return int(t)
+}
+
+// Duration returns the time since the start of the service day.
+func (t GTFSTime) Duration() time.Duration {
+	return time.Duration(t) * time.Second
+}
+
+// Add returns the time shifted by a number of seconds.
+func (t GTFSTime) Add(seconds int) GTFSTime {
+	return t + GTFSTime(seconds)
+}
+
+// Time returns the absolute time for a service date in loc, typically the agency timezone.
+func (t GTFSTime) Time(serviceDate time.Time, loc *time.Location) time.Time {
+	return ServiceDayStart(serviceDate, loc).Add(t.Duration())
+}
+
+// String returns the time as HH:MM:SS, or "" if it is unset.
+func (t GTFSTime) String() string {
+	if !t.IsSet() {
+		return ""
+	}
+	return fmt.Sprintf("%02d:%02d:%02d", t/3600, t%3600/60, t%60)
+}
+
+// MarshalJSON encodes the time as an HH:MM:SS string, or null if it is unset.
+func (t GTFSTime) MarshalJSON() ([]byte, error) {
+	if !t.IsSet() {
+		return []byte("null"), nil
+	}
+	return json.Marshal(t.String())
+}
+
+// UnmarshalJSON decodes an HH:MM:SS string or null.
+func (t *GTFSTime) UnmarshalJSON(data []byte) error {
+	var s *string
+	if err := json.Unmarshal(data, &s); err != nil {
+		return err
+	}
+	if s == nil || *s == "" {
+		*t = NoTime
+		return nil
+	}
+	parsed, err := ParseGTFSTime(*s)
+	if err != nil {
+		return err
+	}
+	*t = parsed
+	return nil
+}
+
+// Value stores the time as seconds, or -1 if it is unset.
+func (t GTFSTime) Value() (driver.Value, error) {
+	if !t.IsSet() {
+		return int64(NoTime), nil
+	}
+	return int64(t), nil
+}
+
+// Scan reads a time stored by Value.
+func (t *GTFSTime) Scan(value interface{}) error {
+	switch v := value.(type) {
+	case nil:
+		*t = NoTime
+	case int64:
+		*t = GTFSTime(v)
+	case int32:
+		*t = GTFSTime(v)
+	case float64:
+		*t = GTFSTime(v)
+	case []byte:
+		return t.Scan(string(v))
+	case string:
+		if i, err := strconv.Atoi(v); err == nil {
+			*t = GTFSTime(i)
+			return nil
+		}
+		// Databases created before times were stored as seconds hold
+		// them as HH:MM:SS text, empty when unset.
+		if strings.TrimSpace(v) == "" {
+			*t = NoTime
+			return nil
+		}
+		parsed, err := ParseGTFSTime(v)
+		if err != nil {
+			return fmt.Errorf("cannot scan %q into GTFSTime: %v", v, err)
+		}
+		*t = parsed
+	default:
+		return fmt.Errorf("cannot scan %T into GTFSTime", value)
+	}
+	return nil
+}
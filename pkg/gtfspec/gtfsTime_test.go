@@ -0,0 +1,161 @@
+package gtfspec
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func TestParseGTFSTime(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    GTFSTime
+		wantErr bool
+	}{
+		{in: "00:00:00", want: 0},
+		{in: "08:05:09", want: 8*3600 + 5*60 + 9},
+		{in: "8:05:09", want: 8*3600 + 5*60 + 9},
+		{in: " 6:43:00", want: 6*3600 + 43*60},
+		{in: "23:59:59", want: 86399},
+		{in: "24:00:00", want: 86400},
+		{in: "25:10:30", want: 25*3600 + 10*60 + 30},
+		{in: "47:59:59", want: 47*3600 + 59*60 + 59},
+		{in: "", wantErr: true},
+		{in: "   ", wantErr: true},
+		{in: "08:05", wantErr: true},
+		{in: "08:5:00", wantErr: true},
+		{in: "08:60:00", wantErr: true},
+		{in: "08:00:60", wantErr: true},
+		{in: "-1:00:00", wantErr: true},
+		{in: "aa:bb:cc", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.in, func(t *testing.T) {
+			got, err := ParseGTFSTime(tt.in)
+			if tt.wantErr {
+				if err == nil {
+					t.Fatalf("ParseGTFSTime(%q) = %v, want error", tt.in, got)
+				}
+				if got != NoTime {
+					t.Errorf("ParseGTFSTime(%q) = %v on error, want NoTime", tt.in, got)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("ParseGTFSTime(%q) error = %v", tt.in, err)
+			}
+			if got != tt.want {
+				t.Errorf("ParseGTFSTime(%q) = %d, want %d", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGTFSTimeString(t *testing.T) {
+	tests := []struct {
+		in   GTFSTime
+		want string
+	}{
+		{in: NoTime, want: ""},
+		{in: 0, want: "00:00:00"},
+		{in: 8*3600 + 5*60 + 9, want: "08:05:09"},
+		{in: 86400, want: "24:00:00"},
+		{in: 25*3600 + 10*60 + 30, want: "25:10:30"},
+		{in: 100*3600 + 1, want: "100:00:01"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.want, func(t *testing.T) {
+			if got := tt.in.String(); got != tt.want {
+				t.Errorf("GTFSTime(%d).String() = %q, want %q", tt.in, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGTFSTimeRoundTrip(t *testing.T) {
+	for _, in := range []string{"00:00:00", "06:43:00", "23:59:59", "24:00:00", "25:10:30", "47:59:59"} {
+		t.Run(in, func(t *testing.T) {
+			parsed, err := ParseGTFSTime(in)
+			if err != nil {
+				t.Fatalf("ParseGTFSTime(%q) error = %v", in, err)
+			}
+			if got := parsed.String(); got != in {
+				t.Errorf("String() = %q, want %q", got, in)
+			}
+
+			b, err := json.Marshal(parsed)
+			if err != nil {
+				t.Fatalf("MarshalJSON() error = %v", err)
+			}
+			var decoded GTFSTime
+			if err := json.Unmarshal(b, &decoded); err != nil {
+				t.Fatalf("UnmarshalJSON(%s) error = %v", b, err)
+			}
+			if decoded != parsed {
+				t.Errorf("JSON round trip = %v, want %v", decoded, parsed)
+			}
+
+			value, err := parsed.Value()
+			if err != nil {
+				t.Fatalf("Value() error = %v", err)
+			}
+			var scanned GTFSTime
+			if err := scanned.Scan(value); err != nil {
+				t.Fatalf("Scan(%v) error = %v", value, err)
+			}
+			if scanned != parsed {
+				t.Errorf("database round trip = %v, want %v", scanned, parsed)
+			}
+		})
+	}
+}
+
+func TestGTFSTimeUnset(t *testing.T) {
+	b, err := json.Marshal(NoTime)
+	if err != nil {
+		t.Fatalf("MarshalJSON() error = %v", err)
+	}
+	if string(b) != "null" {
+		t.Errorf("MarshalJSON(NoTime) = %s, want null", b)
+	}
+
+	for _, in := range []string{"null", `""`} {
+		var decoded GTFSTime
+		if err := json.Unmarshal([]byte(in), &decoded); err != nil {
+			t.Fatalf("UnmarshalJSON(%s) error = %v", in, err)
+		}
+		if decoded != NoTime {
+			t.Errorf("UnmarshalJSON(%s) = %v, want NoTime", in, decoded)
+		}
+	}
+
+	value, err := NoTime.Value()
+	if err != nil {
+		t.Fatalf("Value() error = %v", err)
+	}
+	if value != int64(-1) {
+		t.Errorf("Value(NoTime) = %v, want -1", value)
+	}
+
+	// Unset times are stored as -1, and databases created before times were
+	// stored as seconds hold empty text or NULL.
+	for _, in := range []interface{}{int64(-1), nil, "", []byte("")} {
+		scanned := GTFSTime(0)
+		if err := scanned.Scan(in); err != nil {
+			t.Fatalf("Scan(%#v) error = %v", in, err)
+		}
+		if scanned != NoTime {
+			t.Errorf("Scan(%#v) = %v, want NoTime", in, scanned)
+		}
+	}
+
+	// Legacy HH:MM:SS text is read too.
+	var legacy GTFSTime
+	if err := legacy.Scan("25:10:30"); err != nil {
+		t.Fatalf("Scan(legacy text) error = %v", err)
+	}
+	if legacy != 25*3600+10*60+30 {
+		t.Errorf("Scan(legacy text) = %d, want %d", legacy, 25*3600+10*60+30)
+	}
+}
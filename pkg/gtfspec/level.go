@@ -0,0 +1,20 @@
+package gtfspec
+
+// level_id,level_index,level_name
+// FIVE_POINTS_L1,-1,Concourse
+type Level struct {
+	LevelId string  `json:"level_id" gorm:"primaryKey"`
+	Index   float64 `json:"level_index"`
+	Name    string  `json:"level_name"`
+}
+
+// Add populates the level from a CSV record of levels.txt.
+func (l *Level) Add(headers map[string]int, record []string) error {
+	row := newRow(headers, record)
+
+	l.LevelId = row.requiredString("level_id")
+	l.Index = row.requiredFloat("level_index")
+	l.Name = row.optionalString("level_name")
+
+	return row.err()
+}
@@ -0,0 +1,142 @@
+package gtfspec
+
+// NamespaceSeparator separates a feed namespace from the id it qualifies
+const NamespaceSeparator = ":"
+
+// Namespaced returns an id qualified by a namespace, e.g. "cobblinc:10".
+// Empty ids, and every id when the namespace is empty, are left unchanged.
+func Namespaced(namespace string, id string) string {
+	if namespace == "" || id == "" {
+		return id
+	}
+	return namespace + NamespaceSeparator + id
+}
+
+// Namespace qualifies every id in the feed with a namespace so several feeds
+// can be stored side by side without their ids colliding. Routes and fares
+// that omit agency_id are given the id of the feed's only agency first, since
+// the merged data has more than one.
+func (f *Feed) Namespace(namespace string) {
+	if namespace == "" {
+		return
+	}
+	ns := func(id string) string {
+		return Namespaced(namespace, id)
+	}
+
+	if len(f.Agencies) == 1 {
+		if f.Agencies[0].AgencyId == "" {
+			f.Agencies[0].AgencyId = namespace
+		}
+		for _, route := range f.Routes {
+			if route.AgencyId == "" {
+				route.AgencyId = f.Agencies[0].AgencyId
+			}
+		}
+		for _, fare := range f.FareAttributes {
+			if fare.AgencyId == "" {
+				fare.AgencyId = f.Agencies[0].AgencyId
+			}
+		}
+	}
+
+	for _, agency := range f.Agencies {
+		agency.AgencyId = ns(agency.AgencyId)
+	}
+	for _, area := range f.Areas {
+		area.AreaId = ns(area.AreaId)
+	}
+	for _, calendar := range f.Calendars {
+		calendar.ServiceId = ns(calendar.ServiceId)
+	}
+	for _, date := range f.CalendarDates {
+		date.ServiceId = ns(date.ServiceId)
+	}
+	for _, fare := range f.FareAttributes {
+		fare.FareId = ns(fare.FareId)
+		fare.AgencyId = ns(fare.AgencyId)
+	}
+	for _, rule := range f.FareLegRules {
+		rule.LegGroupId = ns(rule.LegGroupId)
+		rule.NetworkId = ns(rule.NetworkId)
+		rule.FromAreaId = ns(rule.FromAreaId)
+		rule.ToAreaId = ns(rule.ToAreaId)
+		rule.FromTimeframeGroupId = ns(rule.FromTimeframeGroupId)
+		rule.ToTimeframeGroupId = ns(rule.ToTimeframeGroupId)
+		rule.FareProductId = ns(rule.FareProductId)
+	}
+	for _, media := range f.FareMedia {
+		media.FareMediaId = ns(media.FareMediaId)
+	}
+	for _, product := range f.FareProducts {
+		product.FareProductId = ns(product.FareProductId)
+		product.FareMediaId = ns(product.FareMediaId)
+	}
+	for _, rule := range f.FareRules {
+		rule.FareId = ns(rule.FareId)
+		rule.RouteId = ns(rule.RouteId)
+		rule.OriginId = ns(rule.OriginId)
+		rule.DestinationId = ns(rule.DestinationId)
+		rule.ContainsId = ns(rule.ContainsId)
+	}
+	for _, rule := range f.FareTransferRules {
+		rule.FromLegGroupId = ns(rule.FromLegGroupId)
+		rule.ToLegGroupId = ns(rule.ToLegGroupId)
+		rule.FareProductId = ns(rule.FareProductId)
+	}
+	for _, frequency := range f.Frequencies {
+		frequency.TripId = ns(frequency.TripId)
+	}
+	for _, level := range f.Levels {
+		level.LevelId = ns(level.LevelId)
+	}
+	for _, network := range f.Networks {
+		network.NetworkId = ns(network.NetworkId)
+	}
+	for _, pathway := range f.Pathways {
+		pathway.PathwayId = ns(pathway.PathwayId)
+		pathway.FromStopId = ns(pathway.FromStopId)
+		pathway.ToStopId = ns(pathway.ToStopId)
+	}
+	for _, routeNetwork := range f.RouteNetworks {
+		routeNetwork.NetworkId = ns(routeNetwork.NetworkId)
+		routeNetwork.RouteId = ns(routeNetwork.RouteId)
+	}
+	for _, route := range f.Routes {
+		route.RouteId = ns(route.RouteId)
+		route.AgencyId = ns(route.AgencyId)
+		route.NetworkId = ns(route.NetworkId)
+	}
+	for _, shape := range f.Shapes {
+		shape.ShapeId = ns(shape.ShapeId)
+	}
+	for _, stopArea := range f.StopAreas {
+		stopArea.AreaId = ns(stopArea.AreaId)
+		stopArea.StopId = ns(stopArea.StopId)
+	}
+	for _, stop := range f.Stops {
+		stop.StopId = ns(stop.StopId)
+		stop.ZoneId = ns(stop.ZoneId)
+		stop.ParentStation = ns(stop.ParentStation)
+		stop.LevelId = ns(stop.LevelId)
+	}
+	for _, stopTime := range f.StopTimes {
+		stopTime.TripId = ns(stopTime.TripId)
+		stopTime.StopId = ns(stopTime.StopId)
+	}
+	for _, transfer := range f.Transfers {
+		transfer.FromStopId = ns(transfer.FromStopId)
+		transfer.ToStopId = ns(transfer.ToStopId)
+		transfer.FromRouteId = ns(transfer.FromRouteId)
+		transfer.ToRouteId = ns(transfer.ToRouteId)
+		transfer.FromTripId = ns(transfer.FromTripId)
+		transfer.ToTripId = ns(transfer.ToTripId)
+	}
+	for _, trip := range f.Trips {
+		trip.RouteId = ns(trip.RouteId)
+		trip.ServiceId = ns(trip.ServiceId)
+		trip.TripID = ns(trip.TripID)
+		trip.BlockId = ns(trip.BlockId)
+		trip.ShapeId = ns(trip.ShapeId)
+	}
+}
@@ -0,0 +1,18 @@
+package gtfspec
+
+// network_id,network_name
+// MARTA_RAIL,MARTA Rail
+type Network struct {
+	NetworkId string `json:"network_id" gorm:"primaryKey"`
+	Name      string `json:"network_name"`
+}
+
+// Add populates the network from a CSV record of networks.txt.
+func (n *Network) Add(headers map[string]int, record []string) error {
+	row := newRow(headers, record)
+
+	n.NetworkId = row.requiredString("network_id")
+	n.Name = row.optionalString("network_name")
+
+	return row.err()
+}
@@ -0,0 +1,71 @@
+package gtfspec
+
+// Pathway modes
+const (
+	PathwayWalkway        = 1
+	PathwayStairs         = 2
+	PathwayMovingSidewalk = 3
+	PathwayEscalator      = 4
+	PathwayElevator       = 5
+	PathwayFareGate       = 6
+	PathwayExitGate       = 7
+)
+
+// pathway_id,from_stop_id,to_stop_id,pathway_mode,is_bidirectional,length,traversal_time,stair_count,max_slope,min_width,signposted_as,reversed_signposted_as
+// FP_E1_ESC,90001,90010,4,0,18.5,40,,,,To Trains,
+type Pathway struct {
+	PathwayId            string  `json:"pathway_id" gorm:"primaryKey"`
+	FromStopId           string  `json:"from_stop_id"`
+	ToStopId             string  `json:"to_stop_id"`
+	Mode                 int     `json:"pathway_mode"`
+	IsBidirectional      bool    `json:"is_bidirectional"`
+	Length               float64 `json:"length"`
+	TraversalTime        int     `json:"traversal_time"`
+	StairCount           int     `json:"stair_count"`
+	MaxSlope             float64 `json:"max_slope"`
+	MinWidth             float64 `json:"min_width"`
+	SignpostedAs         string  `json:"signposted_as"`
+	ReversedSignpostedAs string  `json:"reversed_signposted_as"`
+}
+
+// Add populates the pathway from a CSV record of pathways.txt.
+func (p *Pathway) Add(headers map[string]int, record []string) error {
+	row := newRow(headers, record)
+
+	p.PathwayId = row.requiredString("pathway_id")
+	p.FromStopId = row.requiredString("from_stop_id")
+	p.ToStopId = row.requiredString("to_stop_id")
+	p.Mode = row.requiredInt("pathway_mode")
+	p.IsBidirectional = row.requiredInt("is_bidirectional") == 1
+	p.Length = row.optionalFloat("length", 0)
+	p.TraversalTime = row.optionalInt("traversal_time", 0)
+	p.StairCount = row.optionalInt("stair_count", 0)
+	p.MaxSlope = row.optionalFloat("max_slope", 0)
+	p.MinWidth = row.optionalFloat("min_width", 0)
+	p.SignpostedAs = row.optionalString("signposted_as")
+	p.ReversedSignpostedAs = row.optionalString("reversed_signposted_as")
+
+	return row.err()
+}
+
+// ModeName returns a human readable name for the pathway mode.
+func (p *Pathway) ModeName() string {
+	switch p.Mode {
+	case PathwayWalkway:
+		return "walkway"
+	case PathwayStairs:
+		return "stairs"
+	case PathwayMovingSidewalk:
+		return "moving sidewalk"
+	case PathwayEscalator:
+		return "escalator"
+	case PathwayElevator:
+		return "elevator"
+	case PathwayFareGate:
+		return "fare gate"
+	case PathwayExitGate:
+		return "exit gate"
+	default:
+		return "unknown"
+	}
+}
@@ -0,0 +1,18 @@
+package gtfspec
+
+// network_id,route_id
+// MARTA_RAIL,17114
+type RouteNetwork struct {
+	NetworkId string `json:"network_id"`
+	RouteId   string `json:"route_id" gorm:"primaryKey"`
+}
+
+// Add populates the route network from a CSV record of route_networks.txt.
+func (r *RouteNetwork) Add(headers map[string]int, record []string) error {
+	row := newRow(headers, record)
+
+	r.NetworkId = row.requiredString("network_id")
+	r.RouteId = row.requiredString("route_id")
+
+	return row.err()
+}
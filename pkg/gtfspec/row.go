@@ -0,0 +1,219 @@
+package gtfspec
+
+import (
+	"encoding/hex"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// DateFormat is the layout of GTFS date fields (YYYYMMDD).
+const DateFormat = "20060102"
+
+// utf8BOM is the byte order mark some feeds prepend to the first header.
+const utf8BOM = "\ufeff"
+
+// ParseHeaders maps each column name in a GTFS header row to its index in the row.
+// A leading UTF-8 byte order mark and any surrounding whitespace are removed.
+func ParseHeaders(headerRow []string) map[string]int {
+	headers := make(map[string]int, len(headerRow))
+
+	for i, header := range headerRow {
+		if i == 0 {
+			header = strings.TrimPrefix(header, utf8BOM)
+		}
+		header = strings.TrimSpace(header)
+		if header == "" {
+			continue
+		}
+		if _, ok := headers[header]; !ok {
+			headers[header] = i
+		}
+	}
+
+	return headers
+}
+
+// row gives typed access to the columns of a single CSV record by header name.
+// Columns not referenced by a model are ignored. Parse failures are collected
+// rather than returned, so every bad column of a record is reported at once.
+type row struct {
+	headers map[string]int
+	record  []string
+	errs    []*ErrField
+}
+
+// newRow wraps a CSV record and the headers of the file it was read from.
+func newRow(headers map[string]int, record []string) *row {
+	return &row{headers: headers, record: record}
+}
+
+// value returns the trimmed value of a column and whether it is present and non-empty.
+func (r *row) value(column string) (string, bool) {
+	ndx, ok := r.headers[column]
+	if !ok || ndx >= len(r.record) {
+		return "", false
+	}
+	v := strings.TrimSpace(r.record[ndx])
+	return v, v != ""
+}
+
+// fail records a parse failure for a column.
+func (r *row) fail(column string, value string, required bool, err error) {
+	r.errs = append(r.errs, &ErrField{Err: err, Column: column, Value: value, Required: required})
+}
+
+// err returns the collected parse failures, or nil if there were none.
+func (r *row) err() error {
+	if len(r.errs) == 0 {
+		return nil
+	}
+	return &ErrRecord{Fields: r.errs}
+}
+
+// requiredString returns the value of a column that must be present.
+func (r *row) requiredString(column string) string {
+	v, ok := r.value(column)
+	if !ok {
+		r.fail(column, v, true, &ErrMissingValue{})
+	}
+	return v
+}
+
+// optionalString returns the value of a column, or "" if it is absent.
+func (r *row) optionalString(column string) string {
+	v, _ := r.value(column)
+	return v
+}
+
+// requiredInt returns the integer value of a column that must be present.
+func (r *row) requiredInt(column string) int {
+	v, ok := r.value(column)
+	if !ok {
+		r.fail(column, v, true, &ErrMissingValue{})
+		return 0
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		r.fail(column, v, true, err)
+	}
+	return i
+}
+
+// optionalInt returns the integer value of a column, or def if it is absent.
+func (r *row) optionalInt(column string, def int) int {
+	v, ok := r.value(column)
+	if !ok {
+		return def
+	}
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		r.fail(column, v, false, err)
+		return def
+	}
+	return i
+}
+
+// requiredFloat returns the float value of a column that must be present.
+func (r *row) requiredFloat(column string) float64 {
+	v, ok := r.value(column)
+	if !ok {
+		r.fail(column, v, true, &ErrMissingValue{})
+		return 0
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		r.fail(column, v, true, err)
+	}
+	return f
+}
+
+// optionalFloat returns the float value of a column, or def if it is absent.
+func (r *row) optionalFloat(column string, def float64) float64 {
+	v, ok := r.value(column)
+	if !ok {
+		return def
+	}
+	f, err := strconv.ParseFloat(v, 64)
+	if err != nil {
+		r.fail(column, v, false, err)
+		return def
+	}
+	return f
+}
+
+// requiredDate returns the YYYYMMDD date value of a column that must be present.
+func (r *row) requiredDate(column string) time.Time {
+	v, ok := r.value(column)
+	if !ok {
+		r.fail(column, v, true, &ErrMissingValue{})
+		return time.Time{}
+	}
+	d, err := time.Parse(DateFormat, v)
+	if err != nil {
+		r.fail(column, v, true, err)
+	}
+	return d
+}
+
+// optionalDate returns the YYYYMMDD date value of a column, or the zero time if it is absent.
+func (r *row) optionalDate(column string) time.Time {
+	v, ok := r.value(column)
+	if !ok {
+		return time.Time{}
+	}
+	d, err := time.Parse(DateFormat, v)
+	if err != nil {
+		r.fail(column, v, false, err)
+		return time.Time{}
+	}
+	return d
+}
+
+// optionalBool returns true if the column holds "1".
+func (r *row) optionalBool(column string) bool {
+	v, _ := r.value(column)
+	return v == "1"
+}
+
+// optionalHex returns the decoded value of a hex color column, or nil if it is absent.
+func (r *row) optionalHex(column string) []uint8 {
+	v, ok := r.value(column)
+	if !ok {
+		return nil
+	}
+	b, err := hex.DecodeString(v)
+	if err != nil {
+		r.fail(column, v, false, err)
+		return nil
+	}
+	return b
+}
+
+// requiredTime returns the HH:MM:SS time value of a column that must be present.
+func (r *row) requiredTime(column string) GTFSTime {
+	v, ok := r.value(column)
+	if !ok {
+		r.fail(column, v, true, &ErrMissingValue{})
+		return NoTime
+	}
+	t, err := ParseGTFSTime(v)
+	if err != nil {
+		r.fail(column, v, true, err)
+	}
+	return t
+}
+
+// optionalTime returns the HH:MM:SS time value of a column, or NoTime if it is absent.
+func (r *row) optionalTime(column string) GTFSTime {
+	v, ok := r.value(column)
+	if !ok {
+		return NoTime
+	}
+	t, err := ParseGTFSTime(v)
+	if err != nil {
+		r.fail(column, v, false, err)
+		return NoTime
+	}
+	return t
+}
@@ -0,0 +1,222 @@
+package gtfspec
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseHeaders(t *testing.T) {
+	tests := []struct {
+		name   string
+		header []string
+		want   map[string]int
+	}{
+		{
+			name:   "plain",
+			header: []string{"stop_id", "stop_name", "stop_lat"},
+			want:   map[string]int{"stop_id": 0, "stop_name": 1, "stop_lat": 2},
+		},
+		{
+			name:   "byte order mark",
+			header: []string{"\ufeffstop_id", "stop_name"},
+			want:   map[string]int{"stop_id": 0, "stop_name": 1},
+		},
+		{
+			name:   "whitespace",
+			header: []string{" stop_id", "stop_name ", "\tstop_lat\t"},
+			want:   map[string]int{"stop_id": 0, "stop_name": 1, "stop_lat": 2},
+		},
+		{
+			name:   "byte order mark and whitespace",
+			header: []string{"\ufeff stop_id ", "stop_name"},
+			want:   map[string]int{"stop_id": 0, "stop_name": 1},
+		},
+		{
+			name:   "reordered",
+			header: []string{"stop_lat", "stop_id", "stop_name"},
+			want:   map[string]int{"stop_lat": 0, "stop_id": 1, "stop_name": 2},
+		},
+		{
+			name:   "empty and duplicate columns",
+			header: []string{"stop_id", "", "stop_name", "stop_id"},
+			want:   map[string]int{"stop_id": 0, "stop_name": 2},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := ParseHeaders(tt.header); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ParseHeaders() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRowMissingColumns(t *testing.T) {
+	headers := ParseHeaders([]string{"trip_id", "stop_id"})
+	r := newRow(headers, []string{"T1", "S1"})
+
+	if got := r.optionalString("stop_headsign"); got != "" {
+		t.Errorf("optionalString() = %q, want empty", got)
+	}
+	if got := r.optionalInt("pickup_type", 3); got != 3 {
+		t.Errorf("optionalInt() = %d, want default 3", got)
+	}
+	if got := r.optionalFloat("shape_dist_traveled", -1); got != -1 {
+		t.Errorf("optionalFloat() = %v, want default -1", got)
+	}
+	if got := r.optionalTime("arrival_time"); got != NoTime {
+		t.Errorf("optionalTime() = %v, want NoTime", got)
+	}
+	if got := r.optionalDate("start_date"); !got.IsZero() {
+		t.Errorf("optionalDate() = %v, want zero time", got)
+	}
+	if err := r.err(); err != nil {
+		t.Errorf("err() = %v, want nil for absent optional columns", err)
+	}
+
+	// A record shorter than its header is treated as having empty trailing columns.
+	short := newRow(ParseHeaders([]string{"trip_id", "stop_id", "stop_sequence"}), []string{"T1"})
+	if got := short.optionalInt("stop_sequence", 7); got != 7 {
+		t.Errorf("optionalInt() on a short record = %d, want default 7", got)
+	}
+}
+
+func TestRowErrRecord(t *testing.T) {
+	headers := ParseHeaders([]string{"trip_id", "stop_sequence", "arrival_time", "pickup_type", "shape_dist_traveled", "start_date"})
+
+	tests := []struct {
+		name   string
+		record []string
+		read   func(r *row)
+		want   []ErrField
+	}{
+		{
+			name:   "valid",
+			record: []string{"T1", "4", "25:10:00", "1", "1.5", "20240101"},
+			read: func(r *row) {
+				r.requiredString("trip_id")
+				r.requiredInt("stop_sequence")
+				r.requiredTime("arrival_time")
+				r.optionalInt("pickup_type", 0)
+				r.optionalFloat("shape_dist_traveled", 0)
+				r.requiredDate("start_date")
+			},
+		},
+		{
+			name:   "missing required values",
+			record: []string{"", "", "", "", "", ""},
+			read: func(r *row) {
+				r.requiredString("trip_id")
+				r.requiredInt("stop_sequence")
+				r.requiredTime("arrival_time")
+				r.optionalInt("pickup_type", 0)
+			},
+			want: []ErrField{
+				{Column: "trip_id", Required: true},
+				{Column: "stop_sequence", Required: true},
+				{Column: "arrival_time", Required: true},
+			},
+		},
+		{
+			name:   "invalid required and optional values",
+			record: []string{"T1", "four", "8:5:00", "x", "far", "2024-01-01"},
+			read: func(r *row) {
+				r.requiredString("trip_id")
+				r.requiredInt("stop_sequence")
+				r.requiredTime("arrival_time")
+				r.optionalInt("pickup_type", 0)
+				r.optionalFloat("shape_dist_traveled", 0)
+				r.requiredDate("start_date")
+			},
+			want: []ErrField{
+				{Column: "stop_sequence", Value: "four", Required: true},
+				{Column: "arrival_time", Value: "8:5:00", Required: true},
+				{Column: "pickup_type", Value: "x"},
+				{Column: "shape_dist_traveled", Value: "far"},
+				{Column: "start_date", Value: "2024-01-01", Required: true},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := newRow(headers, tt.record)
+			tt.read(r)
+			err := r.err()
+
+			if len(tt.want) == 0 {
+				if err != nil {
+					t.Fatalf("err() = %v, want nil", err)
+				}
+				return
+			}
+
+			var record *ErrRecord
+			if !errors.As(err, &record) {
+				t.Fatalf("err() = %v, want *ErrRecord", err)
+			}
+			if len(record.Fields) != len(tt.want) {
+				t.Fatalf("err() has %d fields, want %d: %v", len(record.Fields), len(tt.want), err)
+			}
+			for i, want := range tt.want {
+				got := record.Fields[i]
+				if got.Column != want.Column || got.Value != want.Value || got.Required != want.Required {
+					t.Errorf("field %d = {%s %q required=%v}, want {%s %q required=%v}",
+						i, got.Column, got.Value, got.Required, want.Column, want.Value, want.Required)
+				}
+				var missing *ErrMissingValue
+				if want.Value == "" && want.Required && !errors.As(got.Err, &missing) {
+					t.Errorf("field %d error = %v, want *ErrMissingValue", i, got.Err)
+				}
+			}
+		})
+	}
+}
+
+func TestRowDefaultsOnError(t *testing.T) {
+	r := newRow(ParseHeaders([]string{"pickup_type", "arrival_time", "start_date"}), []string{"x", "later", "tomorrow"})
+
+	if got := r.optionalInt("pickup_type", 2); got != 2 {
+		t.Errorf("optionalInt() = %d, want default 2 on a bad value", got)
+	}
+	if got := r.optionalTime("arrival_time"); got != NoTime {
+		t.Errorf("optionalTime() = %v, want NoTime on a bad value", got)
+	}
+	if got := r.optionalDate("start_date"); got != (time.Time{}) {
+		t.Errorf("optionalDate() = %v, want zero time on a bad value", got)
+	}
+}
+
+func TestStopTimeAddHeaderDriven(t *testing.T) {
+	// Columns are found by name, so a reordered header with a byte order mark
+	// and no optional columns parses the same as the spec's order.
+	headers := ParseHeaders([]string{"\ufeffstop_sequence", " stop_id", "departure_time", "trip_id"})
+
+	s := &StopTime{}
+	if err := s.Add(headers, []string{"3", "S1", "25:10:00", "T1"}); err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if s.TripId != "T1" || s.StopId != "S1" || s.StopSequence != 3 {
+		t.Errorf("Add() = trip %q stop %q sequence %d, want T1 S1 3", s.TripId, s.StopId, s.StopSequence)
+	}
+	if s.DepartureTime.String() != "25:10:00" || s.ArrivalTime != s.DepartureTime {
+		t.Errorf("Add() times = %v/%v, want 25:10:00 for both", s.ArrivalTime, s.DepartureTime)
+	}
+
+	// Every bad column of the record is reported in one error.
+	err := (&StopTime{}).Add(headers, []string{"x", "", "", ""})
+	var record *ErrRecord
+	if !errors.As(err, &record) {
+		t.Fatalf("Add() error = %v, want *ErrRecord", err)
+	}
+	columns := make([]string, 0, len(record.Fields))
+	for _, field := range record.Fields {
+		columns = append(columns, field.Column)
+	}
+	if want := []string{"trip_id", "stop_id", "stop_sequence"}; !reflect.DeepEqual(columns, want) {
+		t.Errorf("Add() failed columns = %v, want %v", columns, want)
+	}
+}
@@ -1,50 +1,24 @@
 package gtfspec
 
-import (
-	"fmt"
-	"strconv"
-
-	"gorm.io/gorm"
-)
-
 // shape_id,shape_pt_lat,shape_pt_lon,shape_pt_sequence,shape_dist_traveled
 // 100095,33.818860,-84.450519,1,0.0000
 type Shape struct {
-	gorm.Model
-	ShapeId  int     `json:"shape_id" gorm:"primaryKey"`
+	ShapeId  string  `json:"shape_id" gorm:"primaryKey"`
 	Lat      float64 `json:"shape_pt_lat"`
 	Lon      float64 `json:"shape_pt_lon"`
-	Sequence int     `json:"shape_pt_sequence" gorm:"primaryKey"`
+	Sequence int     `json:"shape_pt_sequence" gorm:"primaryKey;autoIncrement:false"`
 	Distance float64 `json:"shape_dist_traveled"`
 }
 
+// Add populates the shape point from a CSV record of shapes.txt.
 func (s *Shape) Add(headers map[string]int, record []string) error {
-	if len(record) != 5 {
-		return fmt.Errorf("invalid shape record length: %d", len(record))
-	}
-
-	var err error
-
-	s.ShapeId, err = strconv.Atoi(record[headers["shape_id"]])
-	if err != nil {
-		return fmt.Errorf("shape id: %v", err)
-	}
-
-	s.Sequence, err = strconv.Atoi(record[headers["shape_pt_sequence"]])
-	if err != nil {
-		return fmt.Errorf("shape sequence: %v", err)
-	}
-
-	if s.Lat, err = strconv.ParseFloat(record[headers["shape_pt_lat"]], 64); err != nil {
-		return fmt.Errorf("shape lat: %v", err)
-	}
-	if s.Lon, err = strconv.ParseFloat(record[headers["shape_pt_lon"]], 64); err != nil {
-		return fmt.Errorf("shape lon: %v", err)
-	}
+	row := newRow(headers, record)
 
-	if s.Distance, err = strconv.ParseFloat(record[headers["shape_dist_traveled"]], 64); err != nil {
-		return fmt.Errorf("shape distance: %v", err)
-	}
+	s.ShapeId = row.requiredString("shape_id")
+	s.Lat = row.requiredFloat("shape_pt_lat")
+	s.Lon = row.requiredFloat("shape_pt_lon")
+	s.Sequence = row.requiredInt("shape_pt_sequence")
+	s.Distance = row.optionalFloat("shape_dist_traveled", 0)
 
-	return nil
+	return row.err()
 }
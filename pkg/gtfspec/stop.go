@@ -1,73 +1,91 @@
 package gtfspec
 
-import (
-	"fmt"
-	"strconv"
-	"strings"
+import "math"
 
-	"gorm.io/gorm"
+// earthRadius is the mean radius of the earth in meters
+const earthRadius = 6371000
+
+// Stop location types
+const (
+	LocationStop         = 0
+	LocationStation      = 1
+	LocationEntrance     = 2
+	LocationGenericNode  = 3
+	LocationBoardingArea = 4
 )
 
 // stop_id,stop_code,stop_name,stop_desc,stop_lat,stop_lon,zone_id,stop_url,location_type,parent_station,stop_timezone,wheelchair_boarding
 // 27,907933,HAMILTON E HOLMES STATION,70 HAMILTON E HOLMES DR NW & CSX TRANSPORTATION,33.754553,-84.469302,,,,,,1
 type Stop struct {
-	gorm.Model
-	StopId             int     `json:"stop_id" gorm:"primaryKey"`
-	Code               int     `json:"stop_code"`
+	StopId             string  `json:"stop_id" gorm:"primaryKey"`
+	Code               string  `json:"stop_code"`
 	Name               string  `json:"stop_name"`
 	Desc               string  `json:"stop_desc"`
 	Lat                float64 `json:"stop_lat"`
 	Lon                float64 `json:"stop_lon"`
-	ZoneId             int     `json:"zone_id"`
+	ZoneId             string  `json:"zone_id"`
 	Url                string  `json:"stop_url"`
 	LocationType       int     `json:"location_type"`
-	ParentStation      string  `json:"parent_station"`
+	ParentStation      string  `json:"parent_station" gorm:"index"`
 	Timezone           string  `json:"stop_timezone"`
 	WheelchairBoarding bool    `json:"wheelchair_boarding"`
+	LevelId            string  `json:"level_id"`
+	PlatformCode       string  `json:"platform_code"`
 }
 
+// Add populates the stop from a CSV record of stops.txt.
 func (s *Stop) Add(headers map[string]int, record []string) error {
-	if len(record) != 12 {
-		return fmt.Errorf("invalid stop record length: %d", len(record))
-	}
+	row := newRow(headers, record)
 
-	var err error
-	s.Name = record[headers["stop_name"]]
-	s.Desc = record[headers["stop_desc"]]
-	s.Url = record[headers["stop_url"]]
-	s.ParentStation = record[headers["parent_station"]]
+	s.StopId = row.requiredString("stop_id")
+	s.Code = row.optionalString("stop_code")
+	s.Desc = row.optionalString("stop_desc")
+	s.ZoneId = row.optionalString("zone_id")
+	s.Url = row.optionalString("stop_url")
+	s.LocationType = row.optionalInt("location_type", 0)
+	s.ParentStation = row.optionalString("parent_station")
+	s.Timezone = row.optionalString("stop_timezone")
+	s.WheelchairBoarding = row.optionalBool("wheelchair_boarding")
+	s.LevelId = row.optionalString("level_id")
+	s.PlatformCode = row.optionalString("platform_code")
 
-	s.StopId, err = strconv.Atoi(record[headers["stop_id"]])
-	if err != nil {
-		return fmt.Errorf("stop id: %v", err)
-	}
-	if s.Code, err = strconv.Atoi(record[headers["stop_code"]]); err != nil {
-		return fmt.Errorf("stop code: %v", err)
-	}
-	if s.Lat, err = strconv.ParseFloat(record[headers["stop_lat"]], 64); err != nil {
-		return fmt.Errorf("stop lat: %v", err)
-	}
-	if s.Lon, err = strconv.ParseFloat(record[headers["stop_lon"]], 64); err != nil {
-		return fmt.Errorf("stop lon: %v", err)
-	}
-	if s.ZoneId, err = strconv.Atoi(record[headers["zone_id"]]); err != nil {
-		if strings.TrimSpace(record[headers["zone_id"]]) == "" {
-			s.ZoneId = 0
-		} else {
-			return fmt.Errorf("stop zone id: %v", err)
-		}
-	}
-	if s.LocationType, err = strconv.Atoi(record[headers["location_type"]]); err != nil {
-		if strings.TrimSpace(record[headers["location_type"]]) == "" {
-			s.LocationType = 0
-		} else {
-			return fmt.Errorf("stop location type: %v", err)
-		}
-	}
-	if strings.TrimSpace(record[headers["wheelchair_boarding"]]) == "1" {
-		s.WheelchairBoarding = true
+	// stop_name, stop_lat and stop_lon are only required for stops, stations and entrances;
+	// generic nodes and boarding areas may omit them.
+	if s.LocationType <= LocationEntrance {
+		s.Name = row.requiredString("stop_name")
+		s.Lat = row.requiredFloat("stop_lat")
+		s.Lon = row.requiredFloat("stop_lon")
 	} else {
-		s.WheelchairBoarding = false
+		s.Name = row.optionalString("stop_name")
+		s.Lat = row.optionalFloat("stop_lat", 0)
+		s.Lon = row.optionalFloat("stop_lon", 0)
 	}
-	return nil
+
+	return row.err()
+}
+
+// IsStation reports whether the stop is a station that groups other stops.
+func (s *Stop) IsStation() bool {
+	return s.LocationType == LocationStation
+}
+
+// IsPlatform reports whether the stop is a platform (or plain stop) that vehicles serve.
+func (s *Stop) IsPlatform() bool {
+	return s.LocationType == LocationStop
+}
+
+// IsEntrance reports whether the stop is a station entrance or exit.
+func (s *Stop) IsEntrance() bool {
+	return s.LocationType == LocationEntrance
+}
+
+// Distance returns the great circle distance to another stop in meters
+func (s *Stop) Distance(to *Stop) float64 {
+	lat1 := s.Lat * math.Pi / 180
+	lat2 := to.Lat * math.Pi / 180
+	dLat := lat2 - lat1
+	dLon := (to.Lon - s.Lon) * math.Pi / 180
+
+	a := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadius * math.Asin(math.Sqrt(a))
 }
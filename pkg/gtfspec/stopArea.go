@@ -0,0 +1,18 @@
+package gtfspec
+
+// area_id,stop_id
+// AIRPORT,99
+type StopArea struct {
+	AreaId string `json:"area_id" gorm:"primaryKey"`
+	StopId string `json:"stop_id" gorm:"primaryKey"`
+}
+
+// Add populates the stop area from a CSV record of stop_areas.txt.
+func (s *StopArea) Add(headers map[string]int, record []string) error {
+	row := newRow(headers, record)
+
+	s.AreaId = row.requiredString("area_id")
+	s.StopId = row.requiredString("stop_id")
+
+	return row.err()
+}
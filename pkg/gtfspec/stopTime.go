@@ -1,66 +1,60 @@
 package gtfspec
 
-import (
-	"fmt"
-	"strconv"
-	"strings"
+// Pickup types
+const (
+	PickupRegular    = 0
+	PickupNone       = 1
+	PickupPhone      = 2
+	PickupCoordinate = 3
+)
 
-	"gorm.io/gorm"
+// Drop off types
+const (
+	DropOffRegular    = 0
+	DropOffNone       = 1
+	DropOffPhone      = 2
+	DropOffCoordinate = 3
 )
 
 // trip_id,arrival_time,departure_time,stop_id,stop_sequence,stop_headsign,pickup_type,drop_off_type,shape_dist_traveled,timepoint
 // 7142673, 6:43:00, 6:43:00,27,1,,0,0,,1
 type StopTime struct {
-	gorm.Model
-	TripId            int     `json:"trip_id" gorm:"primaryKey"`
-	ArrivalTime       string  `json:"arrival_time"`
-	DepartureTime     string  `json:"departure_time"`
-	StopId            int     `json:"stop_id" gorm:"primaryKey"`
-	StopSequence      int     `json:"stop_sequence" `
-	StopHeadsign      string  `json:"stop_headsign"`
-	PickupType        int     `json:"pickup_type"`
-	DropOffType       int     `json:"drop_off_type"`
-	ShapeDistTraveled float64 `json:"shape_dist_traveled"`
-	Timepoint         int     `json:"timepoint"`
+	TripId            string   `json:"trip_id" gorm:"primaryKey"`
+	ArrivalTime       GTFSTime `json:"arrival_time"`
+	DepartureTime     GTFSTime `json:"departure_time" gorm:"index:idx_stop_times_stop_id,priority:2"`
+	StopId            string   `json:"stop_id" gorm:"index:idx_stop_times_stop_id,priority:1"`
+	StopSequence      int      `json:"stop_sequence" gorm:"primaryKey;autoIncrement:false"`
+	StopHeadsign      string   `json:"stop_headsign"`
+	PickupType        int      `json:"pickup_type"`
+	DropOffType       int      `json:"drop_off_type"`
+	ShapeDistTraveled float64  `json:"shape_dist_traveled"`
+	Timepoint         int      `json:"timepoint"`
 }
 
+// Add populates the stop time from a CSV record of stop_times.txt.
 func (s *StopTime) Add(headers map[string]int, record []string) error {
-	if len(record) != 10 {
-		return fmt.Errorf("invalid stop time record length: %d", len(record))
-	}
+	row := newRow(headers, record)
 
-	var err error
-	s.ArrivalTime = record[headers["arrival_time"]]
-	s.DepartureTime = record[headers["departure_time"]]
-	s.StopHeadsign = record[headers["stop_headsign"]]
+	s.TripId = row.requiredString("trip_id")
+	s.ArrivalTime = row.optionalTime("arrival_time")
+	s.DepartureTime = row.optionalTime("departure_time")
+	s.StopId = row.requiredString("stop_id")
+	s.StopSequence = row.requiredInt("stop_sequence")
+	s.StopHeadsign = row.optionalString("stop_headsign")
+	s.PickupType = row.optionalInt("pickup_type", 0)
+	s.DropOffType = row.optionalInt("drop_off_type", 0)
+	s.ShapeDistTraveled = row.optionalFloat("shape_dist_traveled", 0)
 
-	s.TripId, err = strconv.Atoi(record[headers["trip_id"]])
-	if err != nil {
-		return fmt.Errorf("trip id: %v", err)
-	}
-	s.StopId, err = strconv.Atoi(record[headers["stop_id"]])
-	if err != nil {
-		return fmt.Errorf("stop id: %v", err)
-	}
-	if s.StopSequence, err = strconv.Atoi(record[headers["stop_sequence"]]); err != nil {
-		return fmt.Errorf("stop sequence: %v", err)
+	// A stop with only one of the two times arrives and departs at the same time.
+	if !s.ArrivalTime.IsSet() {
+		s.ArrivalTime = s.DepartureTime
 	}
-	if s.PickupType, err = strconv.Atoi(record[headers["pickup_type"]]); err != nil {
-		return fmt.Errorf("pickup type: %v", err)
-	}
-	if s.DropOffType, err = strconv.Atoi(record[headers["drop_off_type"]]); err != nil {
-		return fmt.Errorf("drop off type: %v", err)
-	}
-	if s.ShapeDistTraveled, err = strconv.ParseFloat(strings.TrimSpace(record[headers["shape_dist_traveled"]]), 64); err != nil {
-		if strings.TrimSpace(record[headers["shape_dist_traveled"]]) == "" {
-			s.ShapeDistTraveled = 0
-		} else {
-			return fmt.Errorf("shape dist traveled: %v", err)
-		}
-	}
-	if s.Timepoint, err = strconv.Atoi(record[headers["timepoint"]]); err != nil {
-		return fmt.Errorf("timepoint: %v", err)
+	if !s.DepartureTime.IsSet() {
+		s.DepartureTime = s.ArrivalTime
 	}
 
-	return nil
+	// An empty timepoint means the times are exact.
+	s.Timepoint = row.optionalInt("timepoint", 1)
+
+	return row.err()
 }
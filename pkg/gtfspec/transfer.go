@@ -0,0 +1,45 @@
+package gtfspec
+
+// Transfer types
+const (
+	TransferRecommended = 0
+	TransferTimed       = 1
+	TransferMinTime     = 2
+	TransferNotPossible = 3
+)
+
+// from_stop_id,to_stop_id,from_route_id,to_route_id,from_trip_id,to_trip_id,transfer_type,min_transfer_time
+// 27,28,,,,,2,180
+type Transfer struct {
+	FromStopId      string `json:"from_stop_id" gorm:"primaryKey"`
+	ToStopId        string `json:"to_stop_id" gorm:"primaryKey"`
+	FromRouteId     string `json:"from_route_id" gorm:"primaryKey"`
+	ToRouteId       string `json:"to_route_id" gorm:"primaryKey"`
+	FromTripId      string `json:"from_trip_id" gorm:"primaryKey"`
+	ToTripId        string `json:"to_trip_id" gorm:"primaryKey"`
+	TransferType    int    `json:"transfer_type"`
+	MinTransferTime int    `json:"min_transfer_time"`
+}
+
+// Add populates the transfer from a CSV record of transfers.txt.
+func (t *Transfer) Add(headers map[string]int, record []string) error {
+	row := newRow(headers, record)
+
+	t.TransferType = row.optionalInt("transfer_type", TransferRecommended)
+
+	// Stops are only optional for in-seat transfers (types 4 and 5), which are keyed by trip.
+	if t.TransferType <= TransferNotPossible {
+		t.FromStopId = row.requiredString("from_stop_id")
+		t.ToStopId = row.requiredString("to_stop_id")
+	} else {
+		t.FromStopId = row.optionalString("from_stop_id")
+		t.ToStopId = row.optionalString("to_stop_id")
+	}
+	t.FromRouteId = row.optionalString("from_route_id")
+	t.ToRouteId = row.optionalString("to_route_id")
+	t.FromTripId = row.optionalString("from_trip_id")
+	t.ToTripId = row.optionalString("to_trip_id")
+	t.MinTransferTime = row.optionalInt("min_transfer_time", 0)
+
+	return row.err()
+}
@@ -0,0 +1,13 @@
+package gtfspec
+
+// WalkingTransfer is a generated walk between two nearby stops. It is not
+// part of GTFS; it fills in for transfers.txt when a feed doesn't ship one.
+type WalkingTransfer struct {
+	FromStopId string  `json:"from_stop_id" gorm:"primaryKey"`
+	ToStopId   string  `json:"to_stop_id" gorm:"primaryKey"`
+	Distance   float64 `json:"distance"`
+	WalkTime   int     `json:"walk_time"`
+
+	// SameStation is set when both stops belong to the same parent station
+	SameStation bool `json:"same_station"`
+}
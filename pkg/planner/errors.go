@@ -0,0 +1,105 @@
+package planner
+
+// ErrLoadingData is returned when planner tables cannot be read from the database.
+type ErrLoadingData struct {
+	Err       error
+	Structure string
+	Msg       string
+}
+
+// Error returns the error message.
+func (e *ErrLoadingData) Error() string {
+	if e.Msg == "" {
+		e.Msg = "error loading planner data"
+	}
+	if e.Structure != "" {
+		e.Msg += ": " + e.Structure
+	}
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
+
+// ErrNoData is returned when neither a database nor a feed is provided.
+type ErrNoData struct {
+	Err error
+	Msg string
+}
+
+// Error returns the error message.
+func (e *ErrNoData) Error() string {
+	if e.Msg == "" {
+		e.Msg = "no planner data provided- use WithDatabase() or WithFeed()"
+	}
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
+
+// ErrUnknownStop is returned when a stop_id is not in the feed.
+type ErrUnknownStop struct {
+	Err    error
+	StopId string
+	Msg    string
+}
+
+// Error returns the error message.
+func (e *ErrUnknownStop) Error() string {
+	if e.Msg == "" {
+		e.Msg = "unknown stop"
+	}
+	if e.StopId != "" {
+		e.Msg += ": " + e.StopId
+	}
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
+
+// ErrNoItinerary is returned when no journey reaches the destination.
+type ErrNoItinerary struct {
+	Err  error
+	From string
+	To   string
+	Msg  string
+}
+
+// Error returns the error message.
+func (e *ErrNoItinerary) Error() string {
+	if e.Msg == "" {
+		e.Msg = "no itinerary found"
+	}
+	if e.From != "" && e.To != "" {
+		e.Msg += ": " + e.From + " to " + e.To
+	}
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
+
+// ErrSameStop is returned when the origin and destination are the same stop,
+// or a station and one of its platforms.
+type ErrSameStop struct {
+	Err  error
+	From string
+	To   string
+	Msg  string
+}
+
+// Error returns the error message.
+func (e *ErrSameStop) Error() string {
+	if e.Msg == "" {
+		e.Msg = "origin and destination are the same stop"
+	}
+	if e.From != "" && e.To != "" {
+		e.Msg += ": " + e.From + " to " + e.To
+	}
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
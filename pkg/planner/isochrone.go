@@ -0,0 +1,222 @@
+package planner
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/rmrfslashbin/gomarta/pkg/gtfspec"
+)
+
+const (
+	// defaultMaxWalk is the farthest walk in meters from a coordinate origin
+	// and the largest buffer drawn around a reached stop
+	defaultMaxWalk = 800
+
+	// bufferSegments is the number of sides of the polygon approximating a buffer
+	bufferSegments = 32
+)
+
+// ReachInput is the input for the Reach method
+type ReachInput struct {
+	// From is the origin stop or station id. If empty, the origin is the
+	// coordinate Lat, Lon and the rider walks to the stops near it.
+	From string
+	Lat  float64
+	Lon  float64
+
+	Depart time.Time
+	Budget time.Duration
+
+	// MaxTransfers limits the number of vehicle changes. 0 allows direct
+	// trips only; a negative value uses the default of 3.
+	MaxTransfers int
+
+	// MaxWalk is the farthest walk in meters from a coordinate origin and
+	// the radius limit of buffers; defaults to 800
+	MaxWalk float64
+}
+
+// Reachability is every stop reachable from an origin within a time budget
+type Reachability struct {
+	Depart  time.Time
+	Budget  time.Duration
+	MaxWalk float64
+
+	// Stops are ordered by travel time
+	Stops []*Reached
+}
+
+// Reached is a stop reachable within the budget
+type Reached struct {
+	Stop      *gtfspec.Stop
+	Arrival   time.Time
+	Seconds   int
+	Transfers int
+}
+
+// Reach returns the stops reachable by transit and walking from an origin
+// within a time budget, with the earliest arrival at each
+func (c *Planner) Reach(input *ReachInput) (*Reachability, error) {
+	depart := input.Depart
+	if depart.IsZero() {
+		depart = time.Now()
+	}
+	local := depart.In(c.schedule.Location())
+	date := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+
+	maxTransfers := input.MaxTransfers
+	if maxTransfers < 0 {
+		maxTransfers = defaultMaxTransfers
+	}
+	maxWalk := input.MaxWalk
+	if maxWalk <= 0 {
+		maxWalk = defaultMaxWalk
+	}
+
+	n, err := c.network(date)
+	if err != nil {
+		return nil, err
+	}
+
+	start := gtfspec.GTFSTimeOf(depart, date, c.schedule.Location()).Seconds()
+	limit := start + int(input.Budget/time.Second) + 1
+
+	origins := make([]origin, 0)
+	if input.From != "" {
+		from, ok := c.stops[input.From]
+		if !ok {
+			return nil, &ErrUnknownStop{StopId: input.From}
+		}
+		for _, stop := range n.expand(c.expand(from)) {
+			origins = append(origins, origin{stop: stop, arrival: start})
+		}
+	} else {
+		here := &gtfspec.Stop{Lat: input.Lat, Lon: input.Lon}
+		for ndx, stop := range n.stops {
+			if stop.LocationType != gtfspec.LocationStop || (stop.Lat == 0 && stop.Lon == 0) {
+				continue
+			}
+			if here.Distance(stop) <= maxWalk {
+				origins = append(origins, origin{stop: ndx, arrival: start + walkSeconds(here, stop)})
+			}
+		}
+	}
+
+	rounds := n.raptor(origins, nil, limit, maxTransfers+1)
+
+	reach := &Reachability{
+		Depart:  depart,
+		Budget:  input.Budget,
+		MaxWalk: maxWalk,
+		Stops:   make([]*Reached, 0),
+	}
+
+	for stop := range n.stops {
+		arrival, trips := infinity, 0
+		for k, round := range rounds {
+			if round[stop].arrival < arrival {
+				arrival, trips = round[stop].arrival, k
+			}
+		}
+		if arrival == infinity {
+			continue
+		}
+		reached := &Reached{
+			Stop:    n.stops[stop],
+			Arrival: n.time(arrival),
+			Seconds: arrival - start,
+		}
+		if trips > 0 {
+			reached.Transfers = trips - 1
+		}
+		reach.Stops = append(reach.Stops, reached)
+	}
+
+	sort.SliceStable(reach.Stops, func(i, j int) bool {
+		return reach.Stops[i].Seconds < reach.Stops[j].Seconds
+	})
+
+	return reach, nil
+}
+
+// FeatureCollection is a GeoJSON feature collection
+type FeatureCollection struct {
+	Type     string     `json:"type"`
+	Features []*Feature `json:"features"`
+}
+
+// Feature is a GeoJSON feature
+type Feature struct {
+	Type       string                 `json:"type"`
+	Geometry   *Geometry              `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+// Geometry is a GeoJSON Point or Polygon
+type Geometry struct {
+	Type        string      `json:"type"`
+	Coordinates interface{} `json:"coordinates"`
+}
+
+// GeoJSON returns the reached stops as points, or as buffers covering the
+// area a rider can still walk to with the rest of the budget
+func (r *Reachability) GeoJSON(buffers bool) *FeatureCollection {
+	collection := &FeatureCollection{
+		Type:     "FeatureCollection",
+		Features: make([]*Feature, 0, len(r.Stops)),
+	}
+
+	for _, reached := range r.Stops {
+		stop := reached.Stop
+		if stop.Lat == 0 && stop.Lon == 0 {
+			continue
+		}
+
+		feature := &Feature{
+			Type: "Feature",
+			Geometry: &Geometry{
+				Type:        "Point",
+				Coordinates: []float64{stop.Lon, stop.Lat},
+			},
+			Properties: map[string]interface{}{
+				"stop_id":   stop.StopId,
+				"stop_name": stop.Name,
+				"seconds":   reached.Seconds,
+				"transfers": reached.Transfers,
+				"arrival":   reached.Arrival,
+			},
+		}
+
+		if buffers {
+			remaining := r.Budget.Seconds() - float64(reached.Seconds)
+			radius := math.Min(remaining*walkingSpeed, r.MaxWalk)
+			if radius <= 0 {
+				continue
+			}
+			feature.Geometry = &Geometry{
+				Type:        "Polygon",
+				Coordinates: [][][]float64{circle(stop.Lat, stop.Lon, radius)},
+			}
+			feature.Properties["radius"] = radius
+		}
+
+		collection.Features = append(collection.Features, feature)
+	}
+
+	return collection
+}
+
+// circle returns a closed ring of [lon, lat] points approximating a circle
+// of radius meters around a coordinate
+func circle(lat float64, lon float64, radius float64) [][]float64 {
+	ring := make([][]float64, 0, bufferSegments+1)
+	angular := radius / earthRadius
+	for i := 0; i <= bufferSegments; i++ {
+		bearing := 2 * math.Pi * float64(i%bufferSegments) / bufferSegments
+		dLat := angular * math.Cos(bearing)
+		dLon := angular * math.Sin(bearing) / math.Cos(lat*math.Pi/180)
+		ring = append(ring, []float64{lon + dLon*180/math.Pi, lat + dLat*180/math.Pi})
+	}
+	return ring
+}
@@ -0,0 +1,343 @@
+package planner
+
+import (
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/rmrfslashbin/gomarta/pkg/gtfspec"
+)
+
+const (
+	// walkingSpeed is the assumed walking speed in meters per second
+	walkingSpeed = 1.3
+
+	// stationTransferSeconds is the assumed time to change platforms inside a
+	// station when transfers.txt doesn't say otherwise
+	stationTransferSeconds = 120
+
+	// earthRadius is the mean radius of the earth in meters
+	earthRadius = 6371000
+
+	// infinity is an unreached arrival time
+	infinity = math.MaxInt32
+)
+
+// network is the timetable index RAPTOR runs over for one service date.
+// Times are seconds since the start of that service day; trips of the
+// previous service date that run past midnight are included, shifted onto
+// the same clock.
+type network struct {
+	date time.Time
+	loc  *time.Location
+
+	stops     []*gtfspec.Stop
+	stopIdx   map[string]int
+	transfers []*gtfspec.Transfer
+	walking   []*gtfspec.WalkingTransfer
+	routes    map[string]*gtfspec.Route
+	runs      []*tripRun
+
+	patterns   []*pattern
+	patternsAt [][]patternStop
+	footpaths  [][]footpath
+
+	// minChange is the minimum time in seconds to change vehicles at each
+	// stop, from transfers.txt rows whose from and to stop are the same
+	minChange []int
+}
+
+// pattern is a set of trips that visit the same stops in the same order
+// without overtaking each other, the "route" of RAPTOR
+type pattern struct {
+	route *gtfspec.Route
+	stops []int
+	trips []*patternTrip
+}
+
+// patternTrip is one trip of a pattern. arrivals and departures are the
+// expected times, which differ from the scheduled ones when realtime data
+// is applied.
+type patternTrip struct {
+	trip                *gtfspec.Trip
+	serviceDate         time.Time
+	stopTimes           []*gtfspec.StopTime
+	arrivals            []int
+	departures          []int
+	scheduledArrivals   []int
+	scheduledDepartures []int
+	realtime            bool
+}
+
+// patternStop is a position of a stop in a pattern
+type patternStop struct {
+	pattern  int
+	position int
+}
+
+// footpath is a walking transfer to another stop
+type footpath struct {
+	to      int
+	seconds int
+}
+
+// tripRun is a trip with its stop times on a service date, shifted by offset
+// seconds onto the network's clock. arrivals and departures override the
+// scheduled times when realtime predictions are known.
+type tripRun struct {
+	trip        *gtfspec.Trip
+	serviceDate time.Time
+	offset      int
+	stopTimes   []*gtfspec.StopTime
+	arrivals    []int
+	departures  []int
+}
+
+// newNetwork indexes the stops, transfers and trip runs of a service date
+func newNetwork(date time.Time, loc *time.Location, stops []*gtfspec.Stop, transfers []*gtfspec.Transfer, walking []*gtfspec.WalkingTransfer, routes map[string]*gtfspec.Route, runs []*tripRun) *network {
+	n := &network{
+		date:      date,
+		loc:       loc,
+		stops:     stops,
+		stopIdx:   make(map[string]int, len(stops)),
+		transfers: transfers,
+		walking:   walking,
+		routes:    routes,
+		runs:      runs,
+	}
+	for ndx, stop := range stops {
+		n.stopIdx[stop.StopId] = ndx
+	}
+	n.patternsAt = make([][]patternStop, len(stops))
+	n.footpaths = make([][]footpath, len(stops))
+	n.minChange = make([]int, len(stops))
+
+	n.addPatterns()
+	n.addFootpaths()
+
+	return n
+}
+
+// addPatterns groups trip runs into patterns
+func (n *network) addPatterns() {
+	byKey := make(map[string][]*patternTrip)
+	keys := make([]string, 0)
+	stopsOf := make(map[string][]int)
+
+	for _, run := range n.runs {
+		stops := make([]int, 0, len(run.stopTimes))
+		ids := make([]string, 0, len(run.stopTimes))
+		for _, stopTime := range run.stopTimes {
+			ndx, ok := n.stopIdx[stopTime.StopId]
+			if !ok {
+				break
+			}
+			stops = append(stops, ndx)
+			ids = append(ids, stopTime.StopId)
+		}
+		if len(stops) < 2 || len(stops) != len(run.stopTimes) {
+			continue
+		}
+
+		trip := &patternTrip{
+			trip:        run.trip,
+			serviceDate: run.serviceDate,
+			stopTimes:   run.stopTimes,
+		}
+		trip.scheduledArrivals, trip.scheduledDepartures = interpolate(run.stopTimes, run.offset)
+		trip.arrivals, trip.departures = trip.scheduledArrivals, trip.scheduledDepartures
+		if run.arrivals != nil && run.departures != nil {
+			trip.arrivals, trip.departures = run.arrivals, run.departures
+			trip.realtime = true
+		}
+
+		key := run.trip.RouteId + "|" + strings.Join(ids, ",")
+		if _, ok := byKey[key]; !ok {
+			keys = append(keys, key)
+			stopsOf[key] = stops
+		}
+		byKey[key] = append(byKey[key], trip)
+	}
+
+	sort.Strings(keys)
+	for _, key := range keys {
+		trips := byKey[key]
+		sort.SliceStable(trips, func(i, j int) bool {
+			return trips[i].departures[0] < trips[j].departures[0]
+		})
+
+		// Trips that overtake an earlier trip go into a pattern of their own so
+		// every pattern can be searched assuming first in, first out.
+		groups := make([][]*patternTrip, 0, 1)
+		for _, trip := range trips {
+			placed := false
+			for g, group := range groups {
+				if !overtakes(group[len(group)-1], trip) {
+					groups[g] = append(group, trip)
+					placed = true
+					break
+				}
+			}
+			if !placed {
+				groups = append(groups, []*patternTrip{trip})
+			}
+		}
+
+		for _, group := range groups {
+			p := &pattern{
+				route: n.routes[group[0].trip.RouteId],
+				stops: stopsOf[key],
+				trips: group,
+			}
+			for position, stop := range p.stops {
+				n.patternsAt[stop] = append(n.patternsAt[stop], patternStop{pattern: len(n.patterns), position: position})
+			}
+			n.patterns = append(n.patterns, p)
+		}
+	}
+}
+
+// addFootpaths adds the walking transfers of transfers.txt, then generated
+// walking transfers and walks between the platforms of a station for stop
+// pairs transfers.txt doesn't cover. Transfers from a stop to itself set the
+// minimum change time at that stop.
+func (n *network) addFootpaths() {
+	explicit := make(map[[2]int]bool)
+
+	for _, transfer := range n.transfers {
+		// Route and trip specific transfers don't describe a walk between stops.
+		if transfer.FromRouteId != "" || transfer.ToRouteId != "" || transfer.FromTripId != "" || transfer.ToTripId != "" {
+			continue
+		}
+		from, ok := n.stopIdx[transfer.FromStopId]
+		if !ok {
+			continue
+		}
+		to, ok := n.stopIdx[transfer.ToStopId]
+		if !ok {
+			continue
+		}
+		if from == to {
+			if transfer.TransferType == gtfspec.TransferMinTime && transfer.MinTransferTime > n.minChange[from] {
+				n.minChange[from] = transfer.MinTransferTime
+			}
+			continue
+		}
+		explicit[[2]int{from, to}] = true
+
+		switch transfer.TransferType {
+		case gtfspec.TransferNotPossible:
+			continue
+		case gtfspec.TransferMinTime:
+			n.footpaths[from] = append(n.footpaths[from], footpath{to: to, seconds: transfer.MinTransferTime})
+		default:
+			n.footpaths[from] = append(n.footpaths[from], footpath{to: to, seconds: walkSeconds(n.stops[from], n.stops[to])})
+		}
+	}
+
+	for _, transfer := range n.walking {
+		from, ok := n.stopIdx[transfer.FromStopId]
+		if !ok {
+			continue
+		}
+		to, ok := n.stopIdx[transfer.ToStopId]
+		if !ok || from == to || explicit[[2]int{from, to}] {
+			continue
+		}
+		explicit[[2]int{from, to}] = true
+		// Straight-line walks between neighboring platforms understate the
+		// change, so they take at least as long as the station minimum.
+		seconds := transfer.WalkTime
+		if parent := n.stops[from].ParentStation; parent != "" && parent == n.stops[to].ParentStation {
+			seconds = max(seconds, stationTransferSeconds)
+		}
+		n.footpaths[from] = append(n.footpaths[from], footpath{to: to, seconds: seconds})
+	}
+
+	platforms := make(map[string][]int)
+	for ndx, stop := range n.stops {
+		if stop.LocationType == gtfspec.LocationStop && stop.ParentStation != "" {
+			platforms[stop.ParentStation] = append(platforms[stop.ParentStation], ndx)
+		}
+	}
+	for _, stops := range platforms {
+		for _, from := range stops {
+			for _, to := range stops {
+				if from == to || explicit[[2]int{from, to}] {
+					continue
+				}
+				n.footpaths[from] = append(n.footpaths[from], footpath{to: to, seconds: stationTransferSeconds})
+			}
+		}
+	}
+}
+
+// earliestTrip returns the first trip of the pattern that can be boarded at
+// a position at or after a time, or nil if there is none
+func (p *pattern) earliestTrip(position int, after int) *patternTrip {
+	first := sort.Search(len(p.trips), func(i int) bool {
+		return p.trips[i].departures[position] >= after
+	})
+	for i := first; i < len(p.trips); i++ {
+		trip := p.trips[i]
+		if trip.departures[position] < infinity && trip.stopTimes[position].PickupType != gtfspec.PickupNone {
+			return trip
+		}
+	}
+	return nil
+}
+
+// overtakes reports whether trip b, departing after trip a, arrives or
+// departs earlier than a at any stop
+func overtakes(a *patternTrip, b *patternTrip) bool {
+	for i := range a.departures {
+		if b.departures[i] < a.departures[i] || b.arrivals[i] < a.arrivals[i] {
+			return true
+		}
+	}
+	return false
+}
+
+// interpolate returns the arrival and departure times of a trip's stop
+// times, filling stops without times linearly between the timepoints
+// around them
+func interpolate(stopTimes []*gtfspec.StopTime, offset int) ([]int, []int) {
+	arrivals := make([]int, len(stopTimes))
+	departures := make([]int, len(stopTimes))
+
+	known := make([]int, 0, len(stopTimes))
+	for i, stopTime := range stopTimes {
+		arrivals[i], departures[i] = infinity, infinity
+		if stopTime.ArrivalTime.IsSet() {
+			arrivals[i] = stopTime.ArrivalTime.Seconds() + offset
+		}
+		if stopTime.DepartureTime.IsSet() {
+			departures[i] = stopTime.DepartureTime.Seconds() + offset
+		}
+		if arrivals[i] == infinity {
+			arrivals[i] = departures[i]
+		}
+		if departures[i] == infinity {
+			departures[i] = arrivals[i]
+		}
+		if arrivals[i] != infinity {
+			known = append(known, i)
+		}
+	}
+
+	for k := 1; k < len(known); k++ {
+		from, to := known[k-1], known[k]
+		for i := from + 1; i < to; i++ {
+			t := departures[from] + (arrivals[to]-departures[from])*(i-from)/(to-from)
+			arrivals[i], departures[i] = t, t
+		}
+	}
+
+	return arrivals, departures
+}
+
+// walkSeconds estimates the time to walk in a straight line between two stops
+func walkSeconds(from *gtfspec.Stop, to *gtfspec.Stop) int {
+	return int(math.Ceil(from.Distance(to) / walkingSpeed))
+}
@@ -0,0 +1,398 @@
+package planner
+
+import (
+	"os"
+	"time"
+
+	"github.com/rmrfslashbin/gomarta/pkg/gtfspec"
+	"github.com/rmrfslashbin/gomarta/pkg/schedule"
+	"github.com/rmrfslashbin/gomarta/pkg/store"
+	"github.com/rs/zerolog"
+)
+
+// defaultMaxTransfers is used when the input asks for the default with a negative limit
+const defaultMaxTransfers = 3
+
+// Options for the planner instance
+type Option func(c *Planner)
+
+// Planner finds journeys over the static schedule using RAPTOR
+type Planner struct {
+	log  *zerolog.Logger
+	db   store.Reader
+	feed *gtfspec.Feed
+
+	schedule *schedule.Schedule
+	walking  []*gtfspec.WalkingTransfer
+	stops    map[string]*gtfspec.Stop
+	children map[string][]*gtfspec.Stop
+	routes   map[string]*gtfspec.Route
+
+	// net is the network of the last planned service date
+	net *network
+}
+
+// New creates a new planner instance
+func New(opts ...Option) (*Planner, error) {
+	cfg := &Planner{}
+
+	// apply the list of options to Planner
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	// set up logger if not provided
+	if cfg.log == nil {
+		log := zerolog.New(os.Stderr).With().Timestamp().Logger()
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+		cfg.log = &log
+	}
+
+	scheduleOpts := []schedule.Option{schedule.WithLogger(cfg.log)}
+	if cfg.feed != nil {
+		scheduleOpts = append(scheduleOpts, schedule.WithFeed(cfg.feed))
+	} else if cfg.db != nil {
+		feed, err := loadFeed(cfg.db)
+		if err != nil {
+			return nil, err
+		}
+		cfg.feed = feed
+		if cfg.walking == nil {
+			if cfg.walking, err = cfg.db.GetWalkingTransfers(); err != nil {
+				return nil, &ErrLoadingData{Err: err, Structure: "WalkingTransfers"}
+			}
+		}
+		scheduleOpts = append(scheduleOpts, schedule.WithDatabase(cfg.db))
+	} else {
+		return nil, &ErrNoData{}
+	}
+
+	sched, err := schedule.New(scheduleOpts...)
+	if err != nil {
+		return nil, err
+	}
+	cfg.schedule = sched
+
+	cfg.index()
+
+	return cfg, nil
+}
+
+// WithDatabase reads the schedule from the database
+func WithDatabase(db store.Reader) Option {
+	return func(c *Planner) {
+		c.db = db
+	}
+}
+
+// WithFeed reads the schedule from an already parsed feed
+func WithFeed(feed *gtfspec.Feed) Option {
+	return func(c *Planner) {
+		c.feed = feed
+	}
+}
+
+// WithWalkingTransfers adds generated walking transfers to the feed's transfers.
+// With WithDatabase they are read from the database unless given here.
+func WithWalkingTransfers(transfers []*gtfspec.WalkingTransfer) Option {
+	return func(c *Planner) {
+		c.walking = transfers
+	}
+}
+
+// WithLogger sets the logger for the planner instance
+func WithLogger(log *zerolog.Logger) Option {
+	return func(c *Planner) {
+		c.log = log
+	}
+}
+
+// loadFeed reads the tables the planner needs up front from the database.
+// Trips and stop times are loaded per service date.
+func loadFeed(db store.Reader) (*gtfspec.Feed, error) {
+	feed := &gtfspec.Feed{}
+
+	var err error
+	if feed.Routes, err = db.GetRoutes(); err != nil {
+		return nil, &ErrLoadingData{Err: err, Structure: "Routes"}
+	}
+	if feed.Stops, err = db.GetAllStops(); err != nil {
+		return nil, &ErrLoadingData{Err: err, Structure: "Stops"}
+	}
+	if feed.Transfers, err = db.GetTransfers(); err != nil {
+		return nil, &ErrLoadingData{Err: err, Structure: "Transfers"}
+	}
+
+	return feed, nil
+}
+
+// index builds the stop and route lookups
+func (c *Planner) index() {
+	c.stops = make(map[string]*gtfspec.Stop, len(c.feed.Stops))
+	c.children = make(map[string][]*gtfspec.Stop)
+	for _, stop := range c.feed.Stops {
+		c.stops[stop.StopId] = stop
+		if stop.ParentStation != "" && stop.LocationType == gtfspec.LocationStop {
+			c.children[stop.ParentStation] = append(c.children[stop.ParentStation], stop)
+		}
+	}
+
+	c.routes = make(map[string]*gtfspec.Route, len(c.feed.Routes))
+	for _, route := range c.feed.Routes {
+		c.routes[route.RouteId] = route
+	}
+}
+
+// Location returns the timezone schedule times are expressed in
+func (c *Planner) Location() *time.Location {
+	return c.schedule.Location()
+}
+
+// Plan finds the earliest arrivals from one stop to another, one itinerary
+// for each number of transfers that arrives earlier than with fewer transfers
+func (c *Planner) Plan(input *PlanInput) (*Plan, error) {
+	from, ok := c.stops[input.From]
+	if !ok {
+		return nil, &ErrUnknownStop{StopId: input.From}
+	}
+	to, ok := c.stops[input.To]
+	if !ok {
+		return nil, &ErrUnknownStop{StopId: input.To}
+	}
+
+	for _, origin := range c.expand(from) {
+		for _, target := range c.expand(to) {
+			if origin.StopId == target.StopId {
+				return nil, &ErrSameStop{From: input.From, To: input.To}
+			}
+		}
+	}
+
+	depart := input.Depart
+	if depart.IsZero() {
+		depart = time.Now()
+	}
+	local := depart.In(c.schedule.Location())
+	date := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+
+	maxTransfers := input.MaxTransfers
+	if maxTransfers < 0 {
+		maxTransfers = defaultMaxTransfers
+	}
+
+	n, err := c.network(date)
+	if err != nil {
+		return nil, err
+	}
+
+	origins := n.expand(c.expand(from))
+	targets := n.expand(c.expand(to))
+	start := gtfspec.GTFSTimeOf(depart, date, c.schedule.Location()).Seconds()
+
+	plan := &Plan{
+		From:   from,
+		To:     to,
+		Depart: depart,
+	}
+
+	if len(input.Realtime) > 0 {
+		plan.Scheduled = c.itineraries(n, origins, targets, start, maxTransfers+1)
+		n = n.overlay(input.Realtime)
+	}
+	plan.Itineraries = c.itineraries(n, origins, targets, start, maxTransfers+1)
+
+	if len(plan.Itineraries) == 0 {
+		return nil, &ErrNoItinerary{From: input.From, To: input.To}
+	}
+
+	return plan, nil
+}
+
+// itineraries runs RAPTOR and returns one itinerary for each round that
+// reaches a target earlier than the rounds before it
+func (c *Planner) itineraries(n *network, stops []int, targets []int, start int, maxTrips int) []*Itinerary {
+	origins := make([]origin, 0, len(stops))
+	for _, stop := range stops {
+		origins = append(origins, origin{stop: stop, arrival: start})
+	}
+	rounds := n.raptor(origins, targets, infinity, maxTrips)
+
+	itineraries := make([]*Itinerary, 0)
+	earliest := infinity
+	for k, round := range rounds {
+		target := -1
+		for _, stop := range targets {
+			if round[stop].arrival < earliest && (target < 0 || round[stop].arrival < round[target].arrival) {
+				target = stop
+			}
+		}
+		if target < 0 {
+			continue
+		}
+		earliest = round[target].arrival
+		itineraries = append(itineraries, c.itinerary(n, rounds, k, target))
+	}
+
+	return itineraries
+}
+
+// expand returns the stops a rider can start or end at: the platforms of a
+// station, or the stop itself
+func (c *Planner) expand(stop *gtfspec.Stop) []*gtfspec.Stop {
+	if stop.LocationType == gtfspec.LocationStation {
+		return c.children[stop.StopId]
+	}
+	return []*gtfspec.Stop{stop}
+}
+
+// network returns the RAPTOR network of a service date, building it if needed
+func (c *Planner) network(date time.Time) (*network, error) {
+	if c.net != nil && c.net.date.Equal(date) {
+		return c.net, nil
+	}
+
+	loc := c.schedule.Location()
+	runs := make([]*tripRun, 0)
+
+	// Trips of the previous service date that run past midnight can still be ridden.
+	for _, serviceDate := range []time.Time{date.AddDate(0, 0, -1), date} {
+		offset := int(gtfspec.ServiceDayStart(serviceDate, loc).Sub(gtfspec.ServiceDayStart(date, loc)) / time.Second)
+
+		trips, err := c.schedule.Trips(serviceDate, "")
+		if err != nil {
+			return nil, err
+		}
+		tripIds := make([]string, 0, len(trips))
+		for _, trip := range trips {
+			tripIds = append(tripIds, trip.TripID)
+		}
+		stopTimes, err := c.schedule.StopTimes(tripIds)
+		if err != nil {
+			return nil, err
+		}
+
+		for _, trip := range trips {
+			run := &tripRun{
+				trip:        trip,
+				serviceDate: serviceDate,
+				offset:      offset,
+				stopTimes:   stopTimes[trip.TripID],
+			}
+			if offset < 0 && lastTime(run.stopTimes)+offset < 0 {
+				continue
+			}
+			runs = append(runs, run)
+		}
+	}
+
+	c.log.Debug().
+		Str("date", date.Format(gtfspec.DateFormat)).
+		Int("trips", len(runs)).
+		Str("function", "pkg/planner.network()").
+		Msg("building network")
+
+	c.net = newNetwork(date, loc, c.feed.Stops, c.feed.Transfers, c.walking, c.routes, runs)
+	return c.net, nil
+}
+
+// itinerary rebuilds the journey that reached a stop in round k
+func (c *Planner) itinerary(n *network, rounds [][]label, k int, stop int) *Itinerary {
+	legs := make([]*Leg, 0)
+
+	for rounds[k][stop].kind == labelTrip || rounds[k][stop].kind == labelWalk {
+		l := rounds[k][stop]
+		switch l.kind {
+		case labelWalk:
+			legs = append(legs, &Leg{
+				Mode:               ModeWalk,
+				From:               n.stops[l.from],
+				To:                 n.stops[stop],
+				Departure:          n.time(rounds[k][l.from].arrival),
+				Arrival:            n.time(l.arrival),
+				ScheduledDeparture: n.time(rounds[k][l.from].arrival),
+				ScheduledArrival:   n.time(l.arrival),
+			})
+		case labelTrip:
+			legs = append(legs, &Leg{
+				Mode:               ModeTransit,
+				From:               n.stops[l.from],
+				To:                 n.stops[stop],
+				Departure:          n.time(l.trip.departures[l.board]),
+				Arrival:            n.time(l.trip.arrivals[l.alight]),
+				ScheduledDeparture: n.time(l.trip.scheduledDepartures[l.board]),
+				ScheduledArrival:   n.time(l.trip.scheduledArrivals[l.alight]),
+				Realtime:           l.trip.realtime,
+				Route:              c.routes[l.trip.trip.RouteId],
+				Trip:               l.trip.trip,
+				StopTimes:          l.trip.stopTimes[l.board : l.alight+1],
+			})
+			k--
+		}
+		stop = l.from
+	}
+
+	for i, j := 0, len(legs)-1; i < j; i, j = i+1, j-1 {
+		legs[i], legs[j] = legs[j], legs[i]
+	}
+
+	// Walks before the first ride start just in time to catch it.
+	first := 0
+	for first < len(legs) && legs[first].Mode == ModeWalk {
+		first++
+	}
+	if first < len(legs) {
+		for i := first - 1; i >= 0; i-- {
+			walk := legs[i].Arrival.Sub(legs[i].Departure)
+			legs[i].Arrival = legs[i+1].Departure
+			legs[i].Departure = legs[i].Arrival.Add(-walk)
+			legs[i].ScheduledArrival = legs[i+1].ScheduledDeparture
+			legs[i].ScheduledDeparture = legs[i].ScheduledArrival.Add(-walk)
+		}
+	}
+
+	itinerary := &Itinerary{Legs: legs}
+	if len(legs) > 0 {
+		itinerary.Departure = legs[0].Departure
+		itinerary.Arrival = legs[len(legs)-1].Arrival
+	}
+	for _, leg := range legs {
+		if leg.Mode == ModeTransit {
+			itinerary.Transfers++
+		}
+	}
+	if itinerary.Transfers > 0 {
+		itinerary.Transfers--
+	}
+
+	return itinerary
+}
+
+// expand returns the network indexes of stops
+func (n *network) expand(stops []*gtfspec.Stop) []int {
+	indexes := make([]int, 0, len(stops))
+	for _, stop := range stops {
+		if ndx, ok := n.stopIdx[stop.StopId]; ok {
+			indexes = append(indexes, ndx)
+		}
+	}
+	return indexes
+}
+
+// time converts seconds on the network's clock to an absolute time
+func (n *network) time(seconds int) time.Time {
+	return gtfspec.ServiceDayStart(n.date, n.loc).Add(time.Duration(seconds) * time.Second)
+}
+
+// lastTime returns the latest time of a trip's stop times
+func lastTime(stopTimes []*gtfspec.StopTime) int {
+	last := -1
+	for _, stopTime := range stopTimes {
+		if stopTime.ArrivalTime.Seconds() > last {
+			last = stopTime.ArrivalTime.Seconds()
+		}
+		if stopTime.DepartureTime.Seconds() > last {
+			last = stopTime.DepartureTime.Seconds()
+		}
+	}
+	return last
+}
@@ -0,0 +1,170 @@
+package planner
+
+import (
+	"github.com/rmrfslashbin/gomarta/pkg/gtfspec"
+)
+
+// labelKind tells how a stop was reached in a round
+type labelKind int
+
+const (
+	labelNone labelKind = iota
+	labelOrigin
+	labelTrip
+	labelWalk
+)
+
+// label is the earliest arrival at a stop in a round and how it was reached
+type label struct {
+	arrival int
+	kind    labelKind
+
+	// from is the stop the trip was boarded or the walk started at
+	from int
+
+	// trip, board and alight describe the ride of a labelTrip
+	trip   *patternTrip
+	board  int
+	alight int
+}
+
+// origin is a stop a search starts from and the time the rider is there
+type origin struct {
+	stop    int
+	arrival int
+}
+
+// raptor runs rounds of the RAPTOR algorithm from the origins. Round k holds
+// the earliest arrivals using k trips. Arrivals at or after limit are
+// discarded.
+func (n *network) raptor(origins []origin, targets []int, limit int, maxTrips int) [][]label {
+	best := make([]int, len(n.stops))
+	for i := range best {
+		best[i] = infinity
+	}
+
+	isTarget := make(map[int]bool, len(targets))
+	for _, target := range targets {
+		isTarget[target] = true
+	}
+	targetBest := limit
+
+	improve := func(round []label, stop int, l label) bool {
+		if l.arrival >= best[stop] || l.arrival >= targetBest {
+			return false
+		}
+		round[stop] = l
+		best[stop] = l.arrival
+		if isTarget[stop] && l.arrival < targetBest {
+			targetBest = l.arrival
+		}
+		return true
+	}
+
+	rounds := make([][]label, 0, maxTrips+1)
+	rounds = append(rounds, n.newRound())
+
+	marked := make(map[int]bool)
+	for _, o := range origins {
+		if improve(rounds[0], o.stop, label{arrival: o.arrival, kind: labelOrigin}) {
+			marked[o.stop] = true
+		}
+	}
+	n.walk(rounds[0], marked, improve)
+
+	for k := 1; k <= maxTrips && len(marked) > 0; k++ {
+		previous := rounds[k-1]
+		current := n.newRound()
+		rounds = append(rounds, current)
+
+		// Scan each pattern from the earliest marked stop it serves.
+		queue := make(map[int]int)
+		for stop := range marked {
+			for _, ps := range n.patternsAt[stop] {
+				if position, ok := queue[ps.pattern]; !ok || ps.position < position {
+					queue[ps.pattern] = ps.position
+				}
+			}
+		}
+		marked = make(map[int]bool)
+
+		for patternNdx, start := range queue {
+			p := n.patterns[patternNdx]
+
+			var trip *patternTrip
+			board := -1
+
+			for position := start; position < len(p.stops); position++ {
+				stop := p.stops[position]
+
+				if trip != nil && trip.stopTimes[position].DropOffType != gtfspec.DropOffNone {
+					if improve(current, stop, label{
+						arrival: trip.arrivals[position],
+						kind:    labelTrip,
+						from:    p.stops[board],
+						trip:    trip,
+						board:   board,
+						alight:  position,
+					}) {
+						marked[stop] = true
+					}
+				}
+
+				// Catch an earlier trip if the stop was reached in time for it.
+				// Riders getting off another vehicle here need the stop's
+				// minimum change time; walks already include theirs.
+				arrival := previous[stop].arrival
+				if arrival == infinity {
+					continue
+				}
+				if previous[stop].kind == labelTrip {
+					arrival += n.minChange[stop]
+				}
+				if trip != nil && arrival > trip.departures[position] {
+					continue
+				}
+				if earlier := p.earliestTrip(position, arrival); earlier != nil && earlier != trip {
+					trip = earlier
+					board = position
+				}
+			}
+		}
+
+		n.walk(current, marked, improve)
+	}
+
+	return rounds
+}
+
+// walk relaxes the footpaths of the stops marked in a round. Walks only start
+// from stops reached by a trip, so walks are never chained.
+func (n *network) walk(round []label, marked map[int]bool, improve func([]label, int, label) bool) {
+	stops := make([]int, 0, len(marked))
+	for stop := range marked {
+		stops = append(stops, stop)
+	}
+
+	for _, from := range stops {
+		if round[from].kind == labelWalk {
+			continue
+		}
+		for _, fp := range n.footpaths[from] {
+			if improve(round, fp.to, label{
+				arrival: round[from].arrival + fp.seconds,
+				kind:    labelWalk,
+				from:    from,
+			}) {
+				marked[fp.to] = true
+			}
+		}
+	}
+}
+
+// newRound returns a round with every stop unreached
+func (n *network) newRound() []label {
+	round := make([]label, len(n.stops))
+	for i := range round {
+		round[i].arrival = infinity
+	}
+	return round
+}
@@ -0,0 +1,167 @@
+package planner
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/rmrfslashbin/gomarta/pkg/gtfspec"
+	"github.com/rs/zerolog"
+)
+
+// testFeed is two lines meeting at stop B, plus a slow direct line:
+//
+//	route 1: A 08:00 -> B 08:10
+//	route 2: B 08:15 -> C 08:30, B 08:25 -> C 08:40
+//	route 3: A 08:00 -> C 09:30
+//
+// The stops are kilometers apart, so no walk connects them.
+func testFeed(transfers ...*gtfspec.Transfer) *gtfspec.Feed {
+	at := func(s string) gtfspec.GTFSTime {
+		t, err := gtfspec.ParseGTFSTime(s)
+		if err != nil {
+			panic(err)
+		}
+		return t
+	}
+	stopTime := func(tripId string, stopId string, sequence int, time string) *gtfspec.StopTime {
+		return &gtfspec.StopTime{TripId: tripId, StopId: stopId, StopSequence: sequence, ArrivalTime: at(time), DepartureTime: at(time)}
+	}
+
+	return &gtfspec.Feed{
+		Agencies: []*gtfspec.Agency{{AgencyId: "MARTA", Timezone: "UTC"}},
+		Calendars: []*gtfspec.Calendar{{
+			ServiceId: "ALL",
+			Monday:    1, Tuesday: 1, Wednesday: 1, Thursday: 1, Friday: 1, Saturday: 1, Sunday: 1,
+			StartDate: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC),
+			EndDate:   time.Date(2024, 12, 31, 0, 0, 0, 0, time.UTC),
+		}},
+		Routes: []*gtfspec.Route{
+			{RouteId: "R1", AgencyId: "MARTA", ShortName: "1"},
+			{RouteId: "R2", AgencyId: "MARTA", ShortName: "2"},
+			{RouteId: "R3", AgencyId: "MARTA", ShortName: "3"},
+		},
+		Stops: []*gtfspec.Stop{
+			{StopId: "A", Lat: 33.70, Lon: -84.39},
+			{StopId: "B", Lat: 33.75, Lon: -84.39},
+			{StopId: "C", Lat: 33.80, Lon: -84.39},
+		},
+		Trips: []*gtfspec.Trip{
+			{TripID: "T1", RouteId: "R1", ServiceId: "ALL"},
+			{TripID: "T2", RouteId: "R2", ServiceId: "ALL"},
+			{TripID: "T3", RouteId: "R2", ServiceId: "ALL"},
+			{TripID: "T4", RouteId: "R3", ServiceId: "ALL"},
+		},
+		StopTimes: []*gtfspec.StopTime{
+			stopTime("T1", "A", 1, "08:00:00"),
+			stopTime("T1", "B", 2, "08:10:00"),
+			stopTime("T2", "B", 1, "08:15:00"),
+			stopTime("T2", "C", 2, "08:30:00"),
+			stopTime("T3", "B", 1, "08:25:00"),
+			stopTime("T3", "C", 2, "08:40:00"),
+			stopTime("T4", "A", 1, "08:00:00"),
+			stopTime("T4", "C", 2, "09:30:00"),
+		},
+		Transfers: transfers,
+	}
+}
+
+func TestPlan(t *testing.T) {
+	clock := func(hour int, minute int) time.Time {
+		return time.Date(2024, 6, 3, hour, minute, 0, 0, time.UTC)
+	}
+
+	tests := []struct {
+		name         string
+		transfers    []*gtfspec.Transfer
+		maxTransfers int
+
+		// arrivals and changes are the arrival time and number of transfers
+		// of each itinerary, from fewest transfers to most
+		arrivals []time.Time
+		changes  []int
+	}{
+		{
+			name:         "earliest arrival",
+			maxTransfers: 1,
+			arrivals:     []time.Time{clock(9, 30), clock(8, 30)},
+			changes:      []int{0, 1},
+		},
+		{
+			name:         "no transfers",
+			maxTransfers: 0,
+			arrivals:     []time.Time{clock(9, 30)},
+			changes:      []int{0},
+		},
+		{
+			name:         "default transfer limit",
+			maxTransfers: -1,
+			arrivals:     []time.Time{clock(9, 30), clock(8, 30)},
+			changes:      []int{0, 1},
+		},
+		{
+			name: "minimum change time",
+			transfers: []*gtfspec.Transfer{
+				{FromStopId: "B", ToStopId: "B", TransferType: gtfspec.TransferMinTime, MinTransferTime: 600},
+			},
+			maxTransfers: 1,
+			arrivals:     []time.Time{clock(9, 30), clock(8, 40)},
+			changes:      []int{0, 1},
+		},
+		{
+			name: "minimum change time that misses every connection",
+			transfers: []*gtfspec.Transfer{
+				{FromStopId: "B", ToStopId: "B", TransferType: gtfspec.TransferMinTime, MinTransferTime: 1200},
+			},
+			maxTransfers: 1,
+			arrivals:     []time.Time{clock(9, 30)},
+			changes:      []int{0},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			log := zerolog.Nop()
+			planner, err := New(WithFeed(testFeed(tt.transfers...)), WithLogger(&log))
+			if err != nil {
+				t.Fatalf("New() error = %v", err)
+			}
+
+			plan, err := planner.Plan(&PlanInput{
+				From:         "A",
+				To:           "C",
+				Depart:       clock(7, 50),
+				MaxTransfers: tt.maxTransfers,
+			})
+			if err != nil {
+				t.Fatalf("Plan() error = %v", err)
+			}
+
+			if len(plan.Itineraries) != len(tt.arrivals) {
+				t.Fatalf("Plan() returned %d itineraries, want %d", len(plan.Itineraries), len(tt.arrivals))
+			}
+			for i, itinerary := range plan.Itineraries {
+				if !itinerary.Arrival.Equal(tt.arrivals[i]) {
+					t.Errorf("itinerary %d arrives %s, want %s", i, itinerary.Arrival.Format("15:04"), tt.arrivals[i].Format("15:04"))
+				}
+				if itinerary.Transfers != tt.changes[i] {
+					t.Errorf("itinerary %d has %d transfers, want %d", i, itinerary.Transfers, tt.changes[i])
+				}
+			}
+		})
+	}
+}
+
+func TestPlanSameStop(t *testing.T) {
+	log := zerolog.Nop()
+	planner, err := New(WithFeed(testFeed()), WithLogger(&log))
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+
+	_, err = planner.Plan(&PlanInput{From: "B", To: "B"})
+	var sameStop *ErrSameStop
+	if !errors.As(err, &sameStop) {
+		t.Errorf("Plan() error = %v, want *ErrSameStop", err)
+	}
+}
@@ -0,0 +1,101 @@
+package planner
+
+import (
+	"time"
+
+	"github.com/rmrfslashbin/gomarta/pkg/bus"
+	"github.com/rmrfslashbin/gomarta/pkg/gtfspec"
+)
+
+// overlay returns a copy of the network with realtime trip updates applied:
+// canceled trips are removed and predicted times replace the scheduled ones.
+// Patterns are rebuilt, so a late trip that is overtaken by the next one
+// doesn't break the first in, first out search.
+func (n *network) overlay(updates map[string]*bus.Trip) *network {
+	runs := make([]*tripRun, 0, len(n.runs))
+
+	for _, run := range n.runs {
+		update, ok := updates[run.trip.TripID]
+		if !ok || !n.appliesTo(update, run) {
+			runs = append(runs, run)
+			continue
+		}
+		if update.Canceled() {
+			continue
+		}
+
+		arrivals, departures := n.predict(run, update)
+		runs = append(runs, &tripRun{
+			trip:        run.trip,
+			serviceDate: run.serviceDate,
+			offset:      run.offset,
+			stopTimes:   run.stopTimes,
+			arrivals:    arrivals,
+			departures:  departures,
+		})
+	}
+
+	return newNetwork(n.date, n.loc, n.stops, n.transfers, n.walking, n.routes, runs)
+}
+
+// appliesTo reports whether a trip update refers to a run. Updates whose
+// service date couldn't be resolved apply to the network's own service date.
+func (n *network) appliesTo(update *bus.Trip, run *tripRun) bool {
+	if update.Instance != nil {
+		return update.Instance.ServiceDate.Format(gtfspec.DateFormat) == run.serviceDate.Format(gtfspec.DateFormat)
+	}
+	return run.serviceDate.Equal(n.date)
+}
+
+// predict returns the expected arrival and departure times of a run. Stops
+// with a prediction use it; stops before the first prediction keep their
+// scheduled times. Without stop predictions the trip level delay shifts
+// the whole run.
+func (n *network) predict(run *tripRun, update *bus.Trip) ([]int, []int) {
+	arrivals, departures := interpolate(run.stopTimes, run.offset)
+
+	if len(update.Predictions) == 0 {
+		if update.Delay != 0 {
+			for i := range arrivals {
+				if arrivals[i] != infinity {
+					arrivals[i] += int(update.Delay)
+				}
+				if departures[i] != infinity {
+					departures[i] += int(update.Delay)
+				}
+			}
+		}
+		return arrivals, departures
+	}
+
+	positions := make(map[int]int, len(run.stopTimes))
+	for position, stopTime := range run.stopTimes {
+		positions[stopTime.StopSequence] = position
+	}
+
+	for _, prediction := range update.Predictions {
+		position, ok := positions[prediction.StopTime.StopSequence]
+		if !ok {
+			continue
+		}
+		if !prediction.Arrival.IsZero() {
+			arrivals[position] = n.seconds(prediction.Arrival)
+		}
+		if !prediction.Departure.IsZero() {
+			departures[position] = n.seconds(prediction.Departure)
+		}
+		if arrivals[position] == infinity {
+			arrivals[position] = departures[position]
+		}
+		if departures[position] < arrivals[position] {
+			departures[position] = arrivals[position]
+		}
+	}
+
+	return arrivals, departures
+}
+
+// seconds converts an absolute time to seconds on the network's clock
+func (n *network) seconds(t time.Time) int {
+	return int(t.Sub(gtfspec.ServiceDayStart(n.date, n.loc)) / time.Second)
+}
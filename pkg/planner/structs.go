@@ -0,0 +1,89 @@
+package planner
+
+import (
+	"time"
+
+	"github.com/rmrfslashbin/gomarta/pkg/bus"
+	"github.com/rmrfslashbin/gomarta/pkg/gtfspec"
+)
+
+// LegMode is how a leg of an itinerary is travelled
+type LegMode string
+
+const (
+	// ModeTransit is a ride on a bus or train
+	ModeTransit LegMode = "transit"
+
+	// ModeWalk is a walking transfer between stops
+	ModeWalk LegMode = "walk"
+)
+
+// PlanInput is the input for the Plan method
+type PlanInput struct {
+	// From and To are stop ids; a station includes all of its platforms
+	From string
+	To   string
+
+	// Depart is the earliest departure time
+	Depart time.Time
+
+	// MaxTransfers limits the number of vehicle changes. 0 allows direct
+	// trips only; a negative value uses the default of 3.
+	MaxTransfers int
+
+	// Realtime holds trip updates keyed by trip_id, as returned in
+	// bus.FetchOutput.TripUpdates. Predicted delays replace the scheduled
+	// times and canceled trips are left out.
+	Realtime map[string]*bus.Trip
+}
+
+// Plan is the answer to a journey planning query
+type Plan struct {
+	From   *gtfspec.Stop
+	To     *gtfspec.Stop
+	Depart time.Time
+
+	// Itineraries are the Pareto optimal journeys: each arrives earlier than
+	// the previous one but needs more transfers
+	Itineraries []*Itinerary
+
+	// Scheduled are the itineraries of the timetable alone, set when
+	// realtime data is used so the two can be compared
+	Scheduled []*Itinerary
+}
+
+// Itinerary is one way to get from the origin to the destination
+type Itinerary struct {
+	Departure time.Time
+	Arrival   time.Time
+	Transfers int
+	Legs      []*Leg
+}
+
+// Leg is a ride on one trip or a walk between two stops
+type Leg struct {
+	Mode      LegMode
+	From      *gtfspec.Stop
+	To        *gtfspec.Stop
+	Departure time.Time
+	Arrival   time.Time
+
+	// ScheduledDeparture and ScheduledArrival are the timetable times;
+	// Departure and Arrival include realtime delays
+	ScheduledDeparture time.Time
+	ScheduledArrival   time.Time
+
+	// Realtime is set when the leg's times come from a trip update
+	Realtime bool
+
+	// Route, Trip and StopTimes are only set for transit legs.
+	// StopTimes runs from the boarding stop to the alighting stop.
+	Route     *gtfspec.Route
+	Trip      *gtfspec.Trip
+	StopTimes []*gtfspec.StopTime
+}
+
+// Duration returns how long the itinerary takes from first departure to last arrival
+func (i *Itinerary) Duration() time.Duration {
+	return i.Arrival.Sub(i.Departure)
+}
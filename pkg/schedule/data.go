@@ -0,0 +1,187 @@
+package schedule
+
+import (
+	"math"
+	"sort"
+
+	"github.com/rmrfslashbin/gomarta/pkg/gtfspec"
+)
+
+// feedIndex holds lookups over an in-memory feed, built on first use
+type feedIndex struct {
+	stops             map[string]*gtfspec.Stop
+	trips             map[string]*gtfspec.Trip
+	stopTimesByTrip   map[string][]*gtfspec.StopTime
+	stopTimesByStopId map[string][]*gtfspec.StopTime
+}
+
+// feedIndex returns the lookups over the in-memory feed
+func (c *Schedule) feedIndex() *feedIndex {
+	if c.feedIdx != nil {
+		return c.feedIdx
+	}
+
+	idx := &feedIndex{
+		stops:             make(map[string]*gtfspec.Stop, len(c.feed.Stops)),
+		trips:             make(map[string]*gtfspec.Trip, len(c.feed.Trips)),
+		stopTimesByTrip:   make(map[string][]*gtfspec.StopTime),
+		stopTimesByStopId: make(map[string][]*gtfspec.StopTime),
+	}
+	for _, stop := range c.feed.Stops {
+		idx.stops[stop.StopId] = stop
+	}
+	for _, trip := range c.feed.Trips {
+		idx.trips[trip.TripID] = trip
+	}
+	for _, stopTime := range c.feed.StopTimes {
+		idx.stopTimesByTrip[stopTime.TripId] = append(idx.stopTimesByTrip[stopTime.TripId], stopTime)
+		idx.stopTimesByStopId[stopTime.StopId] = append(idx.stopTimesByStopId[stopTime.StopId], stopTime)
+	}
+	for _, stopTimes := range idx.stopTimesByTrip {
+		sortBySequence(stopTimes)
+	}
+
+	c.feedIdx = idx
+	return idx
+}
+
+// StopTimes returns the stop times of each trip, ordered by stop_sequence
+func (c *Schedule) StopTimes(tripIds []string) (map[string][]*gtfspec.StopTime, error) {
+	byTrip := make(map[string][]*gtfspec.StopTime, len(tripIds))
+
+	if c.feed != nil {
+		idx := c.feedIndex()
+		for _, tripId := range tripIds {
+			if stopTimes, ok := idx.stopTimesByTrip[tripId]; ok {
+				byTrip[tripId] = stopTimes
+			}
+		}
+		return byTrip, nil
+	}
+
+	missing := make([]string, 0, len(tripIds))
+	for _, tripId := range tripIds {
+		if stopTimes, ok := c.preloadedStopTimes[tripId]; ok {
+			if len(stopTimes) > 0 {
+				byTrip[tripId] = stopTimes
+			}
+		} else {
+			missing = append(missing, tripId)
+		}
+	}
+	if len(missing) == 0 {
+		return byTrip, nil
+	}
+
+	stopTimes, err := c.db.GetStopTimesForTrips(missing)
+	if err != nil {
+		return nil, &ErrLoadingData{Err: err, Structure: "StopTimes"}
+	}
+	for _, stopTime := range stopTimes {
+		byTrip[stopTime.TripId] = append(byTrip[stopTime.TripId], stopTime)
+	}
+	for _, stopTimes := range byTrip {
+		sortBySequence(stopTimes)
+	}
+	return byTrip, nil
+}
+
+// StopTimesAtStop returns every stop time at a stop, regardless of service date
+func (c *Schedule) StopTimesAtStop(stopId string) ([]*gtfspec.StopTime, error) {
+	if c.feed != nil {
+		return c.feedIndex().stopTimesByStopId[stopId], nil
+	}
+
+	stopTimes, err := c.db.GetStopTimesAtStop(stopId, 0, math.MaxInt32)
+	if err != nil {
+		return nil, &ErrLoadingData{Err: err, Structure: "StopTimes"}
+	}
+	return stopTimes, nil
+}
+
+// Stops returns the stops with the given ids, keyed by stop_id
+func (c *Schedule) Stops(stopIds []string) (map[string]*gtfspec.Stop, error) {
+	stops := make(map[string]*gtfspec.Stop, len(stopIds))
+
+	if c.feed != nil {
+		idx := c.feedIndex()
+		for _, stopId := range stopIds {
+			if stop, ok := idx.stops[stopId]; ok {
+				stops[stopId] = stop
+			}
+		}
+		return stops, nil
+	}
+
+	found, err := c.db.GetStops(stopIds)
+	if err != nil {
+		return nil, &ErrLoadingData{Err: err, Structure: "Stops"}
+	}
+	for _, stop := range found {
+		stops[stop.StopId] = stop
+	}
+	return stops, nil
+}
+
+// TripsById returns the trips with the given ids, keyed by trip_id
+func (c *Schedule) TripsById(tripIds []string) (map[string]*gtfspec.Trip, error) {
+	trips := make(map[string]*gtfspec.Trip, len(tripIds))
+
+	if c.feed != nil {
+		idx := c.feedIndex()
+		for _, tripId := range tripIds {
+			if trip, ok := idx.trips[tripId]; ok {
+				trips[tripId] = trip
+			}
+		}
+		return trips, nil
+	}
+
+	missing := make([]string, 0, len(tripIds))
+	for _, tripId := range tripIds {
+		if trip, ok := c.preloadedTrips[tripId]; ok {
+			trips[tripId] = trip
+		} else {
+			missing = append(missing, tripId)
+		}
+	}
+	if len(missing) == 0 {
+		return trips, nil
+	}
+
+	found, err := c.db.GetTrips(missing)
+	if err != nil {
+		return nil, &ErrLoadingData{Err: err, Structure: "Trips"}
+	}
+	for _, trip := range found {
+		trips[trip.TripID] = trip
+	}
+	return trips, nil
+}
+
+// Preload supplies trips and their stop times already read from the
+// database, so resolving instances of those trips runs no further queries.
+// It replaces whatever was preloaded before.
+func (c *Schedule) Preload(trips []*gtfspec.Trip, stopTimes []*gtfspec.StopTime) {
+	c.preloadedTrips = make(map[string]*gtfspec.Trip, len(trips))
+	c.preloadedStopTimes = make(map[string][]*gtfspec.StopTime, len(trips))
+	for _, trip := range trips {
+		c.preloadedTrips[trip.TripID] = trip
+		c.preloadedStopTimes[trip.TripID] = nil
+	}
+	for _, stopTime := range stopTimes {
+		if _, ok := c.preloadedStopTimes[stopTime.TripId]; ok {
+			c.preloadedStopTimes[stopTime.TripId] = append(c.preloadedStopTimes[stopTime.TripId], stopTime)
+		}
+	}
+	for _, stopTimes := range c.preloadedStopTimes {
+		sortBySequence(stopTimes)
+	}
+}
+
+// sortBySequence orders a trip's stop times by stop_sequence
+func sortBySequence(stopTimes []*gtfspec.StopTime) {
+	sort.SliceStable(stopTimes, func(i, j int) bool {
+		return stopTimes[i].StopSequence < stopTimes[j].StopSequence
+	})
+}
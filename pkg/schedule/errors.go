@@ -0,0 +1,127 @@
+package schedule
+
+// ErrLoadingData is returned when schedule tables cannot be read from the database.
+type ErrLoadingData struct {
+	Err       error
+	Structure string
+	Msg       string
+}
+
+// Error returns the error message.
+func (e *ErrLoadingData) Error() string {
+	if e.Msg == "" {
+		e.Msg = "error loading schedule data"
+	}
+	if e.Structure != "" {
+		e.Msg += ": " + e.Structure
+	}
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
+
+// ErrNoData is returned when neither a database nor a feed is provided.
+type ErrNoData struct {
+	Err error
+	Msg string
+}
+
+// Error returns the error message.
+func (e *ErrNoData) Error() string {
+	if e.Msg == "" {
+		e.Msg = "no schedule data provided- use WithDatabase() or WithFeed()"
+	}
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
+
+// ErrUnknownRoute is returned when a route cannot be found by id or short name.
+type ErrUnknownRoute struct {
+	Err     error
+	RouteId string
+	Msg     string
+}
+
+// Error returns the error message.
+func (e *ErrUnknownRoute) Error() string {
+	if e.Msg == "" {
+		e.Msg = "unknown route"
+	}
+	if e.RouteId != "" {
+		e.Msg += ": " + e.RouteId
+	}
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
+
+// ErrTimezone is returned when the agency timezone cannot be loaded.
+type ErrTimezone struct {
+	Err      error
+	Timezone string
+	Msg      string
+}
+
+// Error returns the error message.
+func (e *ErrTimezone) Error() string {
+	if e.Msg == "" {
+		e.Msg = "error loading agency timezone"
+	}
+	if e.Timezone != "" {
+		e.Msg += ": " + e.Timezone
+	}
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
+
+// ErrUnknownTrip is returned when a trip_id is not in the feed.
+type ErrUnknownTrip struct {
+	Err    error
+	TripId string
+	Msg    string
+}
+
+// Error returns the error message.
+func (e *ErrUnknownTrip) Error() string {
+	if e.Msg == "" {
+		e.Msg = "unknown trip"
+	}
+	if e.TripId != "" {
+		e.Msg += ": " + e.TripId
+	}
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
+
+// ErrNotRunning is returned when a trip does not run on the requested service date.
+type ErrNotRunning struct {
+	Err    error
+	TripId string
+	Date   string
+	Msg    string
+}
+
+// Error returns the error message.
+func (e *ErrNotRunning) Error() string {
+	if e.Msg == "" {
+		e.Msg = "trip does not run on service date"
+	}
+	if e.TripId != "" {
+		e.Msg += ": " + e.TripId
+	}
+	if e.Date != "" {
+		e.Msg += " on " + e.Date
+	}
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
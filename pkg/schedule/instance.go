@@ -0,0 +1,267 @@
+package schedule
+
+import (
+	"time"
+
+	"github.com/rmrfslashbin/gomarta/pkg/gtfspec"
+)
+
+// instanceSlack is how far outside its scheduled span a realtime observation
+// may fall and still be matched to a trip instance when no start date is given
+const instanceSlack = 2 * time.Hour
+
+// TripInstance is one run of a trip: the static trip on a service date, with
+// its stop times resolved to absolute timestamps. A trip that runs past
+// midnight keeps the service date it started on.
+type TripInstance struct {
+	Trip        *gtfspec.Trip
+	ServiceDate time.Time
+
+	// Offset shifts the trip's stop times, for runs of a frequency based trip
+	// that start later than the template in stop_times.txt
+	Offset int
+
+	StopTimes []*InstanceStopTime
+}
+
+// InstanceStopTime is a stop time of a trip instance. Arrival and Departure
+// are zero if the stop time has no time, such as a stop between timepoints.
+type InstanceStopTime struct {
+	StopTime  *gtfspec.StopTime
+	Arrival   time.Time
+	Departure time.Time
+}
+
+// InstanceInput is the input for the Instance method
+type InstanceInput struct {
+	TripId string
+
+	// StartDate is the service date (YYYYMMDD) as given by a GTFS-RT trip descriptor.
+	// If empty, the service date is inferred from Observed.
+	StartDate string
+
+	// StartTime is the start time (HH:MM:SS) of a frequency based trip as given by
+	// a GTFS-RT trip descriptor. It is ignored for trips without frequencies.
+	StartTime string
+
+	// Observed is when the realtime data was produced; defaults to now
+	Observed time.Time
+}
+
+// InstanceKey returns the key identifying a trip instance, trip_id plus service date
+func InstanceKey(tripId string, serviceDate time.Time) string {
+	return tripId + "@" + serviceDate.Format(gtfspec.DateFormat)
+}
+
+// Key returns the key identifying the trip instance
+func (t *TripInstance) Key() string {
+	return InstanceKey(t.Trip.TripID, t.ServiceDate)
+}
+
+// Start returns the first scheduled departure of the trip instance
+func (t *TripInstance) Start() time.Time {
+	for _, stopTime := range t.StopTimes {
+		if !stopTime.Departure.IsZero() {
+			return stopTime.Departure
+		}
+	}
+	return time.Time{}
+}
+
+// End returns the last scheduled arrival of the trip instance
+func (t *TripInstance) End() time.Time {
+	for i := len(t.StopTimes) - 1; i >= 0; i-- {
+		if !t.StopTimes[i].Arrival.IsZero() {
+			return t.StopTimes[i].Arrival
+		}
+	}
+	return time.Time{}
+}
+
+// TripInstance returns the run of a trip on a service date
+func (c *Schedule) TripInstance(tripId string, serviceDate time.Time) (*TripInstance, error) {
+	trip, err := c.trip(tripId)
+	if err != nil {
+		return nil, err
+	}
+	return c.tripInstance(trip, serviceDate, 0)
+}
+
+// Instance resolves the trip instance a realtime trip descriptor refers to.
+// Without a start date the service date is inferred from the observation time,
+// trying the previous day first so trips that cross midnight are matched to
+// the service date they started on.
+func (c *Schedule) Instance(input *InstanceInput) (*TripInstance, error) {
+	trip, err := c.trip(input.TripId)
+	if err != nil {
+		return nil, err
+	}
+
+	offset, err := c.frequencyOffset(trip.TripID, input.StartTime)
+	if err != nil {
+		return nil, err
+	}
+
+	if input.StartDate != "" {
+		serviceDate, err := time.Parse(gtfspec.DateFormat, input.StartDate)
+		if err != nil {
+			return nil, err
+		}
+		return c.tripInstance(trip, serviceDate, offset)
+	}
+
+	observed := input.Observed
+	if observed.IsZero() {
+		observed = time.Now()
+	}
+	local := observed.In(c.loc)
+	today := time.Date(local.Year(), local.Month(), local.Day(), 0, 0, 0, 0, time.UTC)
+
+	var best *TripInstance
+	var bestDistance time.Duration
+	for _, serviceDate := range []time.Time{today.AddDate(0, 0, -1), today} {
+		instance, err := c.tripInstance(trip, serviceDate, offset)
+		if err != nil {
+			continue
+		}
+		distance := instance.distance(observed)
+		if best == nil || distance < bestDistance {
+			best = instance
+			bestDistance = distance
+		}
+	}
+
+	if best == nil || bestDistance > instanceSlack {
+		return nil, &ErrNotRunning{TripId: trip.TripID, Date: today.Format(gtfspec.DateFormat)}
+	}
+	return best, nil
+}
+
+// tripInstance builds the run of a trip on a service date
+func (c *Schedule) tripInstance(trip *gtfspec.Trip, serviceDate time.Time, offset int) (*TripInstance, error) {
+	if !c.IsActive(trip.ServiceId, serviceDate) {
+		return nil, &ErrNotRunning{TripId: trip.TripID, Date: serviceDate.Format(gtfspec.DateFormat)}
+	}
+
+	stopTimes, err := c.StopTimes([]string{trip.TripID})
+	if err != nil {
+		return nil, err
+	}
+
+	instance := &TripInstance{
+		Trip:        trip,
+		ServiceDate: serviceDate,
+		Offset:      offset,
+		StopTimes:   make([]*InstanceStopTime, 0, len(stopTimes[trip.TripID])),
+	}
+	for _, stopTime := range stopTimes[trip.TripID] {
+		instanceStopTime := &InstanceStopTime{StopTime: stopTime}
+		if stopTime.ArrivalTime.IsSet() {
+			instanceStopTime.Arrival = stopTime.ArrivalTime.Add(offset).Time(serviceDate, c.loc)
+		}
+		if stopTime.DepartureTime.IsSet() {
+			instanceStopTime.Departure = stopTime.DepartureTime.Add(offset).Time(serviceDate, c.loc)
+		}
+		instance.StopTimes = append(instance.StopTimes, instanceStopTime)
+	}
+
+	return instance, nil
+}
+
+// distance returns how far a time falls outside the scheduled span of the instance
+func (t *TripInstance) distance(observed time.Time) time.Duration {
+	start, end := t.Start(), t.End()
+	switch {
+	case start.IsZero() || end.IsZero():
+		return instanceSlack + 1
+	case observed.Before(start):
+		return start.Sub(observed)
+	case observed.After(end):
+		return observed.Sub(end)
+	}
+	return 0
+}
+
+// trip looks up a trip by trip_id
+func (c *Schedule) trip(tripId string) (*gtfspec.Trip, error) {
+	trips, err := c.TripsById([]string{tripId})
+	if err != nil {
+		return nil, err
+	}
+	trip, ok := trips[tripId]
+	if !ok {
+		return nil, &ErrUnknownTrip{TripId: tripId}
+	}
+	return trip, nil
+}
+
+// frequencyOffset returns how far a run of a frequency based trip starting at
+// startTime is shifted from the trip's stop_times.txt template
+func (c *Schedule) frequencyOffset(tripId string, startTime string) (int, error) {
+	if startTime == "" {
+		return 0, nil
+	}
+
+	frequencies, err := c.frequencies(tripId)
+	if err != nil {
+		return 0, err
+	}
+	if len(frequencies) == 0 {
+		return 0, nil
+	}
+
+	start, err := gtfspec.ParseGTFSTime(startTime)
+	if err != nil {
+		return 0, err
+	}
+
+	stopTimes, err := c.StopTimes([]string{tripId})
+	if err != nil {
+		return 0, err
+	}
+	first := firstDeparture(stopTimes[tripId])
+	if !first.IsSet() {
+		return 0, nil
+	}
+
+	return start.Seconds() - first.Seconds(), nil
+}
+
+// frequencies returns the frequencies.txt entries of a trip
+func (c *Schedule) frequencies(tripId string) ([]*gtfspec.Frequency, error) {
+	if c.feed != nil {
+		frequencies := make([]*gtfspec.Frequency, 0)
+		for _, frequency := range c.feed.Frequencies {
+			if frequency.TripId == tripId {
+				frequencies = append(frequencies, frequency)
+			}
+		}
+		return frequencies, nil
+	}
+
+	frequencies, err := c.db.GetFrequencies(tripId)
+	if err != nil {
+		return nil, &ErrLoadingData{Err: err, Structure: "Frequencies"}
+	}
+	return frequencies, nil
+}
+
+// StopTimeBySequence returns the stop time with a stop_sequence, or nil if the trip has none
+func (t *TripInstance) StopTimeBySequence(stopSequence int) *InstanceStopTime {
+	for _, stopTime := range t.StopTimes {
+		if stopTime.StopTime.StopSequence == stopSequence {
+			return stopTime
+		}
+	}
+	return nil
+}
+
+// StopTimeByStop returns the first stop time at a stop, or nil if the trip doesn't serve it
+func (t *TripInstance) StopTimeByStop(stopId string) *InstanceStopTime {
+	for _, stopTime := range t.StopTimes {
+		if stopTime.StopTime.StopId == stopId {
+			return stopTime
+		}
+	}
+	return nil
+}
@@ -0,0 +1,222 @@
+package schedule
+
+import (
+	"os"
+	"sort"
+	"time"
+
+	"github.com/rmrfslashbin/gomarta/pkg/gtfspec"
+	"github.com/rmrfslashbin/gomarta/pkg/store"
+	"github.com/rs/zerolog"
+)
+
+// defaultTimezone is used when the feed has no agency timezone
+const defaultTimezone = "America/New_York"
+
+// Options for the schedule instance
+type Option func(c *Schedule)
+
+// Schedule resolves which services and trips run on a given date
+type Schedule struct {
+	log  *zerolog.Logger
+	db   store.Reader
+	feed *gtfspec.Feed
+	loc  *time.Location
+
+	calendars  map[string]*gtfspec.Calendar
+	exceptions map[string]map[int]int
+	serviceIds []string
+	feedIdx    *feedIndex
+
+	// trips and stop times supplied by Preload, consulted before the database
+	preloadedTrips     map[string]*gtfspec.Trip
+	preloadedStopTimes map[string][]*gtfspec.StopTime
+}
+
+// New creates a new schedule instance
+func New(opts ...Option) (*Schedule, error) {
+	cfg := &Schedule{}
+
+	// apply the list of options to Schedule
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	// set up logger if not provided
+	if cfg.log == nil {
+		log := zerolog.New(os.Stderr).With().Timestamp().Logger()
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+		cfg.log = &log
+	}
+
+	calendars := make([]*gtfspec.Calendar, 0)
+	calendarDates := make([]*gtfspec.CalendarDate, 0)
+	agencies := make([]*gtfspec.Agency, 0)
+
+	if cfg.feed != nil {
+		calendars = cfg.feed.Calendars
+		calendarDates = cfg.feed.CalendarDates
+		agencies = cfg.feed.Agencies
+	} else if cfg.db != nil {
+		var err error
+		if calendars, err = cfg.db.GetCalendars(); err != nil {
+			return nil, &ErrLoadingData{Err: err, Structure: "Calendars"}
+		}
+		if calendarDates, err = cfg.db.GetCalendarDates(); err != nil {
+			return nil, &ErrLoadingData{Err: err, Structure: "CalendarDates"}
+		}
+		if agencies, err = cfg.db.GetAgencies(); err != nil {
+			return nil, &ErrLoadingData{Err: err, Structure: "Agencies"}
+		}
+	} else {
+		return nil, &ErrNoData{}
+	}
+
+	if cfg.loc == nil {
+		timezone := defaultTimezone
+		if len(agencies) > 0 && agencies[0].Timezone != "" {
+			timezone = agencies[0].Timezone
+		}
+		loc, err := time.LoadLocation(timezone)
+		if err != nil {
+			return nil, &ErrTimezone{Err: err, Timezone: timezone}
+		}
+		cfg.loc = loc
+	}
+
+	cfg.index(calendars, calendarDates)
+
+	return cfg, nil
+}
+
+// WithDatabase reads calendars and trips from the database
+func WithDatabase(db store.Reader) Option {
+	return func(c *Schedule) {
+		c.db = db
+	}
+}
+
+// WithFeed reads calendars and trips from an already parsed feed
+func WithFeed(feed *gtfspec.Feed) Option {
+	return func(c *Schedule) {
+		c.feed = feed
+	}
+}
+
+// WithLocation overrides the agency timezone
+func WithLocation(loc *time.Location) Option {
+	return func(c *Schedule) {
+		c.loc = loc
+	}
+}
+
+// WithLogger sets the logger for the schedule instance
+func WithLogger(log *zerolog.Logger) Option {
+	return func(c *Schedule) {
+		c.log = log
+	}
+}
+
+// index builds the calendar and exception lookups
+func (c *Schedule) index(calendars []*gtfspec.Calendar, calendarDates []*gtfspec.CalendarDate) {
+	c.calendars = make(map[string]*gtfspec.Calendar, len(calendars))
+	services := make(map[string]bool)
+	for _, calendar := range calendars {
+		c.calendars[calendar.ServiceId] = calendar
+		services[calendar.ServiceId] = true
+	}
+
+	c.exceptions = make(map[string]map[int]int)
+	for _, calendarDate := range calendarDates {
+		if _, ok := c.exceptions[calendarDate.ServiceId]; !ok {
+			c.exceptions[calendarDate.ServiceId] = make(map[int]int)
+		}
+		c.exceptions[calendarDate.ServiceId][dateKey(calendarDate.Date)] = calendarDate.ExceptionType
+		services[calendarDate.ServiceId] = true
+	}
+
+	c.serviceIds = make([]string, 0, len(services))
+	for serviceId := range services {
+		c.serviceIds = append(c.serviceIds, serviceId)
+	}
+	sort.Strings(c.serviceIds)
+}
+
+// Location returns the timezone schedule times are expressed in
+func (c *Schedule) Location() *time.Location {
+	return c.loc
+}
+
+// IsActive reports whether a service runs on a date. calendar_dates.txt
+// exceptions win over the weekday flags and date range of calendar.txt.
+func (c *Schedule) IsActive(serviceId string, date time.Time) bool {
+	key := dateKey(date)
+
+	if exceptions, ok := c.exceptions[serviceId]; ok {
+		switch exceptions[key] {
+		case gtfspec.ExceptionAdded:
+			return true
+		case gtfspec.ExceptionRemoved:
+			return false
+		}
+	}
+
+	calendar, ok := c.calendars[serviceId]
+	if !ok {
+		return false
+	}
+	if key < dateKey(calendar.StartDate) || key > dateKey(calendar.EndDate) {
+		return false
+	}
+
+	return calendar.RunsOn(date.Weekday())
+}
+
+// ServiceIds returns the service_ids that run on a date
+func (c *Schedule) ServiceIds(date time.Time) []string {
+	active := make([]string, 0)
+	for _, serviceId := range c.serviceIds {
+		if c.IsActive(serviceId, date) {
+			active = append(active, serviceId)
+		}
+	}
+	return active
+}
+
+// Trips returns the trips that run on a date. If routeId is empty, trips of every route are returned.
+func (c *Schedule) Trips(date time.Time, routeId string) ([]*gtfspec.Trip, error) {
+	serviceIds := c.ServiceIds(date)
+	if len(serviceIds) == 0 {
+		return make([]*gtfspec.Trip, 0), nil
+	}
+
+	if c.feed == nil {
+		trips, err := c.db.GetTripsForServices(routeId, serviceIds)
+		if err != nil {
+			return nil, &ErrLoadingData{Err: err, Structure: "Trips"}
+		}
+		return trips, nil
+	}
+
+	active := make(map[string]bool, len(serviceIds))
+	for _, serviceId := range serviceIds {
+		active[serviceId] = true
+	}
+
+	trips := make([]*gtfspec.Trip, 0)
+	for _, trip := range c.feed.Trips {
+		if routeId != "" && trip.RouteId != routeId {
+			continue
+		}
+		if active[trip.ServiceId] {
+			trips = append(trips, trip)
+		}
+	}
+	return trips, nil
+}
+
+// dateKey returns a date as a YYYYMMDD integer, ignoring its time and location
+func dateKey(date time.Time) int {
+	year, month, day := date.Date()
+	return year*10000 + int(month)*100 + day
+}
@@ -0,0 +1,270 @@
+package schedule
+
+import (
+	"sort"
+	"time"
+
+	"github.com/rmrfslashbin/gomarta/pkg/gtfspec"
+)
+
+// TimetableInput is the input for the Timetable method
+type TimetableInput struct {
+	// RouteId is a route_id or route_short_name (ex: "37")
+	RouteId     string
+	Date        time.Time
+	DirectionId int
+}
+
+// Timetable is the scheduled service of a route and direction on a date:
+// trips as columns, stops as rows
+type Timetable struct {
+	Route       *gtfspec.Route
+	Date        time.Time
+	DirectionId int
+
+	// Trips are ordered by their first departure
+	Trips []*gtfspec.Trip
+
+	// Stops are ordered along the direction of travel
+	Stops []*gtfspec.Stop
+
+	// Times holds the departure of Trips[j] from Stops[i] at Times[i][j],
+	// or gtfspec.NoTime if the trip doesn't serve the stop
+	Times [][]gtfspec.GTFSTime
+}
+
+// Departure is a scheduled departure from a stop
+type Departure struct {
+	Trip     *gtfspec.Trip
+	Route    *gtfspec.Route
+	StopTime *gtfspec.StopTime
+
+	// Time is the absolute departure time in the agency timezone
+	Time time.Time
+
+	// ServiceDate is the service date the trip runs on. It is the day before
+	// the requested date for trips that run past midnight.
+	ServiceDate time.Time
+}
+
+// midnight is the GTFS time from which a service day's trips run into the next day
+const midnight gtfspec.GTFSTime = 24 * 60 * 60
+
+// Route looks up a route by route_id, falling back to route_short_name
+func (c *Schedule) Route(routeId string) (*gtfspec.Route, error) {
+	if c.feed != nil {
+		for _, route := range c.feed.Routes {
+			if route.RouteId == routeId {
+				return route, nil
+			}
+		}
+		for _, route := range c.feed.Routes {
+			if route.ShortName == routeId {
+				return route, nil
+			}
+		}
+		return nil, &ErrUnknownRoute{RouteId: routeId}
+	}
+
+	if route, err := c.db.GetRoute(routeId); err == nil {
+		return route, nil
+	}
+	route, err := c.db.GetRouteByShortName(routeId)
+	if err != nil {
+		return nil, &ErrUnknownRoute{Err: err, RouteId: routeId}
+	}
+	return route, nil
+}
+
+// Timetable returns the scheduled timetable of a route and direction on a date
+func (c *Schedule) Timetable(input *TimetableInput) (*Timetable, error) {
+	route, err := c.Route(input.RouteId)
+	if err != nil {
+		return nil, err
+	}
+
+	trips, err := c.Trips(input.Date, route.RouteId)
+	if err != nil {
+		return nil, err
+	}
+
+	timetable := &Timetable{
+		Route:       route,
+		Date:        input.Date,
+		DirectionId: input.DirectionId,
+		Trips:       make([]*gtfspec.Trip, 0),
+		Stops:       make([]*gtfspec.Stop, 0),
+		Times:       make([][]gtfspec.GTFSTime, 0),
+	}
+
+	tripIds := make([]string, 0, len(trips))
+	for _, trip := range trips {
+		if trip.DirectionId == input.DirectionId {
+			tripIds = append(tripIds, trip.TripID)
+			timetable.Trips = append(timetable.Trips, trip)
+		}
+	}
+	if len(tripIds) == 0 {
+		return timetable, nil
+	}
+
+	stopTimes, err := c.StopTimes(tripIds)
+	if err != nil {
+		return nil, err
+	}
+
+	sort.SliceStable(timetable.Trips, func(i, j int) bool {
+		return firstDeparture(stopTimes[timetable.Trips[i].TripID]) < firstDeparture(stopTimes[timetable.Trips[j].TripID])
+	})
+
+	stopIds := stopOrder(timetable.Trips, stopTimes)
+	stops, err := c.Stops(stopIds)
+	if err != nil {
+		return nil, err
+	}
+
+	rows := make(map[string]int, len(stopIds))
+	for ndx, stopId := range stopIds {
+		rows[stopId] = ndx
+		stop, ok := stops[stopId]
+		if !ok {
+			stop = &gtfspec.Stop{StopId: stopId}
+		}
+		timetable.Stops = append(timetable.Stops, stop)
+
+		times := make([]gtfspec.GTFSTime, len(timetable.Trips))
+		for j := range times {
+			times[j] = gtfspec.NoTime
+		}
+		timetable.Times = append(timetable.Times, times)
+	}
+
+	for j, trip := range timetable.Trips {
+		for _, stopTime := range stopTimes[trip.TripID] {
+			// Loop routes visit their first stop again at the end; keep the first visit.
+			if row := rows[stopTime.StopId]; !timetable.Times[row][j].IsSet() {
+				timetable.Times[row][j] = stopTime.DepartureTime
+			}
+		}
+	}
+
+	return timetable, nil
+}
+
+// Departures returns every scheduled departure from a stop on a date, in time
+// order. Trips of the previous service date that leave the stop at 24:00:00
+// or later are included, since they depart in the early hours of date.
+func (c *Schedule) Departures(stopId string, date time.Time) ([]*Departure, error) {
+	stopTimes, err := c.StopTimesAtStop(stopId)
+	if err != nil {
+		return nil, err
+	}
+
+	tripIds := make([]string, 0, len(stopTimes))
+	for _, stopTime := range stopTimes {
+		tripIds = append(tripIds, stopTime.TripId)
+	}
+	trips, err := c.TripsById(tripIds)
+	if err != nil {
+		return nil, err
+	}
+
+	routes := make(map[string]*gtfspec.Route)
+	departures := make([]*Departure, 0)
+
+	previous := date.AddDate(0, 0, -1)
+	for _, stopTime := range stopTimes {
+		trip, ok := trips[stopTime.TripId]
+		if !ok || !stopTime.DepartureTime.IsSet() {
+			continue
+		}
+		// Riders can't board where pickup is not available.
+		if stopTime.PickupType == gtfspec.PickupNone {
+			continue
+		}
+
+		serviceDates := make([]time.Time, 0, 2)
+		if stopTime.DepartureTime >= midnight && c.IsActive(trip.ServiceId, previous) {
+			serviceDates = append(serviceDates, previous)
+		}
+		if c.IsActive(trip.ServiceId, date) {
+			serviceDates = append(serviceDates, date)
+		}
+		if len(serviceDates) == 0 {
+			continue
+		}
+
+		route, ok := routes[trip.RouteId]
+		if !ok {
+			route, err = c.Route(trip.RouteId)
+			if err != nil {
+				return nil, err
+			}
+			routes[trip.RouteId] = route
+		}
+
+		for _, serviceDate := range serviceDates {
+			departures = append(departures, &Departure{
+				Trip:        trip,
+				Route:       route,
+				StopTime:    stopTime,
+				Time:        stopTime.DepartureTime.Time(serviceDate, c.loc),
+				ServiceDate: serviceDate,
+			})
+		}
+	}
+
+	sort.SliceStable(departures, func(i, j int) bool {
+		return departures[i].Time.Before(departures[j].Time)
+	})
+
+	return departures, nil
+}
+
+// firstDeparture returns the earliest departure of a trip's stop times
+func firstDeparture(stopTimes []*gtfspec.StopTime) gtfspec.GTFSTime {
+	for _, stopTime := range stopTimes {
+		if stopTime.DepartureTime.IsSet() {
+			return stopTime.DepartureTime
+		}
+	}
+	return gtfspec.NoTime
+}
+
+// stopOrder merges the stop sequences of several trips into one ordering.
+// The trip with the most stops sets the base order; stops only served by
+// other trips are inserted after the stop that precedes them on that trip.
+func stopOrder(trips []*gtfspec.Trip, stopTimes map[string][]*gtfspec.StopTime) []string {
+	sorted := make([]*gtfspec.Trip, len(trips))
+	copy(sorted, trips)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return len(stopTimes[sorted[i].TripID]) > len(stopTimes[sorted[j].TripID])
+	})
+
+	order := make([]string, 0)
+	seen := make(map[string]bool)
+
+	for _, trip := range sorted {
+		previous := -1
+		for _, stopTime := range stopTimes[trip.TripID] {
+			if seen[stopTime.StopId] {
+				for ndx, stopId := range order {
+					if stopId == stopTime.StopId {
+						previous = ndx
+						break
+					}
+				}
+				continue
+			}
+			seen[stopTime.StopId] = true
+
+			insert := previous + 1
+			order = append(order, "")
+			copy(order[insert+1:], order[insert:])
+			order[insert] = stopTime.StopId
+			previous = insert
+		}
+	}
+
+	return order
+}
@@ -1,5 +1,7 @@
 package specsupdate
 
+import "strconv"
+
 // ErrAddingData is an error type for when data cannot be added to the database.
 type ErrAddingData struct {
 	Err       error
@@ -93,6 +95,7 @@ func (e *ErrNoDatabase) Error() string {
 type ErrParsingFile struct {
 	Err  error
 	File string
+	Line int
 	Msg  string
 }
 
@@ -104,6 +107,9 @@ func (e *ErrParsingFile) Error() string {
 	if e.File != "" {
 		e.Msg += ": " + e.File
 	}
+	if e.Line > 0 {
+		e.Msg += ": line " + strconv.Itoa(e.Line)
+	}
 	if e.Err != nil {
 		e.Msg += ": " + e.Err.Error()
 	}
@@ -0,0 +1,126 @@
+package specsupdate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+)
+
+// Policy controls what the importer does with a record that fails to parse.
+type Policy string
+
+const (
+	// PolicyAbort stops the import at the first bad record.
+	PolicyAbort Policy = "abort"
+
+	// PolicySkip drops every bad record and keeps importing.
+	PolicySkip Policy = "skip"
+
+	// PolicyDefault keeps records whose bad columns are all optional, using the
+	// column's default value. Records with a bad required column are dropped.
+	PolicyDefault Policy = "default"
+)
+
+// Action is what the importer did with a bad record.
+type Action string
+
+const (
+	// ActionSkipped means the record was not imported.
+	ActionSkipped Action = "skipped"
+
+	// ActionDefaulted means the record was imported with defaults for the bad columns.
+	ActionDefaulted Action = "defaulted"
+)
+
+// Issue describes one bad column (or unreadable line) in a feed file.
+type Issue struct {
+	File   string `json:"file"`
+	Line   int    `json:"line"`
+	Column string `json:"column,omitempty"`
+	Value  string `json:"value,omitempty"`
+	Reason string `json:"reason"`
+	Action Action `json:"action"`
+}
+
+// FileSummary counts the records read from a single feed file.
+type FileSummary struct {
+	File      string `json:"file"`
+	Rows      int    `json:"rows"`
+	Loaded    int    `json:"loaded"`
+	Skipped   int    `json:"skipped"`
+	Defaulted int    `json:"defaulted"`
+}
+
+// Report collects the outcome of parsing a feed.
+type Report struct {
+	Policy Policy                  `json:"policy"`
+	Files  map[string]*FileSummary `json:"files"`
+	Issues []*Issue                `json:"issues"`
+}
+
+// newReport creates an empty report for the given policy.
+func newReport(policy Policy) *Report {
+	return &Report{
+		Policy: policy,
+		Files:  make(map[string]*FileSummary),
+		Issues: make([]*Issue, 0),
+	}
+}
+
+// file returns the summary for a file, creating it if needed.
+func (r *Report) file(name string) *FileSummary {
+	if _, ok := r.Files[name]; !ok {
+		r.Files[name] = &FileSummary{File: name}
+	}
+	return r.Files[name]
+}
+
+// HasIssues reports whether any record was skipped or defaulted.
+func (r *Report) HasIssues() bool {
+	return len(r.Issues) > 0
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteText writes a per-file summary followed by one line per issue.
+func (r *Report) WriteText(w io.Writer) error {
+	names := make([]string, 0, len(r.Files))
+	for name := range r.Files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "FILE\tROWS\tLOADED\tSKIPPED\tDEFAULTED\n")
+	for _, name := range names {
+		f := r.Files[name]
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\n", f.File, f.Rows, f.Loaded, f.Skipped, f.Defaulted)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(r.Issues) == 0 {
+		_, err := fmt.Fprintf(w, "\nno issues (policy: %s)\n", r.Policy)
+		return err
+	}
+
+	fmt.Fprintf(w, "\n%d issues (policy: %s)\n", len(r.Issues), r.Policy)
+	for _, issue := range r.Issues {
+		loc := fmt.Sprintf("%s:%d", issue.File, issue.Line)
+		if issue.Column != "" {
+			loc += fmt.Sprintf(": %s: %q", issue.Column, issue.Value)
+		}
+		if _, err := fmt.Fprintf(w, "%s: %s (%s)\n", loc, issue.Reason, issue.Action); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -4,12 +4,14 @@ import (
 	"archive/zip"
 	"bytes"
 	"encoding/csv"
+	"errors"
 	"io"
+	"io/fs"
 	"net/http"
 	"os"
 
-	"github.com/rmrfslashbin/gomarta/pkg/database"
 	"github.com/rmrfslashbin/gomarta/pkg/gtfspec"
+	"github.com/rmrfslashbin/gomarta/pkg/store"
 	"github.com/rs/zerolog"
 )
 
@@ -18,9 +20,12 @@ type Option func(c *SpecsConfig)
 
 // Database for the app instance
 type SpecsConfig struct {
-	log *zerolog.Logger
-	url *string
-	db  *database.Database
+	log       *zerolog.Logger
+	url       *string
+	db        store.Writer
+	policy    Policy
+	namespace string
+	report    *Report
 }
 
 // New creates a new mastoclinet instance
@@ -39,19 +44,15 @@ func New(opts ...Option) (*SpecsConfig, error) {
 		cfg.log = &log
 	}
 
-	if cfg.url == nil {
-		return nil, &ErrNoURL{}
-	}
-
-	if cfg.db == nil {
-		return nil, &ErrNoDatabase{}
+	if cfg.policy == "" {
+		cfg.policy = PolicyAbort
 	}
 
 	return cfg, nil
 }
 
 // WithDatabase sets the database for the bus instance
-func WithDatabase(db *database.Database) Option {
+func WithDatabase(db store.Writer) Option {
 	return func(c *SpecsConfig) {
 		c.db = db
 	}
@@ -64,6 +65,21 @@ func WithLogger(log *zerolog.Logger) Option {
 	}
 }
 
+// WithPolicy sets how records that fail to parse are handled
+func WithPolicy(policy Policy) Option {
+	return func(c *SpecsConfig) {
+		c.policy = policy
+	}
+}
+
+// WithNamespace qualifies every id of the feed with a namespace, e.g. "cobblinc",
+// so several agencies' feeds can be stored side by side
+func WithNamespace(namespace string) Option {
+	return func(c *SpecsConfig) {
+		c.namespace = namespace
+	}
+}
+
 // WithUrl sets the URL for the bus instance
 func WithUrl(url string) Option {
 	return func(c *SpecsConfig) {
@@ -71,198 +87,267 @@ func WithUrl(url string) Option {
 	}
 }
 
+// Update fetches the feed zip from the configured URL, parses it and adds it to the database.
 func (c *SpecsConfig) Update() error {
+	if c.db == nil {
+		return &ErrNoDatabase{}
+	}
+
+	zipReader, err := c.Fetch()
+	if err != nil {
+		return err
+	}
+
+	feed, err := c.Parse(zipReader)
+	if err != nil {
+		return err
+	}
+
+	return c.store(feed)
+}
+
+// Fetch downloads the feed zip from the configured URL.
+func (c *SpecsConfig) Fetch() (*zip.Reader, error) {
+	if c.url == nil {
+		return nil, &ErrNoURL{}
+	}
+
 	resp, err := http.Get(*c.url)
 	if err != nil {
-		return &ErrFetchingURL{Err: err}
+		return nil, &ErrFetchingURL{Err: err}
 	}
 	defer resp.Body.Close()
 
 	body, err := io.ReadAll(resp.Body)
 	if err != nil {
-		return &ErrReadingUrlBody{Err: err}
+		return nil, &ErrReadingUrlBody{Err: err}
 	}
 	zipReader, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
 	if err != nil {
-		return &ErrZipReader{Err: err}
+		return nil, &ErrZipReader{Err: err}
 	}
 
-	agencies := make([]*gtfspec.Agency, 0)
-	stops := make([]*gtfspec.Stop, 0)
-	routes := make([]*gtfspec.Route, 0)
-	trips := make([]*gtfspec.Trip, 0)
-	shapes := make([]*gtfspec.Shape, 0)
-	stopTimes := make([]*gtfspec.StopTime, 0)
-	calendars := make([]*gtfspec.Calendar, 0)
-	calendarDates := make([]*gtfspec.CalendarDate, 0)
-
-	for _, file := range zipReader.File {
-		zipData, err := readZipFile(file)
-		if err != nil {
-			return &ErrZipFileReader{Err: err}
+	return zipReader, nil
+}
+
+// Parse reads every supported file in a GTFS zip into a Feed.
+// Bad records are handled according to the configured Policy and recorded in Report().
+func (c *SpecsConfig) Parse(zipReader *zip.Reader) (*gtfspec.Feed, error) {
+	return c.ParseFS(zipReader)
+}
+
+// ParseFS reads every supported file at the root of a file system, such as
+// an unpacked feed directory opened with os.DirFS, into a Feed.
+func (c *SpecsConfig) ParseFS(fsys fs.FS) (*gtfspec.Feed, error) {
+	c.report = newReport(c.policy)
+	feed := &gtfspec.Feed{}
+
+	entries, err := fs.ReadDir(fsys, ".")
+	if err != nil {
+		return nil, &ErrZipFileReader{Err: err}
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() {
+			continue
 		}
-		csvData, err := csv.NewReader(bytes.NewReader(zipData)).ReadAll()
-		if err != nil {
-			return &ErrCSVReader{Err: err}
+		name := entry.Name()
+		var err error
+
+		switch name {
+		case "agency.txt":
+			feed.Agencies, err = parseFile[gtfspec.Agency](c, fsys, name)
+		case "areas.txt":
+			feed.Areas, err = parseFile[gtfspec.Area](c, fsys, name)
+		case "calendar.txt":
+			feed.Calendars, err = parseFile[gtfspec.Calendar](c, fsys, name)
+		case "calendar_dates.txt":
+			feed.CalendarDates, err = parseFile[gtfspec.CalendarDate](c, fsys, name)
+		case "fare_attributes.txt":
+			feed.FareAttributes, err = parseFile[gtfspec.FareAttribute](c, fsys, name)
+		case "fare_leg_rules.txt":
+			feed.FareLegRules, err = parseFile[gtfspec.FareLegRule](c, fsys, name)
+		case "fare_media.txt":
+			feed.FareMedia, err = parseFile[gtfspec.FareMedia](c, fsys, name)
+		case "fare_products.txt":
+			feed.FareProducts, err = parseFile[gtfspec.FareProduct](c, fsys, name)
+		case "fare_rules.txt":
+			feed.FareRules, err = parseFile[gtfspec.FareRule](c, fsys, name)
+		case "fare_transfer_rules.txt":
+			feed.FareTransferRules, err = parseFile[gtfspec.FareTransferRule](c, fsys, name)
+		case "feed_info.txt":
+			feed.FeedInfo, err = parseFile[gtfspec.FeedInfo](c, fsys, name)
+		case "frequencies.txt":
+			feed.Frequencies, err = parseFile[gtfspec.Frequency](c, fsys, name)
+		case "levels.txt":
+			feed.Levels, err = parseFile[gtfspec.Level](c, fsys, name)
+		case "networks.txt":
+			feed.Networks, err = parseFile[gtfspec.Network](c, fsys, name)
+		case "pathways.txt":
+			feed.Pathways, err = parseFile[gtfspec.Pathway](c, fsys, name)
+		case "route_networks.txt":
+			feed.RouteNetworks, err = parseFile[gtfspec.RouteNetwork](c, fsys, name)
+		case "routes.txt":
+			feed.Routes, err = parseFile[gtfspec.Route](c, fsys, name)
+		case "shapes.txt":
+			feed.Shapes, err = parseFile[gtfspec.Shape](c, fsys, name)
+		case "stop_areas.txt":
+			feed.StopAreas, err = parseFile[gtfspec.StopArea](c, fsys, name)
+		case "stop_times.txt":
+			feed.StopTimes, err = parseFile[gtfspec.StopTime](c, fsys, name)
+		case "stops.txt":
+			feed.Stops, err = parseFile[gtfspec.Stop](c, fsys, name)
+		case "transfers.txt":
+			feed.Transfers, err = parseFile[gtfspec.Transfer](c, fsys, name)
+		case "trips.txt":
+			feed.Trips, err = parseFile[gtfspec.Trip](c, fsys, name)
+		default:
+			c.log.Debug().Str("file", name).Msg("ignoring unsupported file")
 		}
 
-		headers := makeHeaders(csvData[0])
+		if err != nil {
+			return nil, err
+		}
+	}
 
-		switch file.Name {
-		case "agency.txt":
-			c.log.Info().Msg("parsing agency.txt")
+	feed.Namespace(c.namespace)
 
-			for _, row := range csvData[1:] {
-				agency := &gtfspec.Agency{}
+	return feed, nil
+}
 
-				if err := agency.Add(headers, row); err != nil {
-					return &ErrParsingFile{Err: err, File: file.Name}
-				} else {
-					agencies = append(agencies, agency)
-				}
+// Report returns the report of the most recent Parse or Update.
+func (c *SpecsConfig) Report() *Report {
+	return c.report
+}
 
-			}
+// store adds the contents of a parsed feed to the database.
+func (c *SpecsConfig) store(feed *gtfspec.Feed) error {
+	c.log.Info().
+		Int("routes", len(feed.Routes)).
+		Int("trips", len(feed.Trips)).
+		Int("stops", len(feed.Stops)).
+		Int("stopTimes", len(feed.StopTimes)).
+		Msg("adding feed to database")
+	if err := c.db.SaveFeed(feed); err != nil {
+		return &ErrAddingData{Err: err}
+	}
 
-		case "calendar.txt":
-			c.log.Info().Msg("parsing calendar.txt")
+	return nil
+}
 
-			for _, row := range csvData[1:] {
-				calendar := &gtfspec.Calendar{}
+// model is implemented by every gtfspec type that is read from a feed file.
+type model[T any] interface {
+	*T
+	Add(headers map[string]int, record []string) error
+}
 
-				if err := calendar.Add(headers, row); err != nil {
-					return &ErrParsingFile{Err: err, File: file.Name}
-				} else {
-					calendars = append(calendars, calendar)
-				}
-			}
+// parseFile reads every record of a feed file into a slice of T.
+func parseFile[T any, PT model[T]](c *SpecsConfig, fsys fs.FS, name string) ([]*T, error) {
+	c.log.Info().Msgf("parsing %s", name)
 
-		case "calendar_dates.txt":
-			c.log.Info().Msg("parsing calendar_dates.txt")
+	data, err := fs.ReadFile(fsys, name)
+	if err != nil {
+		return nil, &ErrZipFileReader{Err: err}
+	}
 
-			for _, row := range csvData[1:] {
-				calendarDate := &gtfspec.CalendarDate{}
+	reader := csv.NewReader(bytes.NewReader(data))
+	// Rows are mapped by header name, so don't insist on a fixed column count.
+	reader.FieldsPerRecord = -1
 
-				if err := calendarDate.Add(headers, row); err != nil {
-					return &ErrParsingFile{Err: err, File: file.Name}
-				}
-				calendarDates = append(calendarDates, calendarDate)
-			}
+	headerRow, err := reader.Read()
+	if err == io.EOF {
+		c.log.Warn().Str("file", name).Msg("skipping empty file")
+		return nil, nil
+	}
+	if err != nil {
+		return nil, &ErrCSVReader{Err: err}
+	}
+	headers := gtfspec.ParseHeaders(headerRow)
 
-		case "routes.txt":
-			c.log.Info().Msg("parsing routes.txt")
-
-			for _, row := range csvData[1:] {
-				route := &gtfspec.Route{}
-				if err := route.Add(headers, row); err != nil {
-					return &ErrParsingFile{Err: err, File: file.Name}
-				} else {
-					routes = append(routes, route)
-				}
-			}
+	summary := c.report.file(name)
+	items := make([]*T, 0)
 
-		case "shapes.txt":
-			c.log.Info().Msg("parsing shapes.txt")
-
-			for _, row := range csvData[1:] {
-				shape := &gtfspec.Shape{}
-				if err := shape.Add(headers, row); err != nil {
-					return &ErrParsingFile{Err: err, File: file.Name}
-				} else {
-					//specs.Shapes = append(specs.Shapes, shape)
-					shapes = append(shapes, shape)
-				}
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if c.policy == PolicyAbort || !errors.As(err, &parseErr) {
+				return nil, &ErrCSVReader{Err: err}
 			}
+			summary.Rows++
+			summary.Skipped++
+			c.report.Issues = append(c.report.Issues, &Issue{
+				File:   name,
+				Line:   parseErr.Line,
+				Reason: parseErr.Err.Error(),
+				Action: ActionSkipped,
+			})
+			continue
+		}
 
-		case "stop_times.txt":
-			c.log.Info().Msg("parsing stop_times.txt")
-
-			for _, row := range csvData[1:] {
-				stopTime := &gtfspec.StopTime{}
-				if err := stopTime.Add(headers, row); err != nil {
-					return &ErrParsingFile{Err: err, File: file.Name}
-				} else {
-					stopTimes = append(stopTimes, stopTime)
-				}
-			}
+		summary.Rows++
+		line, _ := reader.FieldPos(0)
 
-		case "stops.txt":
-			c.log.Info().Msg("parsing stops.txt")
-
-			for _, row := range csvData[1:] {
-				stop := &gtfspec.Stop{}
-				if err := stop.Add(headers, row); err != nil {
-					return &ErrParsingFile{Err: err, File: file.Name}
-				} else {
-					stops = append(stops, stop)
-				}
+		item := PT(new(T))
+		if err := item.Add(headers, record); err != nil {
+			if c.policy == PolicyAbort {
+				return nil, &ErrParsingFile{Err: err, File: name, Line: line}
 			}
-
-		case "trips.txt":
-			c.log.Info().Msg("parsing trips.txt")
-
-			for _, row := range csvData[1:] {
-				trip := &gtfspec.Trip{}
-				if err := trip.Add(headers, row); err != nil {
-					return &ErrParsingFile{Err: err, File: file.Name}
-				} else {
-					trips = append(trips, trip)
-				}
+			if c.recordIssues(name, line, err) == ActionSkipped {
+				summary.Skipped++
+				continue
 			}
+			summary.Defaulted++
 		}
-	}
 
-	c.log.Info().Msg("adding Agencies to database")
-	if _, err := c.db.Create(agencies); err != nil {
-		return &ErrAddingData{Err: err, Structure: "Agencies"}
-	}
-	c.log.Info().Msg("adding Calendars to database")
-	if _, err := c.db.Create(calendars); err != nil {
-		return &ErrAddingData{Err: err, Structure: "Calendars"}
-	}
-	c.log.Info().Msg("adding CalendarDates to database")
-	if _, err := c.db.Create(calendarDates); err != nil {
-		return &ErrAddingData{Err: err, Structure: "CalendarDates"}
-	}
-	c.log.Info().Msg("adding Routes to database")
-	if _, err := c.db.Create(routes); err != nil {
-		return &ErrAddingData{Err: err, Structure: "Routes"}
-	}
-	c.log.Info().Msg("adding Shapes to database")
-	if _, err := c.db.Create(shapes); err != nil {
-		return &ErrAddingData{Err: err, Structure: "Shapes"}
-	}
-	c.log.Info().Msg("adding Stops to database")
-	if _, err := c.db.Create(stops); err != nil {
-		return &ErrAddingData{Err: err, Structure: "Stops"}
-	}
-	c.log.Info().Msg("adding StopTimes to database")
-	if _, err := c.db.Create(stopTimes); err != nil {
-		return &ErrAddingData{Err: err, Structure: "StopTimes"}
-	}
-	c.log.Info().Msg("adding Trips to database")
-	if _, err := c.db.Create(trips); err != nil {
-		return &ErrAddingData{Err: err, Structure: "Trips"}
+		summary.Loaded++
+		items = append(items, item)
 	}
 
-	return nil
+	return items, nil
 }
 
-// readZipFile reads the contents of a zip file
-func readZipFile(zf *zip.File) ([]byte, error) {
-	f, err := zf.Open()
-	if err != nil {
-		return nil, err
+// recordIssues adds the failures of a bad record to the report and returns
+// what should be done with the record under the configured policy.
+func (c *SpecsConfig) recordIssues(file string, line int, err error) Action {
+	var recordErr *gtfspec.ErrRecord
+	if !errors.As(err, &recordErr) {
+		c.report.Issues = append(c.report.Issues, &Issue{
+			File:   file,
+			Line:   line,
+			Reason: err.Error(),
+			Action: ActionSkipped,
+		})
+		return ActionSkipped
 	}
-	defer f.Close()
-	return io.ReadAll(f)
-}
 
-func makeHeaders(headerRow []string) map[string]int {
-	headers := make(map[string]int)
+	action := ActionDefaulted
+	if c.policy == PolicySkip {
+		action = ActionSkipped
+	}
+	for _, field := range recordErr.Fields {
+		if field.Required {
+			action = ActionSkipped
+		}
+	}
 
-	for i, header := range headerRow {
-		headers[header] = i
+	for _, field := range recordErr.Fields {
+		reason := field.Msg
+		if field.Err != nil {
+			reason = field.Err.Error()
+		}
+		c.report.Issues = append(c.report.Issues, &Issue{
+			File:   file,
+			Line:   line,
+			Column: field.Column,
+			Value:  field.Value,
+			Reason: reason,
+			Action: action,
+		})
 	}
 
-	return headers
+	return action
 }
@@ -0,0 +1,116 @@
+package station
+
+// ErrLoadingData is returned when station tables cannot be read from the database.
+type ErrLoadingData struct {
+	Err       error
+	Structure string
+	Msg       string
+}
+
+// Error returns the error message.
+func (e *ErrLoadingData) Error() string {
+	if e.Msg == "" {
+		e.Msg = "error loading station data"
+	}
+	if e.Structure != "" {
+		e.Msg += ": " + e.Structure
+	}
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
+
+// ErrNoData is returned when neither a database nor a feed is provided.
+type ErrNoData struct {
+	Err error
+	Msg string
+}
+
+// Error returns the error message.
+func (e *ErrNoData) Error() string {
+	if e.Msg == "" {
+		e.Msg = "no station data provided- use WithDatabase() or WithFeed()"
+	}
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
+
+// ErrNoPath is returned when no pathway connects two stops.
+type ErrNoPath struct {
+	Err  error
+	From string
+	To   string
+	Msg  string
+}
+
+// Error returns the error message.
+func (e *ErrNoPath) Error() string {
+	if e.Msg == "" {
+		e.Msg = "no pathway between stops"
+	}
+	e.Msg += ": " + e.From + " -> " + e.To
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
+
+// ErrUnknownStop is returned when a stop id is not in the feed.
+type ErrUnknownStop struct {
+	Err    error
+	StopId string
+	Msg    string
+}
+
+// Error returns the error message.
+func (e *ErrUnknownStop) Error() string {
+	if e.Msg == "" {
+		e.Msg = "unknown stop"
+	}
+	e.Msg += ": " + e.StopId
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
+
+// ErrNotAStation is returned when a stop does not belong to a station.
+type ErrNotAStation struct {
+	Err    error
+	StopId string
+	Msg    string
+}
+
+// Error returns the error message.
+func (e *ErrNotAStation) Error() string {
+	if e.Msg == "" {
+		e.Msg = "stop is not part of a station"
+	}
+	e.Msg += ": " + e.StopId
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
+
+// ErrParentCycle is returned when the parent_station chain of a stop loops back on itself.
+type ErrParentCycle struct {
+	Err    error
+	StopId string
+	Msg    string
+}
+
+// Error returns the error message.
+func (e *ErrParentCycle) Error() string {
+	if e.Msg == "" {
+		e.Msg = "parent_station cycle"
+	}
+	e.Msg += ": " + e.StopId
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
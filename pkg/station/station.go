@@ -0,0 +1,354 @@
+package station
+
+import (
+	"container/heap"
+	"math"
+	"os"
+	"sort"
+
+	"github.com/rmrfslashbin/gomarta/pkg/gtfspec"
+	"github.com/rmrfslashbin/gomarta/pkg/store"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// walkingSpeed is the assumed walking speed in meters per second
+	walkingSpeed = 1.3
+
+	// escalatorSpeed is the assumed speed of a rider standing on an escalator in meters per second
+	escalatorSpeed = 0.5
+
+	// secondsPerStair is the assumed time to climb or descend one stair
+	secondsPerStair = 2
+
+	// elevatorSeconds is the assumed wait and ride time of an elevator
+	elevatorSeconds = 60
+
+	// gateSeconds is the assumed time to pass a fare or exit gate
+	gateSeconds = 10
+
+	// defaultSeconds is used for pathways without a length or traversal time
+	defaultSeconds = 30
+)
+
+// Options for the navigator instance
+type Option func(c *Navigator)
+
+// Navigator answers questions about the parent_station hierarchy and the
+// pathways inside stations
+type Navigator struct {
+	log  *zerolog.Logger
+	db   store.Reader
+	feed *gtfspec.Feed
+
+	stops    map[string]*gtfspec.Stop
+	children map[string][]*gtfspec.Stop
+	levels   map[string]*gtfspec.Level
+	edges    map[string][]*edge
+}
+
+// edge is a pathway that can be walked in one direction
+type edge struct {
+	pathway  *gtfspec.Pathway
+	to       string
+	reversed bool
+}
+
+// New creates a new navigator instance
+func New(opts ...Option) (*Navigator, error) {
+	cfg := &Navigator{}
+
+	// apply the list of options to Navigator
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	// set up logger if not provided
+	if cfg.log == nil {
+		log := zerolog.New(os.Stderr).With().Timestamp().Logger()
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+		cfg.log = &log
+	}
+
+	if cfg.feed == nil {
+		if cfg.db == nil {
+			return nil, &ErrNoData{}
+		}
+		feed, err := loadFeed(cfg.db)
+		if err != nil {
+			return nil, err
+		}
+		cfg.feed = feed
+	}
+
+	cfg.index()
+
+	return cfg, nil
+}
+
+// WithDatabase loads stops, levels and pathways from the database
+func WithDatabase(db store.Reader) Option {
+	return func(c *Navigator) {
+		c.db = db
+	}
+}
+
+// WithFeed uses the stops, levels and pathways of an already parsed feed
+func WithFeed(feed *gtfspec.Feed) Option {
+	return func(c *Navigator) {
+		c.feed = feed
+	}
+}
+
+// WithLogger sets the logger for the navigator instance
+func WithLogger(log *zerolog.Logger) Option {
+	return func(c *Navigator) {
+		c.log = log
+	}
+}
+
+// loadFeed reads the tables the navigator needs from the database
+func loadFeed(db store.Reader) (*gtfspec.Feed, error) {
+	feed := &gtfspec.Feed{}
+
+	var err error
+	if feed.Levels, err = db.GetLevels(); err != nil {
+		return nil, &ErrLoadingData{Err: err, Structure: "Levels"}
+	}
+	if feed.Pathways, err = db.GetAllPathways(); err != nil {
+		return nil, &ErrLoadingData{Err: err, Structure: "Pathways"}
+	}
+	if feed.Stops, err = db.GetAllStops(); err != nil {
+		return nil, &ErrLoadingData{Err: err, Structure: "Stops"}
+	}
+
+	return feed, nil
+}
+
+// index builds the stop hierarchy and the pathway graph
+func (c *Navigator) index() {
+	c.stops = make(map[string]*gtfspec.Stop, len(c.feed.Stops))
+	c.children = make(map[string][]*gtfspec.Stop)
+	for _, stop := range c.feed.Stops {
+		c.stops[stop.StopId] = stop
+		if stop.ParentStation != "" {
+			c.children[stop.ParentStation] = append(c.children[stop.ParentStation], stop)
+		}
+	}
+
+	c.levels = make(map[string]*gtfspec.Level, len(c.feed.Levels))
+	for _, level := range c.feed.Levels {
+		c.levels[level.LevelId] = level
+	}
+
+	c.edges = make(map[string][]*edge)
+	for _, pathway := range c.feed.Pathways {
+		c.edges[pathway.FromStopId] = append(c.edges[pathway.FromStopId], &edge{pathway: pathway, to: pathway.ToStopId})
+		if pathway.IsBidirectional {
+			c.edges[pathway.ToStopId] = append(c.edges[pathway.ToStopId], &edge{pathway: pathway, to: pathway.FromStopId, reversed: true})
+		}
+	}
+}
+
+// Parent returns the parent station of a stop, or nil if it has none
+func (c *Navigator) Parent(stopId string) *gtfspec.Stop {
+	stop, ok := c.stops[stopId]
+	if !ok || stop.ParentStation == "" {
+		return nil
+	}
+	return c.stops[stop.ParentStation]
+}
+
+// Station returns the station a stop belongs to, walking up the parent_station
+// hierarchy from platforms, entrances, nodes and boarding areas. Stops that
+// don't belong to a station return ErrNotAStation.
+func (c *Navigator) Station(stopId string) (*Station, error) {
+	stop, ok := c.stops[stopId]
+	if !ok {
+		return nil, &ErrUnknownStop{StopId: stopId}
+	}
+
+	// Boarding areas belong to a platform, which belongs to the station.
+	visited := map[string]bool{stop.StopId: true}
+	for !stop.IsStation() && stop.ParentStation != "" {
+		if visited[stop.ParentStation] {
+			return nil, &ErrParentCycle{StopId: stop.ParentStation}
+		}
+		visited[stop.ParentStation] = true
+		parent, ok := c.stops[stop.ParentStation]
+		if !ok {
+			return nil, &ErrUnknownStop{StopId: stop.ParentStation}
+		}
+		stop = parent
+	}
+	if !stop.IsStation() {
+		return nil, &ErrNotAStation{StopId: stopId}
+	}
+
+	station := &Station{Stop: stop}
+	levels := make(map[string]*gtfspec.Level)
+
+	// A malformed feed can loop back to the station, so each stop is walked once.
+	walked := map[string]bool{stop.StopId: true}
+	var walk func(parent string)
+	walk = func(parent string) {
+		for _, child := range c.children[parent] {
+			if walked[child.StopId] {
+				continue
+			}
+			walked[child.StopId] = true
+			switch child.LocationType {
+			case gtfspec.LocationStop:
+				station.Platforms = append(station.Platforms, child)
+			case gtfspec.LocationEntrance:
+				station.Entrances = append(station.Entrances, child)
+			case gtfspec.LocationGenericNode:
+				station.Nodes = append(station.Nodes, child)
+			case gtfspec.LocationBoardingArea:
+				station.BoardingAreas = append(station.BoardingAreas, child)
+			}
+			if level, ok := c.levels[child.LevelId]; ok {
+				levels[level.LevelId] = level
+			}
+			walk(child.StopId)
+		}
+	}
+	walk(stop.StopId)
+
+	for _, level := range levels {
+		station.Levels = append(station.Levels, level)
+	}
+	sort.Slice(station.Levels, func(i, j int) bool {
+		return station.Levels[i].Index < station.Levels[j].Index
+	})
+
+	return station, nil
+}
+
+// Path returns the quickest walk between two stops of a station using pathways.txt
+func (c *Navigator) Path(input *PathInput) (*Path, error) {
+	if _, ok := c.stops[input.From]; !ok {
+		return nil, &ErrUnknownStop{StopId: input.From}
+	}
+	if _, ok := c.stops[input.To]; !ok {
+		return nil, &ErrUnknownStop{StopId: input.To}
+	}
+
+	// Dijkstra over the pathway graph, weighted by traversal time.
+	dist := map[string]int{input.From: 0}
+	prev := make(map[string]*edge)
+	from := make(map[string]string)
+	queue := &stopQueue{{stopId: input.From}}
+
+	for queue.Len() > 0 {
+		current := heap.Pop(queue).(*queued)
+		if current.seconds > dist[current.stopId] {
+			continue
+		}
+		if current.stopId == input.To {
+			break
+		}
+		for _, e := range c.edges[current.stopId] {
+			if input.Accessible && !accessible(e.pathway) {
+				continue
+			}
+			seconds, _ := traversalSeconds(e.pathway)
+			next := current.seconds + seconds
+			if d, ok := dist[e.to]; ok && d <= next {
+				continue
+			}
+			dist[e.to] = next
+			prev[e.to] = e
+			from[e.to] = current.stopId
+			heap.Push(queue, &queued{stopId: e.to, seconds: next})
+		}
+	}
+
+	if _, ok := dist[input.To]; !ok || input.From == input.To {
+		return nil, &ErrNoPath{From: input.From, To: input.To}
+	}
+
+	path := &Path{Seconds: dist[input.To]}
+	for stopId := input.To; stopId != input.From; stopId = from[stopId] {
+		path.Segments = append(path.Segments, c.segment(from[stopId], prev[stopId]))
+	}
+	for i, j := 0, len(path.Segments)-1; i < j; i, j = i+1, j-1 {
+		path.Segments[i], path.Segments[j] = path.Segments[j], path.Segments[i]
+	}
+
+	return path, nil
+}
+
+// segment describes walking an edge from a stop
+func (c *Navigator) segment(fromStopId string, e *edge) *Segment {
+	seconds, estimated := traversalSeconds(e.pathway)
+	segment := &Segment{
+		Pathway:   e.pathway,
+		From:      c.stops[fromStopId],
+		To:        c.stops[e.to],
+		Mode:      e.pathway.ModeName(),
+		Reversed:  e.reversed,
+		Signpost:  e.pathway.SignpostedAs,
+		Seconds:   seconds,
+		Estimated: estimated,
+	}
+	if e.reversed {
+		segment.Signpost = e.pathway.ReversedSignpostedAs
+	}
+	if segment.From != nil {
+		segment.FromLevel = c.levels[segment.From.LevelId]
+	}
+	if segment.To != nil {
+		segment.ToLevel = c.levels[segment.To.LevelId]
+	}
+	return segment
+}
+
+// accessible reports whether a wheelchair user can traverse a pathway
+func accessible(pathway *gtfspec.Pathway) bool {
+	return pathway.Mode != gtfspec.PathwayStairs && pathway.Mode != gtfspec.PathwayEscalator && pathway.StairCount == 0
+}
+
+// traversalSeconds returns the pathway's traversal_time, or an estimate from
+// its mode, length and stair count when the feed doesn't provide one
+func traversalSeconds(pathway *gtfspec.Pathway) (int, bool) {
+	if pathway.TraversalTime > 0 {
+		return pathway.TraversalTime, false
+	}
+
+	switch {
+	case pathway.Mode == gtfspec.PathwayElevator:
+		return elevatorSeconds, true
+	case pathway.Mode == gtfspec.PathwayFareGate || pathway.Mode == gtfspec.PathwayExitGate:
+		return gateSeconds, true
+	case pathway.Mode == gtfspec.PathwayStairs && pathway.StairCount > 0:
+		return pathway.StairCount * secondsPerStair, true
+	case pathway.Mode == gtfspec.PathwayEscalator && pathway.Length > 0:
+		return int(math.Ceil(pathway.Length / escalatorSpeed)), true
+	case pathway.Length > 0:
+		return int(math.Ceil(pathway.Length / walkingSpeed)), true
+	}
+
+	return defaultSeconds, true
+}
+
+// queued is a stop waiting in the Dijkstra queue
+type queued struct {
+	stopId  string
+	seconds int
+}
+
+// stopQueue is a min-heap of stops ordered by travel time
+type stopQueue []*queued
+
+func (q stopQueue) Len() int            { return len(q) }
+func (q stopQueue) Less(i, j int) bool  { return q[i].seconds < q[j].seconds }
+func (q stopQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *stopQueue) Push(x interface{}) { *q = append(*q, x.(*queued)) }
+func (q *stopQueue) Pop() interface{} {
+	old := *q
+	n := len(old)
+	item := old[n-1]
+	*q = old[:n-1]
+	return item
+}
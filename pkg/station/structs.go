@@ -0,0 +1,56 @@
+package station
+
+import "github.com/rmrfslashbin/gomarta/pkg/gtfspec"
+
+// Station is a parent station and the stops that belong to it
+type Station struct {
+	Stop          *gtfspec.Stop
+	Platforms     []*gtfspec.Stop
+	Entrances     []*gtfspec.Stop
+	Nodes         []*gtfspec.Stop
+	BoardingAreas []*gtfspec.Stop
+
+	// Levels are the levels used by the station's stops, lowest first
+	Levels []*gtfspec.Level
+}
+
+// PathInput is the input for the Path method
+type PathInput struct {
+	// From and To are stop ids, typically an entrance and a platform
+	From string
+	To   string
+
+	// Accessible avoids stairs and escalators
+	Accessible bool
+}
+
+// Segment is one pathway traversed along a path
+type Segment struct {
+	Pathway *gtfspec.Pathway
+	From    *gtfspec.Stop
+	To      *gtfspec.Stop
+
+	// Mode is the pathway mode name (walkway, stairs, escalator, elevator, ...)
+	Mode string
+
+	// Reversed is true when a bidirectional pathway is walked from to_stop_id to from_stop_id
+	Reversed bool
+
+	// Signpost is the signage a rider follows for this segment, if any
+	Signpost string
+
+	FromLevel *gtfspec.Level
+	ToLevel   *gtfspec.Level
+
+	// Seconds is the traversal time, from the feed or estimated from the pathway length
+	Seconds int
+
+	// Estimated is true when the feed did not provide a traversal_time
+	Estimated bool
+}
+
+// Path is a walk through a station
+type Path struct {
+	Segments []*Segment
+	Seconds  int
+}
@@ -0,0 +1,68 @@
+// Package store defines how the rest of gomarta reads and writes GTFS data,
+// so packages can work with any storage. database.Database is the gorm
+// backed implementation.
+package store
+
+import "github.com/rmrfslashbin/gomarta/pkg/gtfspec"
+
+// Reader looks up stored GTFS records
+type Reader interface {
+	// LoadFeed reads every GTFS table into a Feed
+	LoadFeed() (*gtfspec.Feed, error)
+
+	// Bulk loads of whole tables
+	GetAgencies() ([]*gtfspec.Agency, error)
+	GetCalendars() ([]*gtfspec.Calendar, error)
+	GetCalendarDates() ([]*gtfspec.CalendarDate, error)
+	GetFareAttributes() ([]*gtfspec.FareAttribute, error)
+	GetFareLegRules() ([]*gtfspec.FareLegRule, error)
+	GetFareProducts() ([]*gtfspec.FareProduct, error)
+	GetFareRules() ([]*gtfspec.FareRule, error)
+	GetFareTransferRules() ([]*gtfspec.FareTransferRule, error)
+	GetLevels() ([]*gtfspec.Level, error)
+	GetAllPathways() ([]*gtfspec.Pathway, error)
+	GetRouteNetworks() ([]*gtfspec.RouteNetwork, error)
+	GetRoutes() ([]*gtfspec.Route, error)
+	GetStopAreas() ([]*gtfspec.StopArea, error)
+	GetAllStops() ([]*gtfspec.Stop, error)
+	GetTransfers() ([]*gtfspec.Transfer, error)
+	GetWalkingTransfers() ([]*gtfspec.WalkingTransfer, error)
+
+	// Lookups
+	GetAgency(agencyId string) (*gtfspec.Agency, error)
+	GetRoute(routeId string) (*gtfspec.Route, error)
+	GetRouteByShortName(shortName string) (*gtfspec.Route, error)
+	GetStop(stopId string) (*gtfspec.Stop, error)
+	GetStops(stopIds []string) ([]*gtfspec.Stop, error)
+	GetTrip(tripId string, routeId string) (*gtfspec.Trip, error)
+	GetTrips(tripIds []string) ([]*gtfspec.Trip, error)
+
+	// GetTripsForServices returns the trips of a route that belong to any of the given services.
+	// If routeId is empty, trips of every route are returned.
+	GetTripsForServices(routeId string, serviceIds []string) ([]*gtfspec.Trip, error)
+
+	// GetFrequencies returns the headway periods of a frequency-based trip, ordered by start time
+	GetFrequencies(tripId string) ([]*gtfspec.Frequency, error)
+
+	// GetStopTimesAtStop returns the stop times at a stop that depart between from and to (inclusive),
+	// ordered by departure time
+	GetStopTimesAtStop(stopId string, from gtfspec.GTFSTime, to gtfspec.GTFSTime) ([]*gtfspec.StopTime, error)
+
+	// GetStopTimesForTrips returns the stop times of several trips ordered by trip and stop sequence
+	GetStopTimesForTrips(tripIds []string) ([]*gtfspec.StopTime, error)
+}
+
+// Writer stores GTFS records
+type Writer interface {
+	// SaveFeed inserts every record of a feed, replacing any row with the same primary key
+	SaveFeed(feed *gtfspec.Feed) error
+
+	// ReplaceWalkingTransfers replaces every generated walking transfer
+	ReplaceWalkingTransfers(transfers []*gtfspec.WalkingTransfer) error
+}
+
+// Store reads and writes GTFS records
+type Store interface {
+	Reader
+	Writer
+}
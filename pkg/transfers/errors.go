@@ -0,0 +1,73 @@
+package transfers
+
+// ErrLoadingData is returned when stops or transfers cannot be read from the database.
+type ErrLoadingData struct {
+	Err       error
+	Structure string
+	Msg       string
+}
+
+// Error returns the error message.
+func (e *ErrLoadingData) Error() string {
+	if e.Msg == "" {
+		e.Msg = "error loading transfer data"
+	}
+	if e.Structure != "" {
+		e.Msg += ": " + e.Structure
+	}
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
+
+// ErrNoData is returned when neither a database nor a feed is provided.
+type ErrNoData struct {
+	Err error
+	Msg string
+}
+
+// Error returns the error message.
+func (e *ErrNoData) Error() string {
+	if e.Msg == "" {
+		e.Msg = "no stop data provided- use WithDatabase() or WithFeed()"
+	}
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
+
+// ErrNoDatabase is returned when storing transfers without a database.
+type ErrNoDatabase struct {
+	Err error
+	Msg string
+}
+
+// Error returns the error message.
+func (e *ErrNoDatabase) Error() string {
+	if e.Msg == "" {
+		e.Msg = "no database provided- use WithDatabase()"
+	}
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
+
+// ErrStoring is returned when generated transfers cannot be written to the database.
+type ErrStoring struct {
+	Err error
+	Msg string
+}
+
+// Error returns the error message.
+func (e *ErrStoring) Error() string {
+	if e.Msg == "" {
+		e.Msg = "error storing walking transfers"
+	}
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
@@ -0,0 +1,226 @@
+package transfers
+
+import (
+	"math"
+	"os"
+	"sort"
+
+	"github.com/rmrfslashbin/gomarta/pkg/gtfspec"
+	"github.com/rmrfslashbin/gomarta/pkg/store"
+	"github.com/rs/zerolog"
+)
+
+const (
+	// defaultMaxDistance is the farthest straight line walk between two stops in meters
+	defaultMaxDistance = 250
+
+	// walkingSpeed is the assumed walking speed in meters per second
+	walkingSpeed = 1.3
+
+	// detourFactor scales straight line distance to the distance walked on streets
+	detourFactor = 1.3
+
+	// metersPerDegree is the length of one degree of latitude
+	metersPerDegree = 111320
+)
+
+// Options for the generator instance
+type Option func(c *Generator)
+
+// Generator creates walking transfers between stops that are close to each other
+type Generator struct {
+	log         *zerolog.Logger
+	db          store.Store
+	feed        *gtfspec.Feed
+	maxDistance float64
+}
+
+// New creates a new generator instance
+func New(opts ...Option) (*Generator, error) {
+	cfg := &Generator{}
+
+	// apply the list of options to Generator
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	// set up logger if not provided
+	if cfg.log == nil {
+		log := zerolog.New(os.Stderr).With().Timestamp().Logger()
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+		cfg.log = &log
+	}
+
+	if cfg.maxDistance <= 0 {
+		cfg.maxDistance = defaultMaxDistance
+	}
+
+	if cfg.feed == nil {
+		if cfg.db == nil {
+			return nil, &ErrNoData{}
+		}
+		feed, err := loadFeed(cfg.db)
+		if err != nil {
+			return nil, err
+		}
+		cfg.feed = feed
+	}
+
+	return cfg, nil
+}
+
+// WithDatabase reads stops and transfers from, and stores walking transfers in, the database
+func WithDatabase(db store.Store) Option {
+	return func(c *Generator) {
+		c.db = db
+	}
+}
+
+// WithFeed reads stops and transfers from an already parsed feed
+func WithFeed(feed *gtfspec.Feed) Option {
+	return func(c *Generator) {
+		c.feed = feed
+	}
+}
+
+// WithLogger sets the logger for the generator instance
+func WithLogger(log *zerolog.Logger) Option {
+	return func(c *Generator) {
+		c.log = log
+	}
+}
+
+// WithMaxDistance sets the farthest straight line walk between two stops in meters
+func WithMaxDistance(meters float64) Option {
+	return func(c *Generator) {
+		c.maxDistance = meters
+	}
+}
+
+// loadFeed reads the tables the generator needs from the database
+func loadFeed(db store.Store) (*gtfspec.Feed, error) {
+	feed := &gtfspec.Feed{}
+
+	var err error
+	if feed.Stops, err = db.GetAllStops(); err != nil {
+		return nil, &ErrLoadingData{Err: err, Structure: "Stops"}
+	}
+	if feed.Transfers, err = db.GetTransfers(); err != nil {
+		return nil, &ErrLoadingData{Err: err, Structure: "Transfers"}
+	}
+
+	return feed, nil
+}
+
+// Generate returns a walking transfer in each direction between every pair of
+// boardable stops within the maximum distance. Platforms of the same parent
+// station are always connected. Pairs already described by transfers.txt are
+// left to the feed, including those marked as not possible.
+func (c *Generator) Generate() ([]*gtfspec.WalkingTransfer, error) {
+	stops := make([]*gtfspec.Stop, 0, len(c.feed.Stops))
+	for _, stop := range c.feed.Stops {
+		if stop.LocationType == gtfspec.LocationStop && (stop.Lat != 0 || stop.Lon != 0) {
+			stops = append(stops, stop)
+		}
+	}
+
+	explicit := make(map[[2]string]bool, len(c.feed.Transfers))
+	for _, transfer := range c.feed.Transfers {
+		if transfer.FromRouteId == "" && transfer.ToRouteId == "" && transfer.FromTripId == "" && transfer.ToTripId == "" {
+			explicit[[2]string{transfer.FromStopId, transfer.ToStopId}] = true
+		}
+	}
+
+	transfers := make([]*gtfspec.WalkingTransfer, 0)
+	add := func(from *gtfspec.Stop, to *gtfspec.Stop, sameStation bool) {
+		if explicit[[2]string{from.StopId, to.StopId}] {
+			return
+		}
+		distance := from.Distance(to)
+		transfers = append(transfers, &gtfspec.WalkingTransfer{
+			FromStopId:  from.StopId,
+			ToStopId:    to.StopId,
+			Distance:    math.Round(distance*10) / 10,
+			WalkTime:    int(math.Ceil(distance * detourFactor / walkingSpeed)),
+			SameStation: sameStation,
+		})
+	}
+
+	// Bucket stops into a grid of cells at least maxDistance wide so only
+	// neighboring cells need to be compared.
+	latCell := c.maxDistance / metersPerDegree
+	lonCell := latCell
+	for _, stop := range stops {
+		if cell := latCell / math.Cos(stop.Lat*math.Pi/180); cell > lonCell {
+			lonCell = cell
+		}
+	}
+	grid := make(map[[2]int][]*gtfspec.Stop)
+	cellOf := func(stop *gtfspec.Stop) [2]int {
+		return [2]int{int(math.Floor(stop.Lat / latCell)), int(math.Floor(stop.Lon / lonCell))}
+	}
+	for _, stop := range stops {
+		grid[cellOf(stop)] = append(grid[cellOf(stop)], stop)
+	}
+
+	for _, from := range stops {
+		cell := cellOf(from)
+		for dLat := -1; dLat <= 1; dLat++ {
+			for dLon := -1; dLon <= 1; dLon++ {
+				for _, to := range grid[[2]int{cell[0] + dLat, cell[1] + dLon}] {
+					if to.StopId == from.StopId {
+						continue
+					}
+					sameStation := from.ParentStation != "" && from.ParentStation == to.ParentStation
+					if !sameStation && from.Distance(to) <= c.maxDistance {
+						add(from, to, false)
+					}
+				}
+			}
+		}
+	}
+
+	// Platforms of a large station may be farther apart than maxDistance.
+	platforms := make(map[string][]*gtfspec.Stop)
+	for _, stop := range stops {
+		if stop.ParentStation != "" {
+			platforms[stop.ParentStation] = append(platforms[stop.ParentStation], stop)
+		}
+	}
+	for _, station := range platforms {
+		for _, from := range station {
+			for _, to := range station {
+				if from.StopId != to.StopId {
+					add(from, to, true)
+				}
+			}
+		}
+	}
+
+	sort.Slice(transfers, func(i, j int) bool {
+		if transfers[i].FromStopId != transfers[j].FromStopId {
+			return transfers[i].FromStopId < transfers[j].FromStopId
+		}
+		return transfers[i].WalkTime < transfers[j].WalkTime
+	})
+
+	c.log.Debug().
+		Int("stops", len(stops)).
+		Int("transfers", len(transfers)).
+		Float64("maxDistance", c.maxDistance).
+		Str("function", "pkg/transfers.Generate()").
+		Msg("generated walking transfers")
+
+	return transfers, nil
+}
+
+// Store replaces the walking transfers in the database
+func (c *Generator) Store(transfers []*gtfspec.WalkingTransfer) error {
+	if c.db == nil {
+		return &ErrNoDatabase{}
+	}
+	if err := c.db.ReplaceWalkingTransfers(transfers); err != nil {
+		return &ErrStoring{Err: err}
+	}
+	return nil
+}
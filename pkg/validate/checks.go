@@ -0,0 +1,381 @@
+package validate
+
+import (
+	"math"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/rmrfslashbin/gomarta/pkg/gtfspec"
+	"github.com/rmrfslashbin/gomarta/pkg/specsupdate"
+)
+
+// metersPerDegree is the length of one degree of latitude
+const metersPerDegree = 111320
+
+// checkImport reports the records the importer could not parse
+func (c *Validator) checkImport(r *Report) {
+	if c.imported == nil {
+		return
+	}
+	for _, issue := range c.imported.Issues {
+		severity := SeverityError
+		if issue.Action == specsupdate.ActionDefaulted {
+			severity = SeverityWarning
+		}
+		id := "line " + strconv.Itoa(issue.Line)
+		if issue.Column != "" {
+			r.add(severity, "parse_error", issue.File, id, "%s %q: %s (%s)", issue.Column, issue.Value, issue.Reason, issue.Action)
+		} else {
+			r.add(severity, "parse_error", issue.File, id, "%s (%s)", issue.Reason, issue.Action)
+		}
+	}
+}
+
+// checkDuplicates reports records that share a primary key
+func (c *Validator) checkDuplicates(r *Report) {
+	duplicates := func(file string, keys []string) {
+		seen := make(map[string]int, len(keys))
+		for _, key := range keys {
+			seen[key]++
+			if seen[key] == 2 {
+				r.add(SeverityError, "duplicate_key", file, key, "key appears more than once")
+			}
+		}
+	}
+
+	keys := make([]string, 0, len(c.feed.Agencies))
+	for _, agency := range c.feed.Agencies {
+		keys = append(keys, agency.AgencyId)
+	}
+	duplicates("agency.txt", keys)
+
+	keys = make([]string, 0, len(c.feed.Stops))
+	for _, stop := range c.feed.Stops {
+		keys = append(keys, stop.StopId)
+	}
+	duplicates("stops.txt", keys)
+
+	keys = make([]string, 0, len(c.feed.Routes))
+	for _, route := range c.feed.Routes {
+		keys = append(keys, route.RouteId)
+	}
+	duplicates("routes.txt", keys)
+
+	keys = make([]string, 0, len(c.feed.Trips))
+	for _, trip := range c.feed.Trips {
+		keys = append(keys, trip.TripID)
+	}
+	duplicates("trips.txt", keys)
+
+	keys = make([]string, 0, len(c.feed.Calendars))
+	for _, calendar := range c.feed.Calendars {
+		keys = append(keys, calendar.ServiceId)
+	}
+	duplicates("calendar.txt", keys)
+
+	keys = make([]string, 0, len(c.feed.CalendarDates))
+	for _, date := range c.feed.CalendarDates {
+		keys = append(keys, date.ServiceId+"@"+date.Date.Format(gtfspec.DateFormat))
+	}
+	duplicates("calendar_dates.txt", keys)
+
+	keys = make([]string, 0, len(c.feed.StopTimes))
+	for _, stopTime := range c.feed.StopTimes {
+		keys = append(keys, stopTime.TripId+"#"+strconv.Itoa(stopTime.StopSequence))
+	}
+	duplicates("stop_times.txt", keys)
+
+	keys = make([]string, 0, len(c.feed.Shapes))
+	for _, point := range c.feed.Shapes {
+		keys = append(keys, point.ShapeId+"#"+strconv.Itoa(point.Sequence))
+	}
+	duplicates("shapes.txt", keys)
+}
+
+// checkReferences reports ids that point at records missing from the feed
+func (c *Validator) checkReferences(r *Report) {
+	agencies := make(map[string]bool, len(c.feed.Agencies))
+	for _, agency := range c.feed.Agencies {
+		agencies[agency.AgencyId] = true
+	}
+	stops := make(map[string]*gtfspec.Stop, len(c.feed.Stops))
+	for _, stop := range c.feed.Stops {
+		stops[stop.StopId] = stop
+	}
+	routes := make(map[string]bool, len(c.feed.Routes))
+	for _, route := range c.feed.Routes {
+		routes[route.RouteId] = true
+	}
+	trips := make(map[string]bool, len(c.feed.Trips))
+	for _, trip := range c.feed.Trips {
+		trips[trip.TripID] = true
+	}
+	services := make(map[string]bool, len(c.feed.Calendars)+len(c.feed.CalendarDates))
+	for _, calendar := range c.feed.Calendars {
+		services[calendar.ServiceId] = true
+	}
+	for _, date := range c.feed.CalendarDates {
+		services[date.ServiceId] = true
+	}
+	shapes := make(map[string]bool)
+	for _, point := range c.feed.Shapes {
+		shapes[point.ShapeId] = true
+	}
+
+	for _, route := range c.feed.Routes {
+		// agency_id may be omitted when the feed has a single agency
+		if route.AgencyId == "" && len(c.feed.Agencies) <= 1 {
+			continue
+		}
+		if !agencies[route.AgencyId] {
+			r.add(SeverityError, "missing_reference", "routes.txt", route.RouteId, "agency_id %q not in agency.txt", route.AgencyId)
+		}
+	}
+
+	for _, stop := range c.feed.Stops {
+		if stop.ParentStation == "" {
+			continue
+		}
+		if parent, ok := stops[stop.ParentStation]; !ok {
+			r.add(SeverityError, "missing_reference", "stops.txt", stop.StopId, "parent_station %q not in stops.txt", stop.ParentStation)
+		} else if parent.LocationType != gtfspec.LocationStation && stop.LocationType != gtfspec.LocationBoardingArea {
+			r.add(SeverityError, "invalid_parent_station", "stops.txt", stop.StopId, "parent_station %q is not a station", stop.ParentStation)
+		}
+	}
+
+	for _, trip := range c.feed.Trips {
+		if !routes[trip.RouteId] {
+			r.add(SeverityError, "missing_reference", "trips.txt", trip.TripID, "route_id %q not in routes.txt", trip.RouteId)
+		}
+		if !services[trip.ServiceId] {
+			r.add(SeverityError, "missing_reference", "trips.txt", trip.TripID, "service_id %q not in calendar.txt or calendar_dates.txt", trip.ServiceId)
+		}
+		if trip.ShapeId != "" && !shapes[trip.ShapeId] {
+			r.add(SeverityError, "missing_reference", "trips.txt", trip.TripID, "shape_id %q not in shapes.txt", trip.ShapeId)
+		}
+	}
+
+	// Report each missing trip or stop once rather than once per stop time.
+	missingTrips := make(map[string]bool)
+	missingStops := make(map[string]bool)
+	for _, stopTime := range c.feed.StopTimes {
+		if !trips[stopTime.TripId] && !missingTrips[stopTime.TripId] {
+			missingTrips[stopTime.TripId] = true
+			r.add(SeverityError, "missing_reference", "stop_times.txt", stopTime.TripId, "trip_id %q not in trips.txt", stopTime.TripId)
+		}
+		if _, ok := stops[stopTime.StopId]; !ok && !missingStops[stopTime.StopId] {
+			missingStops[stopTime.StopId] = true
+			r.add(SeverityError, "missing_reference", "stop_times.txt", stopTime.StopId, "stop_id %q not in stops.txt", stopTime.StopId)
+		}
+	}
+
+	for _, transfer := range c.feed.Transfers {
+		id := transfer.FromStopId + ">" + transfer.ToStopId
+		for _, stopId := range []string{transfer.FromStopId, transfer.ToStopId} {
+			if _, ok := stops[stopId]; stopId != "" && !ok {
+				r.add(SeverityError, "missing_reference", "transfers.txt", id, "stop_id %q not in stops.txt", stopId)
+			}
+		}
+	}
+
+	for _, frequency := range c.feed.Frequencies {
+		if !trips[frequency.TripId] {
+			r.add(SeverityError, "missing_reference", "frequencies.txt", frequency.TripId, "trip_id %q not in trips.txt", frequency.TripId)
+		}
+	}
+}
+
+// checkStopTimes reports trips whose stop times are too few, out of order,
+// missing times at the ends or going back in time
+func (c *Validator) checkStopTimes(r *Report) {
+	byTrip := make(map[string][]*gtfspec.StopTime)
+	for _, stopTime := range c.feed.StopTimes {
+		byTrip[stopTime.TripId] = append(byTrip[stopTime.TripId], stopTime)
+	}
+
+	for _, trip := range c.feed.Trips {
+		if _, ok := byTrip[trip.TripID]; !ok {
+			r.add(SeverityWarning, "unused_trip", "trips.txt", trip.TripID, "trip has no stop times")
+		}
+	}
+
+	for tripId, stopTimes := range byTrip {
+		if len(stopTimes) < 2 {
+			r.add(SeverityError, "too_few_stops", "stop_times.txt", tripId, "trip has %d stop time, at least 2 are needed", len(stopTimes))
+		}
+
+		// stop_times.txt is not required to be ordered, only stop_sequence must increase.
+		sorted := sort.SliceIsSorted(stopTimes, func(i, j int) bool {
+			return stopTimes[i].StopSequence < stopTimes[j].StopSequence
+		})
+		if !sorted {
+			r.add(SeverityInfo, "unordered_stop_times", "stop_times.txt", tripId, "stop times are not listed in stop_sequence order")
+			sort.SliceStable(stopTimes, func(i, j int) bool {
+				return stopTimes[i].StopSequence < stopTimes[j].StopSequence
+			})
+		}
+
+		first, last := stopTimes[0], stopTimes[len(stopTimes)-1]
+		if !first.ArrivalTime.IsSet() && !first.DepartureTime.IsSet() {
+			r.add(SeverityError, "missing_time", "stop_times.txt", tripId, "first stop %q has no arrival or departure time", first.StopId)
+		}
+		if len(stopTimes) > 1 && !last.ArrivalTime.IsSet() && !last.DepartureTime.IsSet() {
+			r.add(SeverityError, "missing_time", "stop_times.txt", tripId, "last stop %q has no arrival or departure time", last.StopId)
+		}
+
+		previous := gtfspec.NoTime
+		previousSequence := 0
+		for _, stopTime := range stopTimes {
+			arrival, departure := stopTime.ArrivalTime, stopTime.DepartureTime
+			if arrival.IsSet() && departure.IsSet() && departure < arrival {
+				r.add(SeverityError, "departure_before_arrival", "stop_times.txt", tripId,
+					"stop_sequence %d departs %s before it arrives %s", stopTime.StopSequence, departure, arrival)
+			}
+			if !arrival.IsSet() {
+				arrival = departure
+			}
+			if !departure.IsSet() {
+				departure = arrival
+			}
+			if arrival.IsSet() && previous.IsSet() && arrival < previous {
+				r.add(SeverityError, "decreasing_time", "stop_times.txt", tripId,
+					"stop_sequence %d at %s is earlier than stop_sequence %d at %s", stopTime.StopSequence, arrival, previousSequence, previous)
+			}
+			if departure.IsSet() {
+				previous, previousSequence = departure, stopTime.StopSequence
+			}
+		}
+	}
+}
+
+// checkShapeDistance reports stops that are farther from their trip's shape
+// than the configured distance
+func (c *Validator) checkShapeDistance(r *Report) {
+	shapes := make(map[string][]*gtfspec.Shape)
+	for _, point := range c.feed.Shapes {
+		shapes[point.ShapeId] = append(shapes[point.ShapeId], point)
+	}
+	for _, points := range shapes {
+		sort.SliceStable(points, func(i, j int) bool {
+			return points[i].Sequence < points[j].Sequence
+		})
+	}
+
+	stops := make(map[string]*gtfspec.Stop, len(c.feed.Stops))
+	for _, stop := range c.feed.Stops {
+		stops[stop.StopId] = stop
+	}
+	tripShapes := make(map[string]string, len(c.feed.Trips))
+	for _, trip := range c.feed.Trips {
+		if trip.ShapeId != "" {
+			tripShapes[trip.TripID] = trip.ShapeId
+		}
+	}
+
+	// Many trips share a shape and stops; check each pair once.
+	checked := make(map[[2]string]bool)
+	for _, stopTime := range c.feed.StopTimes {
+		shapeId, ok := tripShapes[stopTime.TripId]
+		if !ok || len(shapes[shapeId]) == 0 {
+			continue
+		}
+		pair := [2]string{shapeId, stopTime.StopId}
+		if checked[pair] {
+			continue
+		}
+		checked[pair] = true
+
+		stop, ok := stops[stopTime.StopId]
+		if !ok || (stop.Lat == 0 && stop.Lon == 0) {
+			continue
+		}
+		if distance := shapeDistance(stop, shapes[shapeId]); distance > c.shapeDistance {
+			r.add(SeverityWarning, "stop_too_far_from_shape", "stop_times.txt", shapeId+"/"+stop.StopId,
+				"stop %q is %.0fm from shape %q", stop.StopId, distance, shapeId)
+		}
+	}
+}
+
+// shapeDistance returns the distance in meters from a stop to the closest
+// segment of a shape, using a flat projection around the stop
+func shapeDistance(stop *gtfspec.Stop, points []*gtfspec.Shape) float64 {
+	scale := math.Cos(stop.Lat * math.Pi / 180)
+	project := func(lat float64, lon float64) (float64, float64) {
+		return (lon - stop.Lon) * scale * metersPerDegree, (lat - stop.Lat) * metersPerDegree
+	}
+
+	best := math.Inf(1)
+	ax, ay := project(points[0].Lat, points[0].Lon)
+	if len(points) == 1 {
+		return math.Hypot(ax, ay)
+	}
+	for _, point := range points[1:] {
+		bx, by := project(point.Lat, point.Lon)
+		dx, dy := bx-ax, by-ay
+		t := 0.0
+		if length := dx*dx + dy*dy; length > 0 {
+			t = math.Max(0, math.Min(1, -(ax*dx+ay*dy)/length))
+		}
+		best = math.Min(best, math.Hypot(ax+t*dx, ay+t*dy))
+		ax, ay = bx, by
+	}
+	return best
+}
+
+// checkCalendars reports services and feeds that have ended before the validation date
+func (c *Validator) checkCalendars(r *Report) {
+	today := time.Date(c.date.Year(), c.date.Month(), c.date.Day(), 0, 0, 0, 0, time.UTC)
+
+	// A service is active if its calendar or any added date is still to come.
+	active := make(map[string]bool)
+	for _, calendar := range c.feed.Calendars {
+		if calendar.EndDate.Before(calendar.StartDate) {
+			r.add(SeverityError, "invalid_date_range", "calendar.txt", calendar.ServiceId,
+				"end_date %s is before start_date %s", calendar.EndDate.Format(gtfspec.DateFormat), calendar.StartDate.Format(gtfspec.DateFormat))
+		}
+		if !calendar.EndDate.Before(today) {
+			active[calendar.ServiceId] = true
+		}
+	}
+	for _, date := range c.feed.CalendarDates {
+		if date.ExceptionType == gtfspec.ExceptionAdded && !date.Date.Before(today) {
+			active[date.ServiceId] = true
+		}
+	}
+
+	for _, calendar := range c.feed.Calendars {
+		if !active[calendar.ServiceId] {
+			r.add(SeverityWarning, "expired_calendar", "calendar.txt", calendar.ServiceId,
+				"service ended %s", calendar.EndDate.Format(gtfspec.DateFormat))
+		}
+	}
+
+	if len(c.feed.Calendars)+len(c.feed.CalendarDates) > 0 && len(active) == 0 {
+		r.add(SeverityError, "expired_feed", "calendar.txt", "", "every service ended before %s", today.Format(gtfspec.DateFormat))
+	}
+
+	for _, info := range c.feed.FeedInfo {
+		if !info.EndDate.IsZero() && info.EndDate.Before(today) {
+			r.add(SeverityWarning, "expired_feed", "feed_info.txt", info.Version, "feed_end_date %s has passed", info.EndDate.Format(gtfspec.DateFormat))
+		}
+	}
+}
+
+// checkCoordinates reports stops outside the service area
+func (c *Validator) checkCoordinates(r *Report) {
+	for _, stop := range c.feed.Stops {
+		// Generic nodes and boarding areas may omit coordinates.
+		if stop.LocationType == gtfspec.LocationGenericNode || stop.LocationType == gtfspec.LocationBoardingArea {
+			continue
+		}
+		if stop.Lat == 0 && stop.Lon == 0 {
+			r.add(SeverityError, "missing_coordinates", "stops.txt", stop.StopId, "stop is at 0,0")
+			continue
+		}
+		if !c.bounds.Contains(stop.Lat, stop.Lon) {
+			r.add(SeverityWarning, "outside_service_area", "stops.txt", stop.StopId,
+				"stop at %.6f,%.6f is outside the service area", stop.Lat, stop.Lon)
+		}
+	}
+}
@@ -0,0 +1,83 @@
+package validate
+
+import "strconv"
+
+// ErrNoFeed is returned when no feed is provided.
+type ErrNoFeed struct {
+	Err error
+	Msg string
+}
+
+// Error returns the error message.
+func (e *ErrNoFeed) Error() string {
+	if e.Msg == "" {
+		e.Msg = "no feed provided- use WithFeed()"
+	}
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
+
+// ErrInvalidFeed is returned when a validation finds errors.
+type ErrInvalidFeed struct {
+	Err    error
+	Errors int
+	Msg    string
+}
+
+// Error returns the error message.
+func (e *ErrInvalidFeed) Error() string {
+	if e.Msg == "" {
+		e.Msg = "feed is invalid"
+	}
+	if e.Errors > 0 {
+		e.Msg += ": " + strconv.Itoa(e.Errors) + " errors"
+	}
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
+
+// ErrFetchingFeed is returned when a realtime feed cannot be fetched.
+type ErrFetchingFeed struct {
+	Err error
+	Url string
+	Msg string
+}
+
+// Error returns the error message.
+func (e *ErrFetchingFeed) Error() string {
+	if e.Msg == "" {
+		e.Msg = "error fetching realtime feed"
+	}
+	if e.Url != "" {
+		e.Msg += ": " + e.Url
+	}
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
+
+// ErrDecodingFeed is returned when a realtime feed is not a valid protobuf message.
+type ErrDecodingFeed struct {
+	Err error
+	Url string
+	Msg string
+}
+
+// Error returns the error message.
+func (e *ErrDecodingFeed) Error() string {
+	if e.Msg == "" {
+		e.Msg = "error decoding realtime feed"
+	}
+	if e.Url != "" {
+		e.Msg += ": " + e.Url
+	}
+	if e.Err != nil {
+		e.Msg += ": " + e.Err.Error()
+	}
+	return e.Msg
+}
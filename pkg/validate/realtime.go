@@ -0,0 +1,351 @@
+package validate
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/rmrfslashbin/gomarta/pkg/gtfspec"
+	"github.com/rmrfslashbin/gomarta/pkg/gtfsrt"
+	"github.com/rmrfslashbin/gomarta/pkg/schedule"
+	"google.golang.org/protobuf/proto"
+)
+
+const (
+	// defaultMaxAge is how old a realtime timestamp may be before it is reported as stale
+	defaultMaxAge = 15 * time.Minute
+
+	// futureSlack allows for clock drift between the producer and the validator
+	futureSlack = time.Minute
+)
+
+// Realtime file names used in findings
+const (
+	fileTripUpdates      = "trip_updates"
+	fileVehiclePositions = "vehicle_positions"
+)
+
+// staticIndex is the part of the static feed realtime data refers to
+type staticIndex struct {
+	routes    map[string]*gtfspec.Route
+	trips     map[string]*gtfspec.Trip
+	stops     map[string]*gtfspec.Stop
+	stopTimes map[string][]*gtfspec.StopTime
+	schedule  *schedule.Schedule
+}
+
+// FetchRealtime downloads and decodes a GTFS-RT feed
+func FetchRealtime(url string) (*gtfsrt.FeedMessage, error) {
+	resp, err := http.Get(url)
+	if err != nil {
+		return nil, &ErrFetchingFeed{Err: err, Url: url}
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, &ErrFetchingFeed{Err: err, Url: url}
+	}
+
+	message := &gtfsrt.FeedMessage{}
+	if err := proto.Unmarshal(body, message); err != nil {
+		return nil, &ErrDecodingFeed{Err: err, Url: url}
+	}
+	return message, nil
+}
+
+// ValidateRealtime checks a GTFS-RT feed against the static feed. Trip
+// updates and vehicle positions may be mixed in one message.
+func (c *Validator) ValidateRealtime(message *gtfsrt.FeedMessage) (*Report, error) {
+	index, err := c.staticIndex()
+	if err != nil {
+		return nil, err
+	}
+
+	report := newReport()
+	now := time.Now()
+
+	c.checkTimestamp(report, "header", "", message.GetHeader().GetTimestamp(), now)
+
+	for _, entity := range message.GetEntity() {
+		if entity.GetIsDeleted() {
+			continue
+		}
+		if tripUpdate := entity.GetTripUpdate(); tripUpdate != nil {
+			c.checkTripUpdate(report, index, entity.GetId(), tripUpdate, now)
+		}
+		if vehicle := entity.GetVehicle(); vehicle != nil {
+			c.checkVehicle(report, index, entity.GetId(), vehicle, now)
+		}
+	}
+
+	c.log.Debug().
+		Int("entities", len(message.GetEntity())).
+		Int("findings", len(report.Findings)).
+		Str("function", "pkg/validate.ValidateRealtime()").
+		Msg("validated realtime feed")
+
+	report.sort()
+	return report, nil
+}
+
+// staticIndex builds the lookups realtime checks need, once per validator
+func (c *Validator) staticIndex() (*staticIndex, error) {
+	if c.index != nil {
+		return c.index, nil
+	}
+
+	sched, err := schedule.New(
+		schedule.WithFeed(c.feed),
+		schedule.WithLogger(c.log),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	index := &staticIndex{
+		routes:    make(map[string]*gtfspec.Route, len(c.feed.Routes)),
+		trips:     make(map[string]*gtfspec.Trip, len(c.feed.Trips)),
+		stops:     make(map[string]*gtfspec.Stop, len(c.feed.Stops)),
+		stopTimes: make(map[string][]*gtfspec.StopTime, len(c.feed.Trips)),
+		schedule:  sched,
+	}
+	for _, route := range c.feed.Routes {
+		index.routes[route.RouteId] = route
+	}
+	for _, trip := range c.feed.Trips {
+		index.trips[trip.TripID] = trip
+	}
+	for _, stop := range c.feed.Stops {
+		index.stops[stop.StopId] = stop
+	}
+	for _, stopTime := range c.feed.StopTimes {
+		index.stopTimes[stopTime.TripId] = append(index.stopTimes[stopTime.TripId], stopTime)
+	}
+
+	c.index = index
+	return index, nil
+}
+
+// checkTimestamp reports a POSIX timestamp that is missing, in the future or too old
+func (c *Validator) checkTimestamp(r *Report, file string, id string, timestamp uint64, now time.Time) {
+	if timestamp == 0 {
+		if file == "header" {
+			r.add(SeverityError, "missing_timestamp", file, id, "feed header has no timestamp")
+		}
+		return
+	}
+	at := time.Unix(int64(timestamp), 0)
+	if at.After(now.Add(futureSlack)) {
+		r.add(SeverityError, "future_timestamp", file, id, "timestamp %s is %s in the future",
+			at.Format(time.RFC3339), at.Sub(now).Round(time.Second))
+	} else if at.Before(now.Add(-c.maxAge)) {
+		r.add(SeverityWarning, "stale_timestamp", file, id, "timestamp %s is %s old",
+			at.Format(time.RFC3339), now.Sub(at).Round(time.Second))
+	}
+}
+
+// checkTripDescriptor reports a trip or route the static feed doesn't know and
+// returns the scheduled run of the trip, if it can be resolved
+func (c *Validator) checkTripDescriptor(r *Report, index *staticIndex, file string, id string, descriptor *gtfsrt.TripDescriptor, observed time.Time) *schedule.TripInstance {
+	if descriptor == nil {
+		r.add(SeverityError, "missing_trip", file, id, "entity has no trip descriptor")
+		return nil
+	}
+
+	tripId := gtfspec.Namespaced(c.namespace, descriptor.GetTripId())
+	routeId := gtfspec.Namespaced(c.namespace, descriptor.GetRouteId())
+	if tripId == "" && routeId == "" {
+		r.add(SeverityError, "missing_trip", file, id, "trip descriptor has neither trip_id nor route_id")
+		return nil
+	}
+
+	if routeId != "" {
+		if _, ok := index.routes[routeId]; !ok {
+			r.add(SeverityError, "unknown_route", file, id, "route_id %q not in the static feed", routeId)
+		}
+	}
+
+	// Added and unscheduled trips are not expected to be in the static feed.
+	switch descriptor.GetScheduleRelationship() {
+	case gtfsrt.TripDescriptor_ADDED, gtfsrt.TripDescriptor_UNSCHEDULED:
+		return nil
+	}
+	if tripId == "" {
+		return nil
+	}
+
+	trip, ok := index.trips[tripId]
+	if !ok {
+		r.add(SeverityError, "unknown_trip", file, id, "trip_id %q not in the static feed", tripId)
+		return nil
+	}
+	if routeId != "" && trip.RouteId != routeId {
+		r.add(SeverityError, "route_mismatch", file, id, "trip_id %q belongs to route %q, not %q", tripId, trip.RouteId, routeId)
+	}
+
+	if observed.Unix() <= 0 {
+		observed = time.Now()
+	}
+	instance, err := index.schedule.Instance(&schedule.InstanceInput{
+		TripId:    tripId,
+		StartDate: descriptor.GetStartDate(),
+		StartTime: descriptor.GetStartTime(),
+		Observed:  observed,
+	})
+	if err != nil {
+		var notRunning *schedule.ErrNotRunning
+		if errors.As(err, &notRunning) {
+			r.add(SeverityWarning, "trip_not_running", file, id, "trip_id %q is not scheduled to run around %s", tripId, observed.Format(time.RFC3339))
+		} else {
+			r.add(SeverityWarning, "trip_not_running", file, id, "trip_id %q: %s", tripId, err)
+		}
+		return nil
+	}
+	return instance
+}
+
+// checkStop reports a stop_id or stop_sequence that doesn't exist on the trip
+func (c *Validator) checkStop(r *Report, index *staticIndex, file string, id string, tripId string, stopId string, stopSequence *uint32) {
+	if stopId != "" {
+		if _, ok := index.stops[stopId]; !ok {
+			r.add(SeverityError, "unknown_stop", file, id, "stop_id %q not in the static feed", stopId)
+			return
+		}
+	}
+
+	stopTimes, ok := index.stopTimes[tripId]
+	if !ok {
+		return
+	}
+
+	var bySequence *gtfspec.StopTime
+	onTrip := stopId == ""
+	for _, stopTime := range stopTimes {
+		if stopSequence != nil && stopTime.StopSequence == int(*stopSequence) {
+			bySequence = stopTime
+		}
+		if stopTime.StopId == stopId {
+			onTrip = true
+		}
+	}
+
+	if !onTrip {
+		r.add(SeverityError, "stop_not_on_trip", file, id, "stop_id %q is not served by trip_id %q", stopId, tripId)
+	}
+	if stopSequence != nil {
+		if bySequence == nil {
+			r.add(SeverityError, "unknown_stop_sequence", file, id, "stop_sequence %d not on trip_id %q", *stopSequence, tripId)
+		} else if onTrip && stopId != "" && bySequence.StopId != stopId {
+			r.add(SeverityError, "stop_sequence_mismatch", file, id, "stop_sequence %d of trip_id %q is stop %q, not %q", *stopSequence, tripId, bySequence.StopId, stopId)
+		}
+	}
+}
+
+// checkTripUpdate reports problems with a trip update and its stop time updates
+func (c *Validator) checkTripUpdate(r *Report, index *staticIndex, id string, tripUpdate *gtfsrt.TripUpdate, now time.Time) {
+	c.checkTimestamp(r, fileTripUpdates, id, tripUpdate.GetTimestamp(), now)
+
+	descriptor := tripUpdate.GetTrip()
+	instance := c.checkTripDescriptor(r, index, fileTripUpdates, id, descriptor, time.Unix(int64(tripUpdate.GetTimestamp()), 0))
+	if descriptor.GetScheduleRelationship() == gtfsrt.TripDescriptor_CANCELED {
+		return
+	}
+
+	var previous time.Time
+	var previousSequence uint32
+	for _, update := range tripUpdate.GetStopTimeUpdate() {
+		stopId := gtfspec.Namespaced(c.namespace, update.GetStopId())
+		c.checkStop(r, index, fileTripUpdates, id, gtfspec.Namespaced(c.namespace, descriptor.GetTripId()), stopId, update.StopSequence)
+
+		if update.StopSequence != nil {
+			if previousSequence > 0 && update.GetStopSequence() <= previousSequence {
+				r.add(SeverityError, "unordered_stop_time_updates", fileTripUpdates, id,
+					"stop_sequence %d follows stop_sequence %d", update.GetStopSequence(), previousSequence)
+			}
+			previousSequence = update.GetStopSequence()
+		}
+
+		if update.GetScheduleRelationship() != gtfsrt.TripUpdate_StopTimeUpdate_SCHEDULED {
+			continue
+		}
+
+		var scheduled *schedule.InstanceStopTime
+		if instance != nil {
+			if update.StopSequence != nil {
+				scheduled = instance.StopTimeBySequence(int(update.GetStopSequence()))
+			} else {
+				scheduled = instance.StopTimeByStop(stopId)
+			}
+		}
+		var scheduledArrival, scheduledDeparture time.Time
+		if scheduled != nil {
+			scheduledArrival, scheduledDeparture = scheduled.Arrival, scheduled.Departure
+		}
+
+		arrival := predicted(update.GetArrival(), scheduledArrival)
+		departure := predicted(update.GetDeparture(), scheduledDeparture)
+		label := update.GetStopId()
+		if update.StopSequence != nil {
+			label = "stop_sequence " + strconv.FormatUint(uint64(update.GetStopSequence()), 10)
+		}
+
+		if !arrival.IsZero() && !departure.IsZero() && departure.Before(arrival) {
+			r.add(SeverityError, "departure_before_arrival", fileTripUpdates, id,
+				"%s departs %s before it arrives %s", label, departure.Format(time.TimeOnly), arrival.Format(time.TimeOnly))
+		}
+		if arrival.IsZero() {
+			arrival = departure
+		}
+		if !arrival.IsZero() && !previous.IsZero() && arrival.Before(previous) {
+			r.add(SeverityError, "decreasing_prediction", fileTripUpdates, id,
+				"%s is predicted at %s, before the previous stop at %s", label, arrival.Format(time.TimeOnly), previous.Format(time.TimeOnly))
+		}
+		if !departure.IsZero() {
+			previous = departure
+		} else if !arrival.IsZero() {
+			previous = arrival
+		}
+	}
+}
+
+// checkVehicle reports problems with a vehicle position
+func (c *Validator) checkVehicle(r *Report, index *staticIndex, id string, vehicle *gtfsrt.VehiclePosition, now time.Time) {
+	c.checkTimestamp(r, fileVehiclePositions, id, vehicle.GetTimestamp(), now)
+
+	if descriptor := vehicle.GetTrip(); descriptor != nil {
+		c.checkTripDescriptor(r, index, fileVehiclePositions, id, descriptor, time.Unix(int64(vehicle.GetTimestamp()), 0))
+		if vehicle.GetStopId() != "" || vehicle.CurrentStopSequence != nil {
+			c.checkStop(r, index, fileVehiclePositions, id, gtfspec.Namespaced(c.namespace, descriptor.GetTripId()), gtfspec.Namespaced(c.namespace, vehicle.GetStopId()), vehicle.CurrentStopSequence)
+		}
+	}
+
+	position := vehicle.GetPosition()
+	if position == nil {
+		return
+	}
+	lat, lon := float64(position.GetLatitude()), float64(position.GetLongitude())
+	if lat == 0 && lon == 0 {
+		r.add(SeverityError, "missing_coordinates", fileVehiclePositions, id, "vehicle is at 0,0")
+	} else if !c.bounds.Contains(lat, lon) {
+		r.add(SeverityWarning, "outside_service_area", fileVehiclePositions, id,
+			"vehicle at %.6f,%.6f is outside the service area", lat, lon)
+	}
+}
+
+// predicted returns the time of a stop time event, derived from the schedule
+// when the event only carries a delay. The zero time means no prediction.
+func predicted(event *gtfsrt.TripUpdate_StopTimeEvent, scheduled time.Time) time.Time {
+	if event == nil {
+		return time.Time{}
+	}
+	if event.GetTime() > 0 {
+		return time.Unix(event.GetTime(), 0)
+	}
+	if event.Delay != nil && !scheduled.IsZero() {
+		return scheduled.Add(time.Duration(event.GetDelay()) * time.Second)
+	}
+	return time.Time{}
+}
@@ -0,0 +1,130 @@
+package validate
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"text/tabwriter"
+)
+
+// Severity ranks how serious a finding is.
+type Severity string
+
+const (
+	// SeverityError is a violation of the GTFS reference that consumers may reject.
+	SeverityError Severity = "error"
+
+	// SeverityWarning is likely a mistake, but the feed is still usable.
+	SeverityWarning Severity = "warning"
+
+	// SeverityInfo is worth knowing about but needs no action.
+	SeverityInfo Severity = "info"
+)
+
+// rank orders severities, most serious first.
+func (s Severity) rank() int {
+	switch s {
+	case SeverityError:
+		return 0
+	case SeverityWarning:
+		return 1
+	}
+	return 2
+}
+
+// Finding is one problem found in a feed.
+type Finding struct {
+	Severity Severity `json:"severity"`
+	Code     string   `json:"code"`
+	File     string   `json:"file"`
+	Id       string   `json:"id,omitempty"`
+	Message  string   `json:"message"`
+}
+
+// Report collects the findings of a validation.
+type Report struct {
+	Counts   map[Severity]int `json:"counts"`
+	Findings []*Finding       `json:"findings"`
+}
+
+// newReport creates an empty report.
+func newReport() *Report {
+	return &Report{
+		Counts: map[Severity]int{
+			SeverityError:   0,
+			SeverityWarning: 0,
+			SeverityInfo:    0,
+		},
+		Findings: make([]*Finding, 0),
+	}
+}
+
+// add records a finding.
+func (r *Report) add(severity Severity, code string, file string, id string, format string, args ...interface{}) {
+	r.Counts[severity]++
+	r.Findings = append(r.Findings, &Finding{
+		Severity: severity,
+		Code:     code,
+		File:     file,
+		Id:       id,
+		Message:  fmt.Sprintf(format, args...),
+	})
+}
+
+// sort orders the findings by severity, then code, file and id.
+func (r *Report) sort() {
+	sort.SliceStable(r.Findings, func(i, j int) bool {
+		a, b := r.Findings[i], r.Findings[j]
+		if a.Severity.rank() != b.Severity.rank() {
+			return a.Severity.rank() < b.Severity.rank()
+		}
+		if a.Code != b.Code {
+			return a.Code < b.Code
+		}
+		if a.File != b.File {
+			return a.File < b.File
+		}
+		return a.Id < b.Id
+	})
+}
+
+// HasErrors reports whether any finding is an error.
+func (r *Report) HasErrors() bool {
+	return r.Counts[SeverityError] > 0
+}
+
+// CountByCode returns the number of findings of each code.
+func (r *Report) CountByCode() map[string]int {
+	counts := make(map[string]int)
+	for _, f := range r.Findings {
+		counts[f.Code]++
+	}
+	return counts
+}
+
+// WriteJSON writes the report as indented JSON.
+func (r *Report) WriteJSON(w io.Writer) error {
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(r)
+}
+
+// WriteText writes a summary followed by one line per finding, most serious first.
+func (r *Report) WriteText(w io.Writer) error {
+	if _, err := fmt.Fprintf(w, "%d errors, %d warnings, %d info\n",
+		r.Counts[SeverityError], r.Counts[SeverityWarning], r.Counts[SeverityInfo]); err != nil {
+		return err
+	}
+	if len(r.Findings) == 0 {
+		return nil
+	}
+
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "SEVERITY\tCODE\tFILE\tID\tMESSAGE\n")
+	for _, f := range r.Findings {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", f.Severity, f.Code, f.File, f.Id, f.Message)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,172 @@
+package validate
+
+import (
+	"os"
+	"time"
+
+	"github.com/rmrfslashbin/gomarta/pkg/gtfspec"
+	"github.com/rmrfslashbin/gomarta/pkg/specsupdate"
+	"github.com/rs/zerolog"
+)
+
+// defaultShapeDistance is how far in meters a stop may be from its trip's shape
+const defaultShapeDistance = 150
+
+// DefaultBounds covers the MARTA service area with some margin
+var DefaultBounds = &Bounds{MinLat: 33.3, MinLon: -84.9, MaxLat: 34.3, MaxLon: -83.9}
+
+// Bounds is a latitude/longitude box stops are expected to be in
+type Bounds struct {
+	MinLat float64
+	MinLon float64
+	MaxLat float64
+	MaxLon float64
+}
+
+// Contains reports whether a coordinate is inside the bounds
+func (b *Bounds) Contains(lat float64, lon float64) bool {
+	return lat >= b.MinLat && lat <= b.MaxLat && lon >= b.MinLon && lon <= b.MaxLon
+}
+
+// Options for the validator instance
+type Option func(c *Validator)
+
+// Validator checks a static GTFS feed for problems
+type Validator struct {
+	log           *zerolog.Logger
+	feed          *gtfspec.Feed
+	imported      *specsupdate.Report
+	date          time.Time
+	bounds        *Bounds
+	shapeDistance float64
+	maxAge        time.Duration
+	namespace     string
+	index         *staticIndex
+}
+
+// New creates a new validator instance
+func New(opts ...Option) (*Validator, error) {
+	cfg := &Validator{}
+
+	// apply the list of options to Validator
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	// set up logger if not provided
+	if cfg.log == nil {
+		log := zerolog.New(os.Stderr).With().Timestamp().Logger()
+		zerolog.SetGlobalLevel(zerolog.DebugLevel)
+		cfg.log = &log
+	}
+
+	if cfg.feed == nil {
+		return nil, &ErrNoFeed{}
+	}
+
+	if cfg.date.IsZero() {
+		cfg.date = time.Now()
+	}
+
+	if cfg.bounds == nil {
+		cfg.bounds = DefaultBounds
+	}
+
+	if cfg.shapeDistance <= 0 {
+		cfg.shapeDistance = defaultShapeDistance
+	}
+
+	if cfg.maxAge <= 0 {
+		cfg.maxAge = defaultMaxAge
+	}
+
+	return cfg, nil
+}
+
+// WithFeed sets the feed to validate
+func WithFeed(feed *gtfspec.Feed) Option {
+	return func(c *Validator) {
+		c.feed = feed
+	}
+}
+
+// WithImportReport adds the records that failed to parse to the findings
+func WithImportReport(report *specsupdate.Report) Option {
+	return func(c *Validator) {
+		c.imported = report
+	}
+}
+
+// WithDate sets the date calendars are checked for expiry against; defaults to today
+func WithDate(date time.Time) Option {
+	return func(c *Validator) {
+		c.date = date
+	}
+}
+
+// WithBounds sets the service area stops are expected to be in
+func WithBounds(bounds *Bounds) Option {
+	return func(c *Validator) {
+		c.bounds = bounds
+	}
+}
+
+// WithShapeDistance sets how far in meters a stop may be from its trip's shape
+func WithShapeDistance(meters float64) Option {
+	return func(c *Validator) {
+		c.shapeDistance = meters
+	}
+}
+
+// WithMaxAge sets how old a realtime timestamp may be before it is reported as stale
+func WithMaxAge(maxAge time.Duration) Option {
+	return func(c *Validator) {
+		c.maxAge = maxAge
+	}
+}
+
+// WithNamespace sets the namespace the static feed was imported with, so the
+// ids of realtime feeds are matched to the stored ones
+func WithNamespace(namespace string) Option {
+	return func(c *Validator) {
+		c.namespace = namespace
+	}
+}
+
+// WithLogger sets the logger for the validator instance
+func WithLogger(log *zerolog.Logger) Option {
+	return func(c *Validator) {
+		c.log = log
+	}
+}
+
+// Validate runs every check and returns the findings, most serious first
+func (c *Validator) Validate() *Report {
+	report := newReport()
+
+	checks := []struct {
+		name  string
+		check func(*Report)
+	}{
+		{"import", c.checkImport},
+		{"duplicates", c.checkDuplicates},
+		{"references", c.checkReferences},
+		{"stop times", c.checkStopTimes},
+		{"shapes", c.checkShapeDistance},
+		{"calendars", c.checkCalendars},
+		{"coordinates", c.checkCoordinates},
+	}
+
+	for _, check := range checks {
+		before := len(report.Findings)
+		check.check(report)
+		c.log.Debug().
+			Str("check", check.name).
+			Int("findings", len(report.Findings)-before).
+			Str("function", "pkg/validate.Validate()").
+			Msg("ran check")
+	}
+
+	report.sort()
+	return report
+}